@@ -0,0 +1,442 @@
+package themis
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Adjacency is a pair of neighboring provinces, by ID. Adjacencies go both
+// ways, the order of the provinces doesn't matter.
+type Adjacency struct {
+	From int
+	To   int
+}
+
+// normalized returns the adjacency with the smallest ID first.
+func (a Adjacency) normalized() Adjacency {
+	if a.From > a.To {
+		return Adjacency{From: a.To, To: a.From}
+	}
+	return a
+}
+
+// ParseAdjacenciesCSV reads pairs of neighboring provinces from a CSV file
+// with a header row. The province IDs are read from the `from` and `to`
+// columns, other columns are ignored. Duplicate pairs are removed.
+func ParseAdjacenciesCSV(r io.Reader) ([]Adjacency, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ValidationError{Problems: []string{"file is empty"}}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	from, hasFrom := columns["from"]
+	to, hasTo := columns["to"]
+	if !hasFrom || !hasTo {
+		return nil, ValidationError{Problems: []string{`missing required columns "from" and "to"`}}
+	}
+
+	problems := make([]string, 0)
+	adjacencies := make([]Adjacency, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read record: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		ids := make([]int, 0, 2)
+		for _, i := range []int{from, to} {
+			cell := ""
+			if i < len(record) {
+				cell = strings.TrimSpace(record[i])
+			}
+			id, err := strconv.Atoi(cell)
+			if err != nil || id <= 0 {
+				problems = append(problems, fmt.Sprintf("line %d: invalid province ID %q", line, cell))
+				continue
+			}
+			ids = append(ids, id)
+		}
+		if len(ids) != 2 {
+			continue
+		}
+		if ids[0] == ids[1] {
+			problems = append(problems, fmt.Sprintf("line %d: province %d can't be its own neighbor", line, ids[0]))
+			continue
+		}
+		adjacencies = append(adjacencies, Adjacency{From: ids[0], To: ids[1]})
+	}
+	if len(problems) > 0 {
+		return nil, ValidationError{Problems: problems}
+	}
+	return uniqueAdjacencies(adjacencies), nil
+}
+
+// LoadAdjacencies computes the neighbors of the provinces from the files of an
+// EU4 installation or mod. Two provinces are neighbors when they touch on the
+// province map, map/provinces.bmp, whose colors are matched with provinces
+// through map/definition.csv. The straits and canals of map/adjacencies.csv
+// are added to them.
+func LoadAdjacencies(fsys fs.FS) ([]Adjacency, error) {
+	records, err := readDefinitionRecords(fsys)
+	if err != nil {
+		return nil, err
+	}
+	colors := make(map[[3]byte]int)
+	for id, record := range records {
+		color := [3]byte{}
+		valid := true
+		for i := range color {
+			c, err := strconv.Atoi(strings.TrimSpace(record[i+1]))
+			if err != nil || c < 0 || c > 255 {
+				valid = false
+				break
+			}
+			color[i] = byte(c)
+		}
+		if valid {
+			colors[color] = id
+		}
+	}
+
+	// the map is binary, it mustn't go through the text decoding of
+	// readGameFile
+	raw, err := fs.ReadFile(fsys, GAME_FILE_PROVINCES_MAP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read game file %s: %w", GAME_FILE_PROVINCES_MAP, err)
+	}
+	width, height, pixels, err := decodeBitmap(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", GAME_FILE_PROVINCES_MAP, err)
+	}
+
+	// each pixel is compared with the ones on its right and below it, colors
+	// not listed in the definitions, like borders, are ignored.
+	adjacencies := make([]Adjacency, 0)
+	province := func(x, y int) int {
+		return colors[pixels[y*width+x]]
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := province(x, y)
+			if p == 0 {
+				continue
+			}
+			if x+1 < width {
+				if q := province(x+1, y); q != 0 && q != p {
+					adjacencies = append(adjacencies, Adjacency{From: p, To: q})
+				}
+			}
+			if y+1 < height {
+				if q := province(x, y+1); q != 0 && q != p {
+					adjacencies = append(adjacencies, Adjacency{From: p, To: q})
+				}
+			}
+		}
+	}
+
+	crossings, err := readGameFile(fsys, GAME_FILE_ADJACENCIES, false)
+	if err != nil {
+		return nil, err
+	}
+	if crossings != nil {
+		reader := csv.NewReader(strings.NewReader(string(crossings)))
+		reader.Comma = ';'
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", GAME_FILE_ADJACENCIES, err)
+			}
+			if len(record) < 2 {
+				continue
+			}
+			// skips the header and the -1 line closing the file
+			from, err := strconv.Atoi(strings.TrimSpace(record[0]))
+			if err != nil || from <= 0 {
+				continue
+			}
+			to, err := strconv.Atoi(strings.TrimSpace(record[1]))
+			if err != nil || to <= 0 || to == from {
+				continue
+			}
+			adjacencies = append(adjacencies, Adjacency{From: from, To: to})
+		}
+	}
+
+	return uniqueAdjacencies(adjacencies), nil
+}
+
+// decodeBitmap decodes an uncompressed 24 or 32 bits BMP image, the format of
+// the game's province map. Pixels are returned as RGB colors, row by row from
+// the top of the image.
+func decodeBitmap(raw []byte) (int, int, [][3]byte, error) {
+	if len(raw) < 54 || string(raw[:2]) != "BM" {
+		return 0, 0, nil, fmt.Errorf("not a BMP image")
+	}
+	offset := int(binary.LittleEndian.Uint32(raw[10:14]))
+	width := int(int32(binary.LittleEndian.Uint32(raw[18:22])))
+	height := int(int32(binary.LittleEndian.Uint32(raw[22:26])))
+	bpp := int(binary.LittleEndian.Uint16(raw[28:30]))
+	compression := binary.LittleEndian.Uint32(raw[30:34])
+	if compression != 0 || (bpp != 24 && bpp != 32) {
+		return 0, 0, nil, fmt.Errorf("unsupported BMP format, only uncompressed 24 and 32 bits images are")
+	}
+
+	// rows are stored bottom-up unless the height is negative
+	bottomUp := height > 0
+	if !bottomUp {
+		height = -height
+	}
+	if width <= 0 || height == 0 {
+		return 0, 0, nil, fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+	stride := (bpp*width + 31) / 32 * 4
+	if offset+stride*height > len(raw) {
+		return 0, 0, nil, fmt.Errorf("image data is truncated")
+	}
+
+	pixels := make([][3]byte, width*height)
+	for y := 0; y < height; y++ {
+		row := y
+		if bottomUp {
+			row = height - 1 - y
+		}
+		start := offset + row*stride
+		for x := 0; x < width; x++ {
+			px := raw[start+x*bpp/8:]
+			pixels[y*width+x] = [3]byte{px[2], px[1], px[0]}
+		}
+	}
+	return width, height, pixels, nil
+}
+
+// uniqueAdjacencies returns the adjacencies with the smallest ID first,
+// sorted and without duplicates.
+func uniqueAdjacencies(adjacencies []Adjacency) []Adjacency {
+	seen := make(map[Adjacency]bool)
+	unique := make([]Adjacency, 0, len(adjacencies))
+	for _, a := range adjacencies {
+		a = a.normalized()
+		if !seen[a] {
+			seen[a] = true
+			unique = append(unique, a)
+		}
+	}
+	sort.Slice(unique, func(i, j int) bool {
+		if unique[i].From != unique[j].From {
+			return unique[i].From < unique[j].From
+		}
+		return unique[i].To < unique[j].To
+	})
+	return unique
+}
+
+// ImportAdjacencies replaces the adjacencies of the provinces of the dataset.
+// Pairs with a province that is not part of the dataset, like the wastelands
+// left out of the game files import, are ignored. It returns the number of
+// pairs imported and ignored.
+func (s *Store) ImportAdjacencies(ctx context.Context, dataset string, adjacencies []Adjacency) (imported, ignored int, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	datasetId, err := findDataset(ctx, tx, dataset)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM province_data WHERE dataset_id = ?", datasetId)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to execute query: %w", err)
+	}
+	known := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan row: %w", err)
+		}
+		known[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM province_adjacencies WHERE dataset_id = ?", datasetId); err != nil {
+		return 0, 0, fmt.Errorf("failed to delete adjacencies: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO province_adjacencies (dataset_id, province_id, neighbor_id) VALUES (?1, ?2, ?3), (?1, ?3, ?2)`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+	for _, a := range uniqueAdjacencies(adjacencies) {
+		if !known[a.From] || !known[a.To] {
+			ignored++
+			continue
+		}
+		if _, err := stmt.ExecContext(ctx, datasetId, a.From, a.To); err != nil {
+			return 0, 0, fmt.Errorf("failed to insert adjacency of provinces %d and %d: %w", a.From, a.To, err)
+		}
+		imported++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return imported, ignored, nil
+}
+
+// ProvinceNeighbors returns the neighbors of the province of the dataset with
+// the given ID, ordered by ID. The default dataset is used if the name is
+// empty.
+func (s *Store) ProvinceNeighbors(ctx context.Context, dataset string, ID int) ([]Province, error) {
+	datasetId, err := findDataset(ctx, s.db, dataset)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, provinceQuery+` WHERE province_data.dataset_id = ?1 AND province_data.id IN (
+		SELECT neighbor_id FROM province_adjacencies WHERE dataset_id = ?1 AND province_id = ?2
+	) ORDER BY province_data.id`, datasetId, ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	neighbors := make([]Province, 0)
+	for rows.Next() {
+		p, err := scanProvince(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		neighbors = append(neighbors, p)
+	}
+	return neighbors, rows.Err()
+}
+
+// Neighbor is a claim of another player bordering a claim.
+type Neighbor struct {
+	Claim
+	// Borders counts the provinces of the claim bordering the other one.
+	Borders int
+}
+
+func (n Neighbor) String() string {
+	return fmt.Sprintf("%s: #%d %s %s (%d bordering provinces)", n.Player, n.ID, n.Type, n.Name, n.Borders)
+}
+
+// ClaimNeighbors returns the claims of other players bordering the claim,
+// ordered by player. It returns ErrNoAdjacencies if the campaign's dataset
+// has no adjacency data.
+func (s *Store) ClaimNeighbors(ctx context.Context, campaignId, ID int) ([]Neighbor, error) {
+	var userId string
+	err := s.db.QueryRowContext(ctx, "SELECT userid FROM claims WHERE id = ? AND campaign_id = ?", ID, campaignId).Scan(&userId)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoSuchClaim
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	datasetId, err := campaignDataset(ctx, s.db, campaignId)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := hasAdjacencies(ctx, s.db, datasetId); err != nil || !ok {
+		if err == nil {
+			err = ErrNoAdjacencies
+		}
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT claims.id, claims.player, claims.userid, claims.claim_type, claims.val,
+		COUNT(DISTINCT mine.province_id)
+	FROM claim_provinces AS mine
+	JOIN province_adjacencies AS adj ON adj.dataset_id = ? AND adj.province_id = mine.province_id
+	JOIN claim_provinces AS theirs ON theirs.campaign_id = mine.campaign_id AND theirs.province_id = adj.neighbor_id
+	JOIN claims ON claims.id = theirs.claim_id
+	WHERE mine.claim_id = ? AND theirs.userid IS NOT ?
+	GROUP BY claims.id
+	ORDER BY claims.player, claims.id`, datasetId, ID, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	neighbors := make([]Neighbor, 0)
+	for rows.Next() {
+		n := Neighbor{}
+		if err := rows.Scan(&n.ID, &n.Player, &n.UserID, &n.Type, &n.Name, &n.Borders); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		neighbors = append(neighbors, n)
+	}
+	return neighbors, rows.Err()
+}
+
+// hasAdjacencies returns true if adjacencies were imported for the dataset.
+func hasAdjacencies(ctx context.Context, q querier, datasetId int) (bool, error) {
+	var exists bool
+	err := q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM province_adjacencies WHERE dataset_id = ?)", datasetId).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to scan row: %w", err)
+	}
+	return exists, nil
+}
+
+// claimTouchesHoldings returns true if the claim borders or overlaps the
+// other claims of its owner, or if the owner holds nothing else.
+func claimTouchesHoldings(ctx context.Context, tx *sql.Tx, datasetId, claimId int) (bool, error) {
+	var touches bool
+	err := tx.QueryRowContext(ctx, `SELECT NOT EXISTS (
+		SELECT 1 FROM claim_provinces AS claimed
+		JOIN claim_provinces AS held ON held.campaign_id = claimed.campaign_id AND held.userid = claimed.userid AND held.claim_id != claimed.claim_id
+		WHERE claimed.claim_id = ?2
+	) OR EXISTS (
+		SELECT 1 FROM claim_provinces AS claimed
+		JOIN claim_provinces AS held ON held.campaign_id = claimed.campaign_id AND held.province_id = claimed.province_id
+			AND held.userid = claimed.userid AND held.claim_id != claimed.claim_id
+		WHERE claimed.claim_id = ?2
+	) OR EXISTS (
+		SELECT 1 FROM claim_provinces AS claimed
+		JOIN province_adjacencies AS adj ON adj.dataset_id = ?1 AND adj.province_id = claimed.province_id
+		JOIN claim_provinces AS held ON held.campaign_id = claimed.campaign_id AND held.province_id = adj.neighbor_id
+			AND held.userid = claimed.userid AND held.claim_id != claimed.claim_id
+		WHERE claimed.claim_id = ?2
+	)`, datasetId, claimId).Scan(&touches)
+	if err != nil {
+		return false, fmt.Errorf("failed to check contiguity of claim ID %d: %w", claimId, err)
+	}
+	return touches, nil
+}
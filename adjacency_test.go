@@ -0,0 +1,140 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAdjacenciesCSV(t *testing.T) {
+	adjacencies, err := ParseAdjacenciesCSV(strings.NewReader(`From,To,Comment
+2,1,Östergötland-Stockholm
+1,2,
+4,2,strait
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []Adjacency{{From: 1, To: 2}, {From: 2, To: 4}}, adjacencies)
+
+	_, err = ParseAdjacenciesCSV(strings.NewReader("province,neighbor\n1,2\n"))
+	assert.Error(t, err)
+	_, err = ParseAdjacenciesCSV(strings.NewReader("from,to\n1,1\n2,x\n"))
+	var verr ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Equal(t, []string{"line 2: province 1 can't be its own neighbor", `line 3: invalid province ID "x"`}, verr.Problems)
+}
+
+func TestLoadAdjacencies(t *testing.T) {
+	adjacencies, err := LoadAdjacencies(os.DirFS("testdata/eu4"))
+	assert.NoError(t, err)
+	assert.Equal(t, []Adjacency{
+		{From: 1, To: 2}, {From: 1, To: 3}, {From: 1, To: 7}, {From: 1, To: 8}, {From: 1, To: 9},
+		{From: 2, To: 3}, {From: 2, To: 4}, {From: 2, To: 8},
+		{From: 3, To: 5}, {From: 3, To: 6}, {From: 3, To: 7}, {From: 3, To: 8}, {From: 3, To: 9},
+		{From: 4, To: 8}, {From: 5, To: 6}, {From: 7, To: 9},
+	}, adjacencies)
+}
+
+func TestContiguousClaims(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestContiguousClaims"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.TODO()
+	provinces, err := LoadGameFiles(os.DirFS("testdata/eu4"))
+	assert.NoError(t, err)
+	_, err = store.ImportProvinces(ctx, "scandinavia", provinces)
+	assert.NoError(t, err)
+	vanillaId, err := store.CreateCampaign(ctx, TEST_GUILD_ID, "vanilla game")
+	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(ctx, TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+	assert.NoError(t, store.SetCampaignDataset(ctx, campaignId, "scandinavia"))
+	// wastes, lakes and seas are claimed below
+	assert.NoError(t, store.SetProvinceTypes(ctx, campaignId, ProvinceTypes...))
+
+	assert.ErrorIs(t, store.SetContiguousClaims(ctx, campaignId, true), ErrNoAdjacencies)
+
+	adjacencies, err := LoadAdjacencies(os.DirFS("testdata/eu4"))
+	assert.NoError(t, err)
+	imported, ignored, err := store.ImportAdjacencies(ctx, "scandinavia", append(adjacencies, Adjacency{From: 1, To: 10}))
+	assert.NoError(t, err)
+	assert.Equal(t, 16, imported)
+	assert.Equal(t, 1, ignored)
+
+	neighbors, err := store.ProvinceNeighbors(ctx, "scandinavia", 6)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(neighbors))
+	assert.Equal(t, "Småland", neighbors[0].Name)
+	assert.Equal(t, "Kalmar", neighbors[1].Name)
+	neighbors, err = store.ProvinceNeighbors(ctx, DEFAULT_DATASET, 6)
+	assert.NoError(t, err)
+	assert.Empty(t, neighbors)
+
+	assert.NoError(t, store.SetContiguousClaims(ctx, campaignId, true))
+	campaign, err := store.ActiveCampaign(ctx, TEST_GUILD_ID)
+	assert.NoError(t, err)
+	assert.True(t, campaign.ContiguousClaims)
+	assert.Contains(t, campaign.String(), "scandinavia, contiguous claims, provinces: Land, Sea, Open sea, Inland sea, Lake, Wasteland")
+
+	// the first claim of a player can be anywhere
+	_, err = store.Claim(ctx, campaignId, "000000000000000001", "foo", "Stockholm", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+	_, err = store.Claim(ctx, campaignId, "000000000000000001", "foo", "Skåne", CLAIM_TYPE_PROVINCE)
+	assert.Equal(t, ErrNotContiguous{Zone: Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Skåne"}}, err)
+	ostergotland, err := store.Claim(ctx, campaignId, "000000000000000001", "foo", "Östergötland", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	skane, err := store.Claim(ctx, campaignId, "000000000000000001", "foo", "Skåne", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+	kalmar, err := store.Claim(ctx, campaignId, "000000000000000002", "bar", "Kalmar", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+
+	// claims of a bundle can touch the ones before them
+	_, err = store.ClaimMany(ctx, campaignId, "000000000000000003", "baz",
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Lapland Wastes"}},
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Baltic Sea"}},
+	)
+	assert.ErrorAs(t, err, &ErrNotContiguous{})
+	_, err = store.ClaimMany(ctx, campaignId, "000000000000000003", "baz",
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Lapland Wastes"}},
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Lake Vänern"}},
+	)
+	assert.NoError(t, err)
+
+	bordering, err := store.ClaimNeighbors(ctx, campaignId, kalmar)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bordering))
+	assert.Equal(t, ostergotland, bordering[0].ID)
+	assert.Equal(t, 1, bordering[0].Borders)
+	assert.Equal(t, skane, bordering[1].ID)
+	assert.Equal(t, "foo: #3 Province 6 (1 bordering provinces)", bordering[1].String())
+
+	_, err = store.ClaimNeighbors(ctx, campaignId, 42)
+	assert.ErrorIs(t, err, ErrNoSuchClaim)
+	vanillaClaim, err := store.Claim(ctx, vanillaId, "000000000000000001", "foo", "Gascony", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	_, err = store.ClaimNeighbors(ctx, vanillaId, vanillaClaim)
+	assert.ErrorIs(t, err, ErrNoAdjacencies)
+
+	assert.NoError(t, store.SetContiguousClaims(ctx, campaignId, false))
+	_, err = store.Claim(ctx, campaignId, "000000000000000002", "bar", "Gotland", CLAIM_TYPE_PROVINCE)
+	assert.Error(t, err)
+	_, err = store.Claim(ctx, campaignId, "000000000000000002", "bar", "Baltic Sea", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+
+	// adjacencies of removed provinces go with them
+	modified := make([]Province, 0, len(provinces))
+	for _, p := range provinces {
+		if p.ID != 9 {
+			modified = append(modified, p)
+		}
+	}
+	_, err = store.ImportProvinces(ctx, "scandinavia", modified)
+	assert.NoError(t, err)
+	neighbors, err = store.ProvinceNeighbors(ctx, "scandinavia", 7)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(neighbors))
+}
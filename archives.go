@@ -0,0 +1,227 @@
+package themis
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Archive is a snapshot of a campaign's claims taken when the board was
+// flushed.
+type Archive struct {
+	ID           int
+	Name         string
+	CreatedAt    time.Time
+	Participants []string
+	// Only set by DescribeArchive.
+	Claims []Claim
+}
+
+func (a Archive) String() string {
+	return fmt.Sprintf("#%d %s (%s, %s)", a.ID, a.Name, a.CreatedAt.Format("2006-01-02"), strings.Join(a.Participants, ", "))
+}
+
+// ListArchives returns the campaign's archives, most recent first.
+func (s *Store) ListArchives(ctx context.Context, campaignId int) ([]Archive, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT archives.id, archives.name, archives.created_at, IFNULL(GROUP_CONCAT(DISTINCT archived_claims.player), '')
+	FROM archives LEFT JOIN archived_claims ON archives.id = archived_claims.archive_id
+	WHERE archives.campaign_id = ?
+	GROUP BY archives.id
+	ORDER BY archives.id DESC`, campaignId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	archives := make([]Archive, 0)
+	for rows.Next() {
+		a := Archive{}
+		var participants string
+		if err := rows.Scan(&a.ID, &a.Name, &a.CreatedAt, &participants); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		a.Participants = splitParticipants(participants)
+		archives = append(archives, a)
+	}
+
+	return archives, rows.Err()
+}
+
+// DescribeArchive returns the archive along with all of its claims.
+func (s *Store) DescribeArchive(ctx context.Context, campaignId, ID int) (Archive, error) {
+	a := Archive{}
+	err := s.db.QueryRowContext(ctx, "SELECT id, name, created_at FROM archives WHERE id = ? AND campaign_id = ?", ID, campaignId).
+		Scan(&a.ID, &a.Name, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return Archive{}, ErrNoSuchArchive
+	}
+	if err != nil {
+		return Archive{}, fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT claim_id, player, claim_type, val, userid, created_at
+	FROM archived_claims WHERE archive_id = ? ORDER BY claim_id`, ID)
+	if err != nil {
+		return Archive{}, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	a.Claims = make([]Claim, 0)
+	seen := make(map[string]bool)
+	for rows.Next() {
+		c := Claim{}
+		var createdAt sql.NullTime
+		if err := rows.Scan(&c.ID, &c.Player, &c.Type, &c.Name, &c.UserID, &createdAt); err != nil {
+			return Archive{}, fmt.Errorf("failed to scan row: %w", err)
+		}
+		c.CreatedAt = createdAt.Time
+		a.Claims = append(a.Claims, c)
+
+		if !seen[c.Player] {
+			seen[c.Player] = true
+			a.Participants = append(a.Participants, c.Player)
+		}
+	}
+
+	return a, rows.Err()
+}
+
+// RestoreArchive puts the archived claims back on the campaign's board, along
+// with their exclusions. The board must be empty, flush it first to archive the
+// claims currently on it.
+func (s *Store) RestoreArchive(ctx context.Context, campaignId, ID int, userId string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var count int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(1) FROM archives WHERE id = ? AND campaign_id = ?", ID, campaignId).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to scan row: %w", err)
+	}
+	if count == 0 {
+		return ErrNoSuchArchive
+	}
+
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(1) FROM claims WHERE campaign_id = ?", campaignId).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to scan row: %w", err)
+	}
+	if count > 0 {
+		return ErrBoardNotEmpty
+	}
+
+	// Claims are restored with their original IDs, IDs are never reused so
+	// they are guaranteed to be free once the board is empty.
+	rows, err := tx.QueryContext(ctx, "SELECT claim_id, player, claim_type, val, userid FROM archived_claims WHERE archive_id = ?", ID)
+	if err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	claims := make([]Claim, 0)
+	for rows.Next() {
+		c := Claim{}
+		if err := rows.Scan(&c.ID, &c.Player, &c.Type, &c.Name, &c.UserID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		claims = append(claims, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate rows: %w", err)
+	}
+	exclusions, err := archivedExclusions(ctx, tx, ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO claims (id, player, claim_type, val, zone_id, userid, campaign_id, created_at)
+	SELECT claim_id, player, claim_type, val, %s, userid, ?1, created_at FROM archived_claims WHERE archive_id = ?2`,
+		zoneIdExpression("claim_type", "val", "(SELECT dataset_id FROM campaigns WHERE id = ?1)")), campaignId, ID)
+	if err != nil {
+		return fmt.Errorf("failed to restore claims: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO claim_exclusions (claim_id, claim_type, val)
+	SELECT claim_id, claim_type, val FROM archived_exclusions WHERE archive_id = ?`, ID)
+	if err != nil {
+		return fmt.Errorf("failed to restore exclusions: %w", err)
+	}
+
+	for _, c := range claims {
+		if err := insertClaimProvinces(ctx, tx, c.ID); err != nil {
+			return err
+		}
+		payload := payloadFromClaim(c)
+		payload.Exclusions = exclusions[c.ID]
+		if err := recordEvent(ctx, tx, campaignId, c.ID, EVENT_TYPE_RESTORED, userId, payload); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// archiveClaims snapshots the campaign's current claims and their exclusions
+// into a new archive as part of the transaction flushing them.
+func archiveClaims(ctx context.Context, tx *sql.Tx, campaignId int, name string) (int, error) {
+	res, err := tx.ExecContext(ctx, "INSERT INTO archives (campaign_id, name, created_at) VALUES (?, ?, ?)", campaignId, name, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert archive: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last ID: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO archived_claims (archive_id, claim_id, player, claim_type, val, userid, created_at)
+	SELECT ?, id, player, claim_type, val, userid, created_at FROM claims WHERE campaign_id = ?`, id, campaignId)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive claims: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO archived_exclusions (archive_id, claim_id, claim_type, val)
+	SELECT ?, claim_exclusions.claim_id, claim_exclusions.claim_type, claim_exclusions.val
+	FROM claim_exclusions JOIN claims ON claims.id = claim_exclusions.claim_id
+	WHERE claims.campaign_id = ?`, id, campaignId)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive exclusions: %w", err)
+	}
+
+	return int(id), nil
+}
+
+// archivedExclusions returns the exclusions of the claims of the archive, by
+// claim ID.
+func archivedExclusions(ctx context.Context, q querier, archiveId int) (map[int][]Zone, error) {
+	rows, err := q.QueryContext(ctx, "SELECT claim_id, claim_type, val FROM archived_exclusions WHERE archive_id = ? ORDER BY rowid", archiveId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	exclusions := make(map[int][]Zone)
+	for rows.Next() {
+		var id int
+		z := Zone{}
+		if err := rows.Scan(&id, &z.Type, &z.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		exclusions[id] = append(exclusions[id], z)
+	}
+	return exclusions, rows.Err()
+}
+
+func splitParticipants(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
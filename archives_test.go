@@ -0,0 +1,96 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchives(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestArchives"))
+	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	genoaId, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Genoa", CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000002", "bar", "Scandinavia", CLAIM_TYPE_REGION)
+	assert.NoError(t, err)
+	franceId, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "France", CLAIM_TYPE_REGION, Zone{Type: CLAIM_TYPE_AREA, Name: "Gascony"})
+	assert.NoError(t, err)
+	provinces := func() int {
+		var count int
+		assert.NoError(t, store.db.QueryRow("SELECT COUNT(1) FROM claim_provinces WHERE claim_id = ?", franceId).Scan(&count))
+		return count
+	}
+	assert.Equal(t, 62, provinces())
+
+	archiveId, err := store.Flush(context.TODO(), campaignId, "000000000000000001", "first game")
+	assert.NoError(t, err)
+
+	total, _, err := store.CountClaims(context.TODO(), campaignId)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, total)
+	var exclusions int
+	assert.NoError(t, store.db.QueryRow("SELECT COUNT(1) FROM claim_exclusions").Scan(&exclusions))
+	assert.Equal(t, 0, exclusions)
+	events, err := store.ClaimHistory(context.TODO(), campaignId, HistoryFilter{Zone: "France"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, EventType(EVENT_TYPE_FLUSHED), events[1].Type)
+	assert.Equal(t, []Zone{{Type: CLAIM_TYPE_AREA, Name: "Gascony"}}, events[1].Payload.Exclusions)
+
+	archives, err := store.ListArchives(context.TODO(), campaignId)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(archives))
+	assert.Equal(t, "first game", archives[0].Name)
+	assert.ElementsMatch(t, []string{"foo", "bar"}, archives[0].Participants)
+
+	archive, err := store.DescribeArchive(context.TODO(), campaignId, archiveId)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(archive.Claims))
+	assert.Equal(t, "Genoa", archive.Claims[0].Name)
+	assert.False(t, archive.Claims[0].CreatedAt.IsZero())
+
+	_, err = store.DescribeArchive(context.TODO(), campaignId, 9999)
+	assert.ErrorIs(t, err, ErrNoSuchArchive)
+
+	// archives can only be restored on an empty board
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000003", "baz", "Venice", CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, store.RestoreArchive(context.TODO(), campaignId, archiveId, "000000000000000001"), ErrBoardNotEmpty)
+
+	_, err = store.Flush(context.TODO(), campaignId, "000000000000000001", "oops")
+	assert.NoError(t, err)
+	assert.NoError(t, store.RestoreArchive(context.TODO(), campaignId, archiveId, "000000000000000001"))
+
+	claims, err := store.ListClaims(context.TODO(), campaignId)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(claims))
+	assert.Equal(t, genoaId, claims[0].ID)
+	assert.Equal(t, archive.Claims[0].CreatedAt, claims[0].CreatedAt)
+	assert.Equal(t, 62, provinces())
+
+	// restored claims are the same as the original ones, the conflicts still
+	// apply and they can be released by their owner.
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000003", "baz", "Genoa", CLAIM_TYPE_TRADE)
+	assert.Error(t, err)
+	assert.NoError(t, store.DeleteClaim(context.TODO(), campaignId, genoaId, "000000000000000001"))
+
+	events, err = store.ClaimHistory(context.TODO(), campaignId, HistoryFilter{Zone: "Genoa"})
+	assert.NoError(t, err)
+	types := make([]EventType, 0, len(events))
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []EventType{EVENT_TYPE_CREATED, EVENT_TYPE_FLUSHED, EVENT_TYPE_RESTORED, EVENT_TYPE_RELEASED}, types)
+
+	// the exclusions come back every time the archive is restored
+	assert.NoError(t, store.DeleteClaim(context.TODO(), campaignId, franceId, "000000000000000001"))
+	_, err = store.Flush(context.TODO(), campaignId, "000000000000000001", "again")
+	assert.NoError(t, err)
+	assert.NoError(t, store.RestoreArchive(context.TODO(), campaignId, archiveId, "000000000000000001"))
+	assert.Equal(t, 62, provinces())
+}
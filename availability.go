@@ -0,0 +1,160 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+)
+
+// AvailabilityStatus tells how much of a zone the claims of a campaign hold,
+// whatever their claim type.
+type AvailabilityStatus string
+
+const (
+	AVAILABILITY_FREE    AvailabilityStatus = "free"
+	AVAILABILITY_PARTIAL AvailabilityStatus = "partially taken"
+	AVAILABILITY_TAKEN   AvailabilityStatus = "taken"
+)
+
+// ZoneAvailability counts the provinces of a zone, of the types counted by the
+// campaign, and the ones no claim of the campaign holds through any claim
+// type.
+type ZoneAvailability struct {
+	Zone
+	// ProvinceID is the ID of the province for province zones, zero
+	// otherwise.
+	ProvinceID      int
+	Provinces       int
+	Free            int
+	Development     int
+	FreeDevelopment int
+}
+
+// Status tells whether the provinces of the zone are all free, partially taken
+// or all taken. Zones without provinces are free.
+func (a ZoneAvailability) Status() AvailabilityStatus {
+	switch {
+	case a.Free == a.Provinces:
+		return AVAILABILITY_FREE
+	case a.Free > 0:
+		return AVAILABILITY_PARTIAL
+	default:
+		return AVAILABILITY_TAKEN
+	}
+}
+
+// FreePercent is the percentage of the provinces of the zone that are free,
+// rounded down.
+func (a ZoneAvailability) FreePercent() int {
+	if a.Provinces == 0 {
+		return 100
+	}
+	return a.Free * 100 / a.Provinces
+}
+
+func (a ZoneAvailability) String() string {
+	if a.Type == CLAIM_TYPE_PROVINCE {
+		return fmt.Sprintf("%s (%s #%d) - %s, %d development", a.Name, a.Type, a.ProvinceID, a.Status(), a.Development)
+	}
+	if a.Status() == AVAILABILITY_PARTIAL {
+		return fmt.Sprintf("%s (%s) - %s, %d/%d provinces free, %d/%d development free", a.Name, a.Type, a.Status(), a.Free, a.Provinces, a.FreeDevelopment, a.Development)
+	}
+	return fmt.Sprintf("%s (%s) - %s, %d provinces, %d development", a.Name, a.Type, a.Status(), a.Provinces, a.Development)
+}
+
+// AvailabilityFilter narrows down the zones listed by Availability.
+type AvailabilityFilter struct {
+	// Type is the claim type of the zones, all of them if empty.
+	Type ClaimType
+	// Continent keeps the zones with provinces in the continent.
+	Continent string
+	// MinFreePercent keeps the zones with at least that percentage of their
+	// provinces free, 100 keeps the zones that can be claimed whole.
+	MinFreePercent int
+	// Search keeps the zones whose name contains it, compared folded.
+	Search string
+}
+
+// availabilityQuery counts the provinces and free provinces of the zones of a
+// claim type, given the column of the provinces view holding the zone IDs, the
+// one holding their names, extra conditions and the province type condition.
+const availabilityQuery = `SELECT provinces.%[1]s, MIN(provinces.%[2]s), COUNT(1), SUM(held.province_id IS NULL),
+		SUM(CAST(provinces.development AS INTEGER)),
+		SUM(CASE WHEN held.province_id IS NULL THEN CAST(provinces.development AS INTEGER) ELSE 0 END)
+	FROM provinces
+	LEFT JOIN (SELECT DISTINCT province_id FROM claim_provinces WHERE campaign_id = ?2) AS held ON held.province_id = provinces.id
+	WHERE provinces.dataset_id = ?1 AND %[4]s AND provinces.%[1]s IS NOT NULL%[3]s
+	GROUP BY provinces.%[1]s`
+
+// Availability lists the zones of the campaign's dataset matching the filter
+// along with how many of their provinces are free, by claim type then name.
+// Only the provinces of the types counted by the campaign are considered, see
+// SetProvinceTypes, zones without any are left out.
+func (s *Store) Availability(ctx context.Context, campaignId int, filter AvailabilityFilter) ([]ZoneAvailability, error) {
+	datasetId, err := campaignDataset(ctx, s.db, campaignId)
+	if err != nil {
+		return nil, err
+	}
+
+	continentId := 0
+	if filter.Continent != "" {
+		continentId, _, err = resolveZoneID(ctx, s.db, datasetId, Zone{Type: CLAIM_TYPE_CONTINENT, Name: filter.Continent})
+		if err != nil {
+			return nil, withSuggestions(ctx, s.db, datasetId, err)
+		}
+	}
+
+	types := ClaimTypes
+	if filter.Type != "" {
+		if _, ok := claimTypeToIDColumn[filter.Type]; !ok {
+			return nil, fmt.Errorf("no claim type matching '%s'", filter.Type)
+		}
+		types = []ClaimType{filter.Type}
+	}
+
+	zones := make([]ZoneAvailability, 0)
+	for _, ct := range types {
+		idColumn, column := claimTypeToIDColumn[ct], claimTypeToColumn[ct]
+		if ct == CLAIM_TYPE_PROVINCE {
+			column = "name"
+		}
+
+		conds := ""
+		params := []any{datasetId, campaignId}
+		if filter.Continent != "" {
+			conds += fmt.Sprintf("\n\tAND provinces.%[1]s IN (SELECT %[1]s FROM provinces WHERE dataset_id = ?1 AND continent_id = ?3)", idColumn)
+			params = append(params, continentId)
+		}
+		if filter.Search != "" {
+			conds += fmt.Sprintf("\n\tAND fold_name(provinces.%s) LIKE ?%d", column, len(params)+1)
+			params = append(params, fmt.Sprintf("%%%s%%", foldName(filter.Search)))
+		}
+		query := fmt.Sprintf(availabilityQuery, idColumn, column, conds, provinceTypeCondition("?2", "provinces.typ"))
+		if filter.MinFreePercent > 0 {
+			query += fmt.Sprintf("\n\tHAVING SUM(held.province_id IS NULL) * 100 >= ?%d * COUNT(1)", len(params)+1)
+			params = append(params, filter.MinFreePercent)
+		}
+		query += fmt.Sprintf("\n\tORDER BY MIN(provinces.%s), provinces.%s", column, idColumn)
+
+		rows, err := s.db.QueryContext(ctx, query, params...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute query: %w", err)
+		}
+		for rows.Next() {
+			a := ZoneAvailability{Zone: Zone{Type: ct}}
+			var zoneId int
+			if err := rows.Scan(&zoneId, &a.Name, &a.Provinces, &a.Free, &a.Development, &a.FreeDevelopment); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan row: %w", err)
+			}
+			if ct == CLAIM_TYPE_PROVINCE {
+				a.ProvinceID = zoneId
+			}
+			zones = append(zones, a)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to iterate rows: %w", err)
+		}
+	}
+	return zones, nil
+}
@@ -0,0 +1,315 @@
+package themis
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Campaign is a single game played by a guild. Claims always belong to a
+// campaign, and each guild has at most one active campaign at a time.
+type Campaign struct {
+	ID         int
+	GuildID    string
+	Name       string
+	Active     bool
+	CreatedAt  time.Time
+	ArchivedAt sql.NullTime
+	// Dataset is the name of the province dataset claims are resolved
+	// against.
+	Dataset string
+	// ContiguousClaims is true when new claims must touch the player's other
+	// claims.
+	ContiguousClaims bool
+	// ProvinceTypes are the types of the provinces claims hold, conflict on
+	// and count in totals, see ProvinceTypes. Only land provinces by default.
+	ProvinceTypes []string
+}
+
+func (c Campaign) String() string {
+	status := "inactive"
+	if c.Active {
+		status = "active"
+	}
+	if c.ArchivedAt.Valid {
+		status = "archived"
+	}
+	if c.Dataset != "" && c.Dataset != DEFAULT_DATASET {
+		status += ", " + c.Dataset
+	}
+	if c.ContiguousClaims {
+		status += ", contiguous claims"
+	}
+	if len(c.ProvinceTypes) > 0 && strings.Join(c.ProvinceTypes, ",") != DEFAULT_PROVINCE_TYPES {
+		status += ", provinces: " + strings.Join(c.ProvinceTypes, ", ")
+	}
+	return fmt.Sprintf("#%d %s (%s, created %s)", c.ID, c.Name, status, c.CreatedAt.Format("2006-01-02"))
+}
+
+// CreateCampaign creates a new campaign for the guild and makes it the guild's
+// active campaign.
+func (s *Store) CreateCampaign(ctx context.Context, guildId, name string) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	_, err = tx.ExecContext(ctx, "UPDATE campaigns SET active = 0 WHERE guild_id = ?", guildId)
+	if err != nil {
+		return 0, fmt.Errorf("failed to deactivate campaigns: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO campaigns (guild_id, name, active) VALUES (?, ?, 1)", guildId, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert campaign: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last ID: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return int(id), nil
+}
+
+// ActiveCampaign returns the guild's active campaign, or ErrNoActiveCampaign if
+// there is none.
+func (s *Store) ActiveCampaign(ctx context.Context, guildId string) (Campaign, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT id, guild_id, name, active, created_at, archived_at,
+		(SELECT name FROM datasets WHERE datasets.id = campaigns.dataset_id), contiguous_claims, province_types
+	FROM campaigns WHERE guild_id = ? AND active = 1`, guildId)
+
+	c, err := scanCampaign(row)
+	if err == sql.ErrNoRows {
+		return Campaign{}, ErrNoActiveCampaign
+	}
+	if err != nil {
+		return Campaign{}, fmt.Errorf("failed to scan row: %w", err)
+	}
+	return c, nil
+}
+
+// FindCampaign returns the guild's campaign with the given name.
+func (s *Store) FindCampaign(ctx context.Context, guildId, name string) (Campaign, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT id, guild_id, name, active, created_at, archived_at,
+		(SELECT name FROM datasets WHERE datasets.id = campaigns.dataset_id), contiguous_claims, province_types
+	FROM campaigns WHERE guild_id = ? AND name = ?`, guildId, name)
+
+	c, err := scanCampaign(row)
+	if err == sql.ErrNoRows {
+		return Campaign{}, ErrNoSuchCampaign
+	}
+	if err != nil {
+		return Campaign{}, fmt.Errorf("failed to scan row: %w", err)
+	}
+	return c, nil
+}
+
+// SwitchCampaign makes the campaign with the given ID the guild's active
+// campaign. Archived campaigns cannot be switched to.
+func (s *Store) SwitchCampaign(ctx context.Context, guildId string, ID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var archived sql.NullTime
+	err = tx.QueryRowContext(ctx, "SELECT archived_at FROM campaigns WHERE id = ? AND guild_id = ?", ID, guildId).Scan(&archived)
+	if err == sql.ErrNoRows {
+		return ErrNoSuchCampaign
+	}
+	if err != nil {
+		return fmt.Errorf("failed to scan row: %w", err)
+	}
+	if archived.Valid {
+		return ErrCampaignArchived
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE campaigns SET active = (id = ?) WHERE guild_id = ?", ID, guildId)
+	if err != nil {
+		return fmt.Errorf("failed to switch campaign: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ArchiveCampaign marks the campaign as archived. If it was the guild's active
+// campaign, the guild is left without an active campaign. Claims of archived
+// campaigns are kept.
+func (s *Store) ArchiveCampaign(ctx context.Context, guildId string, ID int) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE campaigns SET active = 0, archived_at = ?
+	WHERE id = ? AND guild_id = ? AND archived_at IS NULL`, time.Now().UTC(), ID, guildId)
+	if err != nil {
+		return fmt.Errorf("failed to archive campaign: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return ErrNoSuchCampaign
+	}
+	return nil
+}
+
+// ListCampaigns returns all the guild's campaigns, including archived ones,
+// most recent first.
+func (s *Store) ListCampaigns(ctx context.Context, guildId string) ([]Campaign, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, guild_id, name, active, created_at, archived_at,
+		(SELECT name FROM datasets WHERE datasets.id = campaigns.dataset_id), contiguous_claims, province_types
+	FROM campaigns WHERE guild_id = ? ORDER BY id DESC`, guildId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	campaigns := make([]Campaign, 0)
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		campaigns = append(campaigns, c)
+	}
+
+	return campaigns, rows.Err()
+}
+
+// SetContiguousClaims turns on or off the rule requiring new claims to touch
+// the player's other claims. The rule can only be turned on when adjacencies
+// were imported for the campaign's dataset, ErrNoAdjacencies is returned
+// otherwise. Existing claims are left as they are.
+func (s *Store) SetContiguousClaims(ctx context.Context, campaignId int, enabled bool) error {
+	datasetId, err := campaignDataset(ctx, s.db, campaignId)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		ok, err := hasAdjacencies(ctx, s.db, datasetId)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNoAdjacencies
+		}
+	}
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE campaigns SET contiguous_claims = ? WHERE id = ?", enabled, campaignId); err != nil {
+		return fmt.Errorf("failed to update campaign: %w", err)
+	}
+	return nil
+}
+
+// DEFAULT_PROVINCE_TYPES are the types of the provinces claims hold in new
+// campaigns, as stored in the campaigns table.
+const DEFAULT_PROVINCE_TYPES = "Land"
+
+// SetProvinceTypes changes the types of the provinces claims hold, conflict on
+// and count in totals, see ProvinceTypes. Types are matched case-insensitively
+// and at least one is needed. The provinces held by the existing claims are
+// recomputed, which fails if it makes claims of different players overlap.
+func (s *Store) SetProvinceTypes(ctx context.Context, campaignId int, types ...string) error {
+	wanted := make(map[string]bool, len(types))
+	for _, t := range types {
+		found := false
+		for _, pt := range ProvinceTypes {
+			if strings.EqualFold(strings.TrimSpace(t), pt) {
+				wanted[pt] = true
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown province type %q, must be one of %s", t, strings.Join(ProvinceTypes, ", "))
+		}
+	}
+	if len(wanted) == 0 {
+		return fmt.Errorf("at least one province type is needed")
+	}
+	canonical := make([]string, 0, len(wanted))
+	for _, pt := range ProvinceTypes {
+		if wanted[pt] {
+			canonical = append(canonical, pt)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	res, err := tx.ExecContext(ctx, "UPDATE campaigns SET province_types = ? WHERE id = ?", strings.Join(canonical, ","), campaignId)
+	if err != nil {
+		return fmt.Errorf("failed to update campaign: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return ErrNoSuchCampaign
+	}
+
+	claimId, err := reinsertClaimProvinces(ctx, tx, "campaigns.id = ?", campaignId)
+	if err != nil && claimId != 0 {
+		return fmt.Errorf("counting %s provinces makes claim ID %d overlap with another player's claim: %w", strings.Join(canonical, ", "), claimId, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// provinceTypeCondition is the SQL condition keeping the provinces whose type,
+// given by the typ expression, is counted by the campaign given by the
+// campaignId expression. See SetProvinceTypes.
+func provinceTypeCondition(campaignId, typ string) string {
+	return fmt.Sprintf("instr(',' || (SELECT province_types FROM campaigns WHERE campaigns.id = %s) || ',', ',' || %s || ',') > 0", campaignId, typ)
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// AdoptCampaigns hands the campaigns that don't belong to any guild over to
+// the given guild. Those hold the claims made before campaigns existed, which
+// were all made in the guild the bot was running in. The adopted campaigns
+// are only left active if the guild has no active campaign of its own.
+func (s *Store) AdoptCampaigns(ctx context.Context, guildId string) (int, error) {
+	res, err := s.db.ExecContext(ctx, `UPDATE campaigns
+	SET guild_id = ?, active = active AND NOT EXISTS (SELECT 1 FROM campaigns WHERE guild_id = ? AND active = 1)
+	WHERE guild_id = ''`, guildId, guildId)
+	if err != nil {
+		return 0, fmt.Errorf("failed to adopt campaigns: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return int(affected), nil
+}
+
+func scanCampaign(row scanner) (Campaign, error) {
+	c := Campaign{}
+	var provinceTypes string
+	err := row.Scan(&c.ID, &c.GuildID, &c.Name, &c.Active, &c.CreatedAt, &c.ArchivedAt, &c.Dataset, &c.ContiguousClaims, &provinceTypes)
+	c.ProvinceTypes = strings.Split(provinceTypes, ",")
+	return c, err
+}
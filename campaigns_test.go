@@ -0,0 +1,181 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCampaigns(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestCampaigns"))
+	assert.NoError(t, err)
+
+	_, err = store.ActiveCampaign(context.TODO(), TEST_GUILD_ID)
+	assert.ErrorIs(t, err, ErrNoActiveCampaign)
+
+	firstId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, "first")
+	assert.NoError(t, err)
+	secondId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, "second")
+	assert.NoError(t, err)
+
+	// names are unique within a guild
+	_, err = store.CreateCampaign(context.TODO(), TEST_GUILD_ID, "second")
+	assert.Error(t, err)
+
+	// the most recently created campaign becomes active
+	active, err := store.ActiveCampaign(context.TODO(), TEST_GUILD_ID)
+	assert.NoError(t, err)
+	assert.Equal(t, secondId, active.ID)
+
+	// claims are scoped to their campaign, the same zone can be claimed by
+	// different players in different campaigns
+	_, err = store.Claim(context.TODO(), firstId, "000000000000000001", "foo", "Genoa", CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+	_, err = store.Claim(context.TODO(), secondId, "000000000000000002", "bar", "Italy", CLAIM_TYPE_REGION)
+	assert.NoError(t, err)
+
+	claims, err := store.ListClaims(context.TODO(), firstId)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(claims))
+	assert.Equal(t, "Genoa", claims[0].Name)
+
+	assert.NoError(t, store.SwitchCampaign(context.TODO(), TEST_GUILD_ID, firstId))
+	active, err = store.ActiveCampaign(context.TODO(), TEST_GUILD_ID)
+	assert.NoError(t, err)
+	assert.Equal(t, firstId, active.ID)
+
+	// flushing a campaign doesn't touch the others
+	_, err = store.Flush(context.TODO(), firstId, "000000000000000001", "test")
+	assert.NoError(t, err)
+	total, _, err := store.CountClaims(context.TODO(), secondId)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	assert.NoError(t, store.ArchiveCampaign(context.TODO(), TEST_GUILD_ID, firstId))
+	_, err = store.ActiveCampaign(context.TODO(), TEST_GUILD_ID)
+	assert.ErrorIs(t, err, ErrNoActiveCampaign)
+	assert.ErrorIs(t, store.SwitchCampaign(context.TODO(), TEST_GUILD_ID, firstId), ErrCampaignArchived)
+	assert.ErrorIs(t, store.ArchiveCampaign(context.TODO(), TEST_GUILD_ID, firstId), ErrNoSuchCampaign)
+
+	// campaigns are not visible from other guilds
+	assert.ErrorIs(t, store.SwitchCampaign(context.TODO(), "000000000000000999", secondId), ErrNoSuchCampaign)
+
+	campaigns, err := store.ListCampaigns(context.TODO(), TEST_GUILD_ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(campaigns))
+	assert.True(t, campaigns[1].ArchivedAt.Valid)
+
+	found, err := store.FindCampaign(context.TODO(), TEST_GUILD_ID, "second")
+	assert.NoError(t, err)
+	assert.Equal(t, secondId, found.ID)
+}
+
+func TestProvinceTypes(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestProvinceTypes"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	// a coast with a province of each type, in the same zones
+	ctx := context.TODO()
+	zones := Province{TradeNode: "Coast", Area: "Shore", Region: "Coastline", Superregion: "Coasts", Continent: "Coastia", Modifiers: []string{}}
+	provinces := make([]Province, 0, len(ProvinceTypes)+1)
+	for i, name := range []string{"Harbor", "Shallows", "Deep", "Lagoon", "Mere", "Dunes", "Cliffs"} {
+		p := zones
+		p.ID, p.Name, p.Type = i+1, name, "Land"
+		if i < len(ProvinceTypes) {
+			p.Type = ProvinceTypes[i]
+		}
+		if p.Type == "Land" {
+			p.Development = 10
+		}
+		provinces = append(provinces, p)
+	}
+	_, err = store.ImportProvinces(ctx, "coast", provinces)
+	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(ctx, TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+	assert.NoError(t, store.SetCampaignDataset(ctx, campaignId, "coast"))
+
+	campaign, err := store.ActiveCampaign(ctx, TEST_GUILD_ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Land"}, campaign.ProvinceTypes)
+	assert.NotContains(t, campaign.String(), "provinces:")
+
+	// only land provinces count by default
+	for _, p := range provinces[1 : len(provinces)-1] {
+		_, err := store.Claim(ctx, campaignId, "000000000000000002", "bar", p.Name, CLAIM_TYPE_PROVINCE)
+		assert.Equal(t, ErrNoProvinces{Zone: Zone{Type: CLAIM_TYPE_PROVINCE, Name: p.Name}, ProvinceTypes: []string{"Land"}}, err, p.Type)
+	}
+	assert.Equal(t, "Province Mere has no Land provinces", ErrNoProvinces{Zone: Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Mere"}, ProvinceTypes: []string{"Land"}}.Error())
+
+	preview, err := store.PreviewClaim(ctx, campaignId, "000000000000000001", "Coast", CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Harbor", "Cliffs"}, preview.Provinces)
+	assert.Equal(t, 20, preview.Development)
+
+	id, err := store.Claim(ctx, campaignId, "000000000000000001", "foo", "Shore", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	detail, err := store.DescribeClaim(ctx, campaignId, id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Harbor", "Cliffs"}, detail.Provinces)
+
+	conflicts, err := store.FindConflicts(ctx, campaignId, "000000000000000002", "Coast", CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(conflicts))
+
+	locations, err := store.Locate(ctx, campaignId, "Coastline")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, locations[0].Provinces)
+	nodes, err := store.TradeUpstream(ctx, campaignId, "Coast")
+	assert.ErrorIs(t, err, ErrNoTradeLinks)
+	assert.Empty(t, nodes)
+
+	available, err := store.Availability(ctx, campaignId, AvailabilityFilter{Type: CLAIM_TYPE_REGION})
+	assert.NoError(t, err)
+	assert.Equal(t, []ZoneAvailability{{Zone: Zone{Type: CLAIM_TYPE_REGION, Name: "Coastline"}, Provinces: 2, Development: 20}}, available)
+	matches, err := store.SearchZones(ctx, campaignId, "coastline", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, available[0], matches[0].ZoneAvailability)
+
+	// each other type can be counted too, and the claims follow
+	for _, typ := range ProvinceTypes[1:] {
+		assert.NoError(t, store.SetProvinceTypes(ctx, campaignId, "land", typ))
+		campaign, err := store.ActiveCampaign(ctx, TEST_GUILD_ID)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Land", typ}, campaign.ProvinceTypes)
+		assert.Contains(t, campaign.String(), "provinces: Land, "+typ)
+
+		detail, err := store.DescribeClaim(ctx, campaignId, id)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(detail.Provinces), typ)
+		conflicts, err := store.FindConflicts(ctx, campaignId, "000000000000000002", "Coast", CLAIM_TYPE_TRADE)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(conflicts), typ)
+		locations, err := store.Locate(ctx, campaignId, "Coastline")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, locations[0].Provinces, typ)
+		available, err := store.Availability(ctx, campaignId, AvailabilityFilter{Type: CLAIM_TYPE_REGION})
+		assert.NoError(t, err)
+		assert.Equal(t, AVAILABILITY_TAKEN, available[0].Status(), typ)
+		assert.Equal(t, 3, available[0].Provinces, typ)
+	}
+
+	// claims can't end up overlapping
+	assert.NoError(t, store.DeleteClaim(ctx, campaignId, id, "000000000000000001"))
+	assert.NoError(t, store.SetProvinceTypes(ctx, campaignId, "Land", "Lake"))
+	_, err = store.Claim(ctx, campaignId, "000000000000000002", "bar", "Mere", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+	assert.NoError(t, store.SetProvinceTypes(ctx, campaignId, "Land"))
+	_, err = store.Claim(ctx, campaignId, "000000000000000001", "foo", "Shore", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	assert.Error(t, store.SetProvinceTypes(ctx, campaignId, "Land", "Lake"))
+	campaign, err = store.ActiveCampaign(ctx, TEST_GUILD_ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Land"}, campaign.ProvinceTypes)
+
+	assert.Error(t, store.SetProvinceTypes(ctx, campaignId, "Swamp"))
+	assert.Error(t, store.SetProvinceTypes(ctx, campaignId))
+	assert.ErrorIs(t, store.SetProvinceTypes(ctx, 42, "Land"), ErrNoSuchCampaign)
+}
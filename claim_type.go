@@ -1,50 +1,114 @@
 package themis
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type ClaimType string
 
 func ClaimTypeFromString(s string) (ClaimType, error) {
 	switch s {
+	case CLAIM_TYPE_PROVINCE:
+		return CLAIM_TYPE_PROVINCE, nil
 	case CLAIM_TYPE_AREA:
 		return CLAIM_TYPE_AREA, nil
 	case CLAIM_TYPE_REGION:
 		return CLAIM_TYPE_REGION, nil
 	case CLAIM_TYPE_TRADE:
 		return CLAIM_TYPE_TRADE, nil
+	case CLAIM_TYPE_SUPERREGION:
+		return CLAIM_TYPE_SUPERREGION, nil
+	case CLAIM_TYPE_CONTINENT:
+		return CLAIM_TYPE_CONTINENT, nil
 	}
 	return "", fmt.Errorf("no claim type matching '%s'", s)
 }
 
 func (ct ClaimType) String() string {
 	switch ct {
+	case CLAIM_TYPE_PROVINCE:
+		return "Province"
 	case CLAIM_TYPE_AREA:
 		return "Area"
 	case CLAIM_TYPE_REGION:
 		return "Region"
 	case CLAIM_TYPE_TRADE:
 		return "Trade Node"
+	case CLAIM_TYPE_SUPERREGION:
+		return "Superregion"
+	case CLAIM_TYPE_CONTINENT:
+		return "Continent"
 	}
 	return ""
 }
 
 const (
-	CLAIM_TYPE_AREA   = "area"
-	CLAIM_TYPE_REGION = "region"
-	CLAIM_TYPE_TRADE  = "trade"
+	CLAIM_TYPE_PROVINCE    = "province"
+	CLAIM_TYPE_AREA        = "area"
+	CLAIM_TYPE_REGION      = "region"
+	CLAIM_TYPE_TRADE       = "trade"
+	CLAIM_TYPE_SUPERREGION = "superregion"
+	CLAIM_TYPE_CONTINENT   = "continent"
 )
 
+// ClaimTypes lists all the claim types, from the smallest zones to the largest
+// ones.
+var ClaimTypes = []ClaimType{
+	CLAIM_TYPE_PROVINCE,
+	CLAIM_TYPE_AREA,
+	CLAIM_TYPE_REGION,
+	CLAIM_TYPE_TRADE,
+	CLAIM_TYPE_SUPERREGION,
+	CLAIM_TYPE_CONTINENT,
+}
+
+// Province claims are stored using the province ID since province names are not
+// unique.
 var claimTypeToColumn = map[ClaimType]string{
-	CLAIM_TYPE_AREA:   "area",
-	CLAIM_TYPE_REGION: "region",
-	CLAIM_TYPE_TRADE:  "trade_node",
+	CLAIM_TYPE_PROVINCE:    "id",
+	CLAIM_TYPE_AREA:        "area",
+	CLAIM_TYPE_REGION:      "region",
+	CLAIM_TYPE_TRADE:       "trade_node",
+	CLAIM_TYPE_SUPERREGION: "superregion",
+	CLAIM_TYPE_CONTINENT:   "continent",
+}
+
+// claimTypeToIDColumn gives the column of the provinces view holding the ID
+// of the zone of each claim type, which claims store in zone_id.
+var claimTypeToIDColumn = map[ClaimType]string{
+	CLAIM_TYPE_PROVINCE:    "id",
+	CLAIM_TYPE_AREA:        "area_id",
+	CLAIM_TYPE_REGION:      "region_id",
+	CLAIM_TYPE_TRADE:       "trade_node_id",
+	CLAIM_TYPE_SUPERREGION: "superregion_id",
+	CLAIM_TYPE_CONTINENT:   "continent_id",
+}
+
+// Zone is a named set of provinces of a given claim type, e.g. the Gascony
+// area or the Bordeaux trade node.
+type Zone struct {
+	Type ClaimType `json:"claim_type"`
+	Name string    `json:"val"`
+}
+
+func (z Zone) String() string {
+	return fmt.Sprintf("%s %s", z.Type, z.Name)
+}
+
+// ClaimRequest is a zone to claim along with the zones carved out of it.
+type ClaimRequest struct {
+	Zone
+	Exclusions []Zone
 }
 
 type Claim struct {
-	ID     int
-	Player string
-	Name   string
-	Type   ClaimType
+	ID        int
+	Player    string
+	UserID    string
+	Name      string
+	Type      ClaimType
+	CreatedAt time.Time // zero for claims made before creation dates were recorded
 }
 
 func (c Claim) String() string {
@@ -0,0 +1,252 @@
+package themis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// ScriptNode is an entry of a Clausewitz script, the format of the Paradox
+// game files. Entries are either assignments, `key = value` or
+// `key = { ... }`, or bare values inside of a list, like the province IDs of
+// an area.
+type ScriptNode struct {
+	Key      string // empty for bare values
+	Operator string // =, <, >, <=, >= or != for assignments
+	Value    string // empty for blocks, unless they are tagged, like `rgb { 1 2 3 }`
+	Children []ScriptNode
+	IsBlock  bool
+	Line     int
+}
+
+// Child returns the first child assigned to the key, case-insensitively.
+func (n ScriptNode) Child(key string) (ScriptNode, bool) {
+	for _, c := range n.Children {
+		if strings.EqualFold(c.Key, key) {
+			return c, true
+		}
+	}
+	return ScriptNode{}, false
+}
+
+// Values returns the bare values of a block, e.g. the province IDs of an area.
+func (n ScriptNode) Values() []string {
+	values := make([]string, 0, len(n.Children))
+	for _, c := range n.Children {
+		if c.Key == "" && !c.IsBlock {
+			values = append(values, c.Value)
+		}
+	}
+	return values
+}
+
+// ParseScript parses a Clausewitz script. Comments start with # and run until
+// the end of the line, values can be quoted.
+func ParseScript(r io.Reader) ([]ScriptNode, error) {
+	lex := &scriptLexer{r: bufio.NewReader(r), line: 1}
+	return parseScriptBlock(lex, false)
+}
+
+func parseScriptBlock(lex *scriptLexer, nested bool) ([]ScriptNode, error) {
+	nodes := make([]ScriptNode, 0)
+	for {
+		tok, err := lex.next()
+		if err != nil {
+			return nil, err
+		}
+		switch tok.kind {
+		case tokenEOF:
+			if nested {
+				return nil, fmt.Errorf("line %d: unexpected end of file, missing }", tok.line)
+			}
+			return nodes, nil
+		case tokenClose:
+			if !nested {
+				return nil, fmt.Errorf("line %d: unexpected }", tok.line)
+			}
+			return nodes, nil
+		case tokenOpen:
+			children, err := parseScriptBlock(lex, true)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, ScriptNode{Children: children, IsBlock: true, Line: tok.line})
+			continue
+		case tokenOperator:
+			return nil, fmt.Errorf("line %d: unexpected %s", tok.line, tok.text)
+		}
+
+		op, err := lex.peek()
+		if err != nil {
+			return nil, err
+		}
+		if op.kind != tokenOperator {
+			nodes = append(nodes, ScriptNode{Value: tok.text, Line: tok.line})
+			continue
+		}
+		lex.next() //nolint:errcheck
+
+		node, err := parseScriptValue(lex)
+		if err != nil {
+			return nil, err
+		}
+		node.Key, node.Operator, node.Line = tok.text, op.text, tok.line
+		nodes = append(nodes, node)
+	}
+}
+
+// parseScriptValue parses the right-hand side of an assignment.
+func parseScriptValue(lex *scriptLexer) (ScriptNode, error) {
+	tok, err := lex.next()
+	if err != nil {
+		return ScriptNode{}, err
+	}
+	switch tok.kind {
+	case tokenOpen:
+		children, err := parseScriptBlock(lex, true)
+		if err != nil {
+			return ScriptNode{}, err
+		}
+		return ScriptNode{Children: children, IsBlock: true}, nil
+	case tokenWord, tokenQuoted:
+		// tagged blocks, like colors written as `hsv { 0.5 0.2 0.8 }`
+		if next, err := lex.peek(); err == nil && next.kind == tokenOpen && tok.kind == tokenWord {
+			lex.next() //nolint:errcheck
+			children, err := parseScriptBlock(lex, true)
+			if err != nil {
+				return ScriptNode{}, err
+			}
+			return ScriptNode{Value: tok.text, Children: children, IsBlock: true}, nil
+		}
+		return ScriptNode{Value: tok.text}, nil
+	default:
+		return ScriptNode{}, fmt.Errorf("line %d: expected a value, found %q", tok.line, tok.text)
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenQuoted
+	tokenOperator
+	tokenOpen
+	tokenClose
+)
+
+type scriptToken struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+type scriptLexer struct {
+	r      *bufio.Reader
+	line   int
+	peeked *scriptToken
+}
+
+func (l *scriptLexer) peek() (scriptToken, error) {
+	if l.peeked == nil {
+		tok, err := l.read()
+		if err != nil {
+			return scriptToken{}, err
+		}
+		l.peeked = &tok
+	}
+	return *l.peeked, nil
+}
+
+func (l *scriptLexer) next() (scriptToken, error) {
+	if l.peeked != nil {
+		tok := *l.peeked
+		l.peeked = nil
+		return tok, nil
+	}
+	return l.read()
+}
+
+func (l *scriptLexer) read() (scriptToken, error) {
+	for {
+		c, _, err := l.r.ReadRune()
+		if err == io.EOF {
+			return scriptToken{kind: tokenEOF, line: l.line}, nil
+		}
+		if err != nil {
+			return scriptToken{}, fmt.Errorf("failed to read script: %w", err)
+		}
+
+		switch {
+		case c == '\n':
+			l.line++
+		case unicode.IsSpace(c) || c == '\uFEFF':
+		case c == '#':
+			if _, err := l.r.ReadString('\n'); err != nil && err != io.EOF {
+				return scriptToken{}, fmt.Errorf("failed to read script: %w", err)
+			}
+			l.line++
+		case c == '{':
+			return scriptToken{kind: tokenOpen, text: "{", line: l.line}, nil
+		case c == '}':
+			return scriptToken{kind: tokenClose, text: "}", line: l.line}, nil
+		case c == '=' || c == '<' || c == '>' || c == '!':
+			op := string(c)
+			if next, _, err := l.r.ReadRune(); err == nil {
+				if next == '=' {
+					op += "="
+				} else {
+					l.r.UnreadRune() //nolint:errcheck
+				}
+			}
+			return scriptToken{kind: tokenOperator, text: op, line: l.line}, nil
+		case c == '"':
+			return l.readQuoted()
+		default:
+			l.r.UnreadRune() //nolint:errcheck
+			return l.readWord()
+		}
+	}
+}
+
+func (l *scriptLexer) readQuoted() (scriptToken, error) {
+	line := l.line
+	sb := strings.Builder{}
+	for {
+		c, _, err := l.r.ReadRune()
+		if err == io.EOF {
+			return scriptToken{}, fmt.Errorf("line %d: unterminated string", line)
+		}
+		if err != nil {
+			return scriptToken{}, fmt.Errorf("failed to read script: %w", err)
+		}
+		switch c {
+		case '"':
+			return scriptToken{kind: tokenQuoted, text: sb.String(), line: line}, nil
+		case '\n':
+			l.line++
+		}
+		sb.WriteRune(c)
+	}
+}
+
+func (l *scriptLexer) readWord() (scriptToken, error) {
+	sb := strings.Builder{}
+	for {
+		c, _, err := l.r.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return scriptToken{}, fmt.Errorf("failed to read script: %w", err)
+		}
+		if unicode.IsSpace(c) || strings.ContainsRune("{}=<>!#\"", c) {
+			l.r.UnreadRune() //nolint:errcheck
+			break
+		}
+		sb.WriteRune(c)
+	}
+	return scriptToken{kind: tokenWord, text: sb.String(), line: l.line}, nil
+}
@@ -0,0 +1,45 @@
+package themis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScript(t *testing.T) {
+	nodes, err := ParseScript(strings.NewReader(`# a comment
+gascony_area = { 173 174 # Labourd and Bordeaux
+	175 }
+venice = {
+	location = 1308
+	outgoing = { name = "ragusa" }
+	color = hsv { 0.5 0.2 0.8 }
+}
+limit = { development >= 10 tag != FRA }
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(nodes))
+
+	assert.Equal(t, "gascony_area", nodes[0].Key)
+	assert.Equal(t, []string{"173", "174", "175"}, nodes[0].Values())
+
+	location, ok := nodes[1].Child("location")
+	assert.True(t, ok)
+	assert.Equal(t, "1308", location.Value)
+	assert.Equal(t, 5, location.Line)
+	outgoing, _ := nodes[1].Child("outgoing")
+	name, _ := outgoing.Child("name")
+	assert.Equal(t, "ragusa", name.Value)
+	color, _ := nodes[1].Child("color")
+	assert.Equal(t, "hsv", color.Value)
+	assert.Equal(t, []string{"0.5", "0.2", "0.8"}, color.Values())
+
+	assert.Equal(t, ScriptNode{Key: "development", Operator: ">=", Value: "10", Line: 9}, nodes[2].Children[0])
+	assert.Equal(t, "!=", nodes[2].Children[1].Operator)
+
+	for _, broken := range []string{"a = { 1 2", "a = 1 }", `a = "unterminated`, "= 1", "a = }"} {
+		_, err := ParseScript(strings.NewReader(broken))
+		assert.Error(t, err, broken)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"go.wperron.io/themis"
+)
+
+const aliasesUsage = `usage: themis-server -db <file> aliases [-dataset <name>] <command>
+
+Manages the other names players can use for the zones of the dataset, on top
+of their names without diacritics. The dataset defaults to vanilla.
+
+commands:
+  list                          list the aliases of the dataset
+  add <type> <zone> <alias>     make alias another name of the zone, provinces
+                                can be given by ID
+  remove <type> <alias>         remove an alias`
+
+// runAliases implements the `aliases` subcommand.
+func runAliases(ctx context.Context, w io.Writer, conn string, args []string) error {
+	flags := flag.NewFlagSet("aliases", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dataset := flags.String("dataset", themis.DEFAULT_DATASET, "name of the dataset of the aliases")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return errors.New(aliasesUsage)
+	}
+	args = flags.Args()
+
+	store, err := themis.NewStore(conn)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer store.Close()
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		aliases, err := store.ListZoneAliases(ctx, *dataset)
+		if err != nil {
+			return err
+		}
+		for _, a := range aliases {
+			fmt.Fprintln(w, a)
+		}
+	case args[0] == "add" && len(args) == 4:
+		ct, err := themis.ClaimTypeFromString(args[1])
+		if err != nil {
+			return err
+		}
+		if err := store.AddZoneAlias(ctx, *dataset, themis.Zone{Type: ct, Name: args[2]}, args[3]); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "added alias %s of %s %s\n", args[3], ct, args[2])
+	case args[0] == "remove" && len(args) == 3:
+		ct, err := themis.ClaimTypeFromString(args[1])
+		if err != nil {
+			return err
+		}
+		if err := store.RemoveZoneAlias(ctx, *dataset, ct, args[2]); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "removed alias %s\n", args[2])
+	default:
+		return errors.New(aliasesUsage)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"go.wperron.io/themis"
+)
+
+const importUsage = `usage: themis-server -db <file> import-provinces [-dataset <name>] [-mapping <file.csv>] <file.csv|game directory>
+
+Replaces the provinces of the dataset with the content of the CSV file, or
+with the map of the EU4 installation or mod in the game directory, and lists
+the provinces and zones added, removed and renamed compared with the current
+data. The dataset is created if it doesn't exist, it defaults to vanilla.
+
+Claims on zones that disappeared are moved to the zone replacing them, when
+there is a single one, or flagged for their owner. The mapping file gives the
+replacement of zones explicitly, one per line: claim type, previous name, new
+name and optionally the claim type of the new zone.`
+
+const checkUsage = `usage: themis-server -db <file> check
+
+Lists the claims whose zone or exclusions don't exist in the province dataset
+of their campaign, and exits with an error if there are any.`
+
+const importAdjacenciesUsage = `usage: themis-server -db <file> import-adjacencies [-dataset <name>] <file.csv|game directory>
+
+Replaces the adjacencies of the provinces of the dataset with the pairs of
+neighbors of the CSV file, read from its from and to columns, or with the ones
+computed from the map of the EU4 installation or mod in the game directory.
+The dataset defaults to vanilla, its provinces must be imported first.`
+
+const importTradeNetworkUsage = `usage: themis-server -db <file> import-trade-network [-dataset <name>] <file.csv|game directory>
+
+Replaces the trade network of the dataset with the links of the CSV file, where
+trade flows from the node of the from column to the node of the to column, or
+with the one of the EU4 installation or mod in the game directory. The dataset
+defaults to vanilla, its provinces must be imported first.`
+
+// runImportProvinces implements the `import-provinces` subcommand.
+func runImportProvinces(ctx context.Context, w io.Writer, conn string, args []string) error {
+	flags := flag.NewFlagSet("import-provinces", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dataset := flags.String("dataset", themis.DEFAULT_DATASET, "name of the dataset to import the provinces into")
+	mappingFile := flags.String("mapping", "", "CSV file of zone mappings")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errors.New(importUsage)
+	}
+
+	provinces, err := loadProvinces(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	mappings := make([]themis.ZoneMapping, 0)
+	if *mappingFile != "" {
+		f, err := os.Open(*mappingFile)
+		if err != nil {
+			return fmt.Errorf("failed to open mapping file: %w", err)
+		}
+		mappings, err = themis.ParseZoneMappings(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	store, err := themis.NewStore(conn)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer store.Close()
+
+	report, err := store.ImportProvinces(ctx, *dataset, provinces, mappings...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, report)
+	return nil
+}
+
+// runCheck implements the `check` subcommand.
+func runCheck(ctx context.Context, w io.Writer, conn string, args []string) error {
+	if len(args) != 0 {
+		return errors.New(checkUsage)
+	}
+
+	store, err := themis.NewStore(conn)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer store.Close()
+
+	orphaned, err := store.CheckIntegrity(ctx)
+	if err != nil {
+		return err
+	}
+	for _, o := range orphaned {
+		fmt.Fprintln(w, o)
+	}
+	if len(orphaned) > 0 {
+		return fmt.Errorf("found %d orphaned claims", len(orphaned))
+	}
+	fmt.Fprintln(w, "no orphaned claims")
+	return nil
+}
+
+// runImportAdjacencies implements the `import-adjacencies` subcommand.
+func runImportAdjacencies(ctx context.Context, w io.Writer, conn string, args []string) error {
+	flags := flag.NewFlagSet("import-adjacencies", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dataset := flags.String("dataset", themis.DEFAULT_DATASET, "name of the dataset to import the adjacencies into")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errors.New(importAdjacenciesUsage)
+	}
+
+	adjacencies, err := loadAdjacencies(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	store, err := themis.NewStore(conn)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer store.Close()
+
+	imported, ignored, err := store.ImportAdjacencies(ctx, *dataset, adjacencies)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "imported %d adjacencies, ignored %d with unknown provinces\n", imported, ignored)
+	return nil
+}
+
+// runImportTradeNetwork implements the `import-trade-network` subcommand.
+func runImportTradeNetwork(ctx context.Context, w io.Writer, conn string, args []string) error {
+	flags := flag.NewFlagSet("import-trade-network", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dataset := flags.String("dataset", themis.DEFAULT_DATASET, "name of the dataset to import the trade network into")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errors.New(importTradeNetworkUsage)
+	}
+
+	links, err := loadTradeNetwork(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	store, err := themis.NewStore(conn)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer store.Close()
+
+	imported, ignored, err := store.ImportTradeLinks(ctx, *dataset, links)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "imported %d trade links, ignored %d with unknown nodes\n", imported, ignored)
+	return nil
+}
+
+// loadProvinces reads provinces from a CSV file, or from the game files if
+// source is a directory.
+func loadProvinces(source string) ([]themis.Province, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open provinces source: %w", err)
+	}
+	if info.IsDir() {
+		return themis.LoadGameFiles(os.DirFS(source))
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open provinces file: %w", err)
+	}
+	defer f.Close()
+	return themis.ParseProvincesCSV(f)
+}
+
+// loadAdjacencies reads adjacencies from a CSV file, or from the game files if
+// source is a directory.
+func loadAdjacencies(source string) ([]themis.Adjacency, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open adjacencies source: %w", err)
+	}
+	if info.IsDir() {
+		return themis.LoadAdjacencies(os.DirFS(source))
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open adjacencies file: %w", err)
+	}
+	defer f.Close()
+	return themis.ParseAdjacenciesCSV(f)
+}
+
+// loadTradeNetwork reads trade links from a CSV file, or from the game files if
+// source is a directory.
+func loadTradeNetwork(source string) ([]themis.TradeLink, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trade network source: %w", err)
+	}
+	if info.IsDir() {
+		return themis.LoadTradeNetwork(os.DirFS(source))
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trade network file: %w", err)
+	}
+	defer f.Close()
+	return themis.ParseTradeLinksCSV(f)
+}
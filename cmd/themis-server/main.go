@@ -9,8 +9,10 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -21,14 +23,14 @@ import (
 	"go.wperron.io/themis"
 )
 
-const (
-	CONN_STRING_PATTERN = "file:%s?cache=shared&mode=rw&_journal_mode=WAL"
-)
-
 var (
 	dbFile = flag.String("db", "", "SQlite database file path")
 
 	store *themis.Store
+
+	// minFreePercent is the lowest value of the min-free option of /available,
+	// the option takes a pointer.
+	minFreePercent = 0.0
 )
 
 type Handler func(s *discordgo.Session, i *discordgo.InteractionCreate)
@@ -44,7 +46,50 @@ func main() {
 		log.Fatal().Err(err).Msg("failed to touch database file")
 	}
 
-	connString := fmt.Sprintf(CONN_STRING_PATTERN, *dbFile)
+	connString := fmt.Sprintf(themis.CONN_STRING_PATTERN, *dbFile)
+
+	// subcommands report their results on stdout, and fail through the logger
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(ctx, os.Stdout, connString, flag.Args()[1:]); err != nil {
+			log.Fatal().Err(err).Msg("failed to run migrations")
+		}
+		return
+	}
+
+	if flag.Arg(0) == "import-provinces" {
+		if err := runImportProvinces(ctx, os.Stdout, connString, flag.Args()[1:]); err != nil {
+			log.Fatal().Err(err).Msg("failed to import provinces")
+		}
+		return
+	}
+
+	if flag.Arg(0) == "import-adjacencies" {
+		if err := runImportAdjacencies(ctx, os.Stdout, connString, flag.Args()[1:]); err != nil {
+			log.Fatal().Err(err).Msg("failed to import adjacencies")
+		}
+		return
+	}
+
+	if flag.Arg(0) == "import-trade-network" {
+		if err := runImportTradeNetwork(ctx, os.Stdout, connString, flag.Args()[1:]); err != nil {
+			log.Fatal().Err(err).Msg("failed to import trade network")
+		}
+		return
+	}
+
+	if flag.Arg(0) == "aliases" {
+		if err := runAliases(ctx, os.Stdout, connString, flag.Args()[1:]); err != nil {
+			log.Fatal().Err(err).Msg("failed to manage aliases")
+		}
+		return
+	}
+
+	if flag.Arg(0) == "check" {
+		if err := runCheck(ctx, os.Stdout, connString, flag.Args()[1:]); err != nil {
+			log.Fatal().Err(err).Msg("failed to check claims")
+		}
+		return
+	}
 
 	store, err = themis.NewStore(connString)
 	if err != nil {
@@ -62,9 +107,16 @@ func main() {
 		log.Fatal().Err(err).Msg("no app id found at DISCORD_APP_ID env var")
 	}
 
-	guildId, ok := os.LookupEnv("DISCORD_GUILD_ID")
-	if !ok {
-		log.Fatal().Err(err).Msg("no guild id found at DISCORD_GUILD_ID env var")
+	// Commands are registered in every guild the application is added to, the
+	// admin guild additionally gets the commands exposing the whole database.
+	adminGuildId := os.Getenv("DISCORD_GUILD_ID")
+	if adminGuildId != "" {
+		adopted, err := store.AdoptCampaigns(ctx, adminGuildId)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to adopt campaigns")
+		} else if adopted > 0 {
+			log.Info().Int("campaigns", adopted).Msg("adopted campaigns from before guilds were tracked")
+		}
 	}
 
 	discord, err := discordgo.New(fmt.Sprintf("Bot %s", authToken))
@@ -72,7 +124,7 @@ func main() {
 		log.Fatal().Err(err).Msg("failed to initialize discord session")
 	}
 
-	log.Info().Str("app_id", appId).Str("guild_id", guildId).Msg("connected to discord")
+	log.Info().Str("app_id", appId).Str("admin_guild_id", adminGuildId).Msg("connected to discord")
 
 	commands := []*discordgo.ApplicationCommand{
 		{
@@ -84,6 +136,13 @@ func main() {
 			Name:        "list-claims",
 			Description: "List current claims",
 			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "at",
+					Description: "list the claims as they were at this date instead (YYYY-MM-DD [HH:MM], UTC)",
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+			},
 		},
 		{
 			Name:        "claim",
@@ -92,13 +151,33 @@ func main() {
 			Options: []*discordgo.ApplicationCommandOption{
 				{
 					Name:        "claim-type",
-					Description: "one of `area`, `region` or `trade`",
+					Description: "one of `province`, `area`, `region`, `trade`, `superregion` or `continent`, inferred from the name if left out",
 					Type:        discordgo.ApplicationCommandOptionString,
-					Choices: []*discordgo.ApplicationCommandOptionChoice{
-						{Name: "Area", Value: themis.CLAIM_TYPE_AREA},
-						{Name: "Region", Value: themis.CLAIM_TYPE_REGION},
-						{Name: "Trade Node", Value: themis.CLAIM_TYPE_TRADE},
-					},
+					Choices:     claimTypeChoices(),
+				},
+				{
+					Name:         "name",
+					Description:  "the name of zone claimed",
+					Type:         discordgo.ApplicationCommandOptionString,
+					Autocomplete: true,
+				},
+				{
+					Name:        "except",
+					Description: "comma-separated zones left out of the claim, e.g. `area:Lower Rhineland, province:Paris`",
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+			},
+		},
+		{
+			Name:        "claim-preview",
+			Description: "See what a claim would hold, without taking it",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "claim-type",
+					Description: "one of `province`, `area`, `region`, `trade`, `superregion` or `continent`",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Choices:     claimTypeChoices(),
 				},
 				{
 					Name:         "name",
@@ -106,6 +185,24 @@ func main() {
 					Type:         discordgo.ApplicationCommandOptionString,
 					Autocomplete: true,
 				},
+				{
+					Name:        "except",
+					Description: "comma-separated zones left out of the claim, e.g. `area:Lower Rhineland, province:Paris`",
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+			},
+		},
+		{
+			Name:        "claim-bundle",
+			Description: "Take claims on several zones at once, either all of them or none",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "zones",
+					Description: "comma-separated zones to claim, e.g. `trade:Genoa, area:Liguria, province:Paris`",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    true,
+				},
 			},
 		},
 		{
@@ -132,11 +229,297 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:        "transfer-claim",
+			Description: "Hand over one of your claims to another player",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "id",
+					Description: "numerical ID for the claim",
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Required:    true,
+				},
+				{
+					Name:        "player",
+					Description: "the player receiving the claim",
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "flagged-claims",
+			Description: "List the claims to remap since the province data was updated",
+			Type:        discordgo.ChatApplicationCommand,
+		},
+		{
+			Name:        "remap-claim",
+			Description: "Move one of your claims to another zone, e.g. after a patch removed its zone",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "id",
+					Description: "numerical ID for the claim",
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Required:    true,
+				},
+				{
+					Name:        "claim-type",
+					Description: "one of `province`, `area`, `region`, `trade`, `superregion` or `continent`",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Choices:     claimTypeChoices(),
+					Required:    true,
+				},
+				{
+					Name:         "name",
+					Description:  "the name of the new zone",
+					Type:         discordgo.ApplicationCommandOptionString,
+					Autocomplete: true,
+					Required:     true,
+				},
+			},
+		},
+		{
+			Name:        "neighbors",
+			Description: "List the players bordering a claim",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "id",
+					Description: "numerical ID for the claim",
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "where",
+			Description: "Find which area, region, trade node and claims a province or zone belongs to",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:         "name",
+					Description:  "the name of a province, area, region, superregion, continent or trade node",
+					Type:         discordgo.ApplicationCommandOptionString,
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Name:        "trade-flow",
+			Description: "Show where the trade of a node comes from and goes to, and who holds those nodes",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:         "node",
+					Description:  "the name of the trade node",
+					Type:         discordgo.ApplicationCommandOptionString,
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Name:        "history",
+			Description: "Show the history of claims",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "player",
+					Description: "only show the claims held by this player",
+					Type:        discordgo.ApplicationCommandOptionUser,
+				},
+				{
+					Name:         "zone",
+					Description:  "only show the claims on this zone",
+					Type:         discordgo.ApplicationCommandOptionString,
+					Autocomplete: true,
+				},
+				{
+					Name:        "since",
+					Description: "only show changes made after this date (YYYY-MM-DD [HH:MM], UTC)",
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+				{
+					Name:        "until",
+					Description: "only show changes made before this date (YYYY-MM-DD [HH:MM], UTC)",
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+			},
+		},
+		{
+			Name:        "available",
+			Description: "List the zones with free provinces, whatever the claims holding the others",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "claim-type",
+					Description: "the type of the zones",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    true,
+					Choices:     claimTypeChoices(),
+				},
+				{
+					Name:         "continent",
+					Description:  "only show the zones in this continent",
+					Type:         discordgo.ApplicationCommandOptionString,
+					Autocomplete: true,
+				},
+				{
+					Name:        "min-free",
+					Description: "only show the zones with at least this percentage of free provinces, defaults to 100",
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					MinValue:    &minFreePercent,
+					MaxValue:    100,
+				},
+			},
+		},
 		{
 			Name:        "flush",
-			Description: "Remove all claims from the database and prepare for the next game!",
+			Description: "Archive and remove all claims and prepare for the next game!",
+			Type:        discordgo.ChatApplicationCommand,
+		},
+		{
+			Name:        "archives",
+			Description: "Browse the claims archived on flush",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "list",
+					Description: "List the archived games",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+				},
+				{
+					Name:        "describe",
+					Description: "List the claims of an archived game",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "id",
+							Description: "numerical ID for the archive",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Required:    true,
+						},
+					},
+				},
+				{
+					Name:        "restore",
+					Description: "Put the claims of an archived game back on the board, which must be empty",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "id",
+							Description: "numerical ID for the archive",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:        "campaign",
+			Description: "Manage the server's campaigns",
 			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "create",
+					Description: "Start a new campaign and make it the active one",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "name",
+							Description: "the name of the campaign",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    true,
+						},
+						{
+							Name:         "dataset",
+							Description:  "the province dataset of the game, vanilla unless playing a mod",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Name:        "dataset",
+					Description: "Change the province dataset of the active campaign, the board must be empty",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "name",
+							Description:  "the name of the dataset",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Name:        "contiguous",
+					Description: "Require new claims of the active campaign to touch the player's other claims",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "enabled",
+							Description: "whether claims must be contiguous",
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Required:    true,
+						},
+					},
+				},
+				{
+					Name:        "province-types",
+					Description: "Choose the types of the provinces claims of the active campaign hold",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "types",
+							Description: "comma separated province types, e.g. Land, Wasteland. Only Land by default",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    true,
+						},
+					},
+				},
+				{
+					Name:        "switch",
+					Description: "Make another campaign the active one",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "name",
+							Description:  "the name of the campaign",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Name:        "archive",
+					Description: "Archive a campaign, keeping its claims",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "name",
+							Description:  "the name of the campaign",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Name:        "list",
+					Description: "List all campaigns",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+				},
+			},
 		},
+	}
+	adminCommands := []*discordgo.ApplicationCommand{
 		{
 			Name:        "query",
 			Description: "Run a raw SQL query on the database",
@@ -166,7 +549,12 @@ func main() {
 				}
 			}
 
-			claimCount, uniquePlayers, err := store.CountClaims(ctx)
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
+			claimCount, uniquePlayers, err := store.CountClaims(ctx, campaign.ID)
 			if err != nil {
 				log.Error().Err(err).Msg("failed to count claims")
 				err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -183,7 +571,7 @@ func main() {
 			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 				Type: discordgo.InteractionResponseChannelMessageWithSource,
 				Data: &discordgo.InteractionResponseData{
-					Content: fmt.Sprintf("Server has been up for %s, has %d claims from %d unique players", uptime, claimCount, uniquePlayers),
+					Content: fmt.Sprintf("Server has been up for %s, campaign %s has %d claims from %d unique players", uptime, campaign.Name, claimCount, uniquePlayers),
 				},
 			})
 			if err != nil {
@@ -191,7 +579,36 @@ func main() {
 			}
 		},
 		"list-claims": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			claims, err := store.ListClaims(ctx)
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
+			var (
+				claims []themis.Claim
+				err    error
+				header string
+			)
+			if opts := i.ApplicationCommandData().Options; len(opts) > 0 {
+				at, perr := parseDate(opts[0].StringValue())
+				if perr != nil {
+					err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+						Type: discordgo.InteractionResponseChannelMessageWithSource,
+						Data: &discordgo.InteractionResponseData{
+							Content: fmt.Sprintf("Invalid date: %s", perr),
+						},
+					})
+					if err != nil {
+						log.Error().Err(err).Msg("failed to respond to interaction")
+					}
+					return
+				}
+				claims, err = store.ListClaimsAt(ctx, campaign.ID, at)
+				header = fmt.Sprintf("There were %d claims in %s on %s:\n", len(claims), campaign.Name, at.Format("2006-01-02 15:04"))
+			} else {
+				claims, err = store.ListClaims(ctx, campaign.ID)
+				header = fmt.Sprintf("There are currently %d claims in %s:\n", len(claims), campaign.Name)
+			}
 			if err != nil {
 				log.Error().Err(err).Msg("failed to list claims")
 				err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -203,10 +620,11 @@ func main() {
 				if err != nil {
 					log.Error().Err(err).Msg("failed to respond to interaction")
 				}
+				return
 			}
 
 			sb := strings.Builder{}
-			sb.WriteString(fmt.Sprintf("There are currently %d claims:\n", len(claims)))
+			sb.WriteString(header)
 			sb.WriteString("```\n")
 			sb.WriteString(formatClaimsTable(claims))
 			sb.WriteString("```\n")
@@ -227,35 +645,10 @@ func main() {
 				return
 			}
 
-			opts := i.ApplicationCommandData().Options
-			if len(opts) != 2 {
-				err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-					Type: discordgo.InteractionResponseChannelMessageWithSource,
-					Data: &discordgo.InteractionResponseData{
-						Content: "`claim-type` and `name` are mandatory parameters",
-					},
-				})
-				if err != nil {
-					log.Error().Err(err).Msg("failed to respond to interaction")
-				}
-				return
-			}
-
-			claimType, err := themis.ClaimTypeFromString(opts[0].StringValue())
-			if err != nil {
-				log.Error().Err(err).Str("claim_type", opts[0].StringValue()).Msg("failed to parse claim")
-				err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-					Type: discordgo.InteractionResponseChannelMessageWithSource,
-					Data: &discordgo.InteractionResponseData{
-						Content: "You can only take claims of types `area`, `region` or `trade`",
-					},
-				})
-				if err != nil {
-					log.Error().Err(err).Msg("failed to respond to interaction")
-				}
+			req, ok := claimRequestFromOptions(s, i)
+			if !ok {
 				return
 			}
-			name := opts[1].StringValue()
 
 			player := i.Member.Nick
 			if player == "" {
@@ -264,34 +657,53 @@ func main() {
 
 			userId := i.Member.User.ID
 
-			_, err = store.Claim(ctx, userId, player, name, claimType)
-			if err != nil {
-				conflict, ok := err.(themis.ErrConflict)
-				if ok {
-					sb := strings.Builder{}
-					sb.WriteString("Some provinces are already claimed:\n```\n")
-					for _, c := range conflict.Conflicts {
-						sb.WriteString(fmt.Sprintf("  - %s\n", c))
-					}
-					sb.WriteString("```\n")
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
 
-					err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-						Type: discordgo.InteractionResponseChannelMessageWithSource,
-						Data: &discordgo.InteractionResponseData{
-							Content: sb.String(),
-						},
-					})
-					if err != nil {
-						log.Error().Err(err).Msg("failed to respond to interaction")
-					}
-					return
+			if req.Type == "" && !inferClaimType(ctx, s, i, campaign.ID, &req) {
+				return
+			}
+
+			takeClaim(ctx, s, i, campaign.ID, userId, player, req)
+		},
+		"claim-preview": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+				handleClaimAutocomplete(ctx, store, s, i)
+				return
+			}
+
+			req, ok := claimRequestFromOptions(s, i)
+			if !ok {
+				return
+			}
+
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
+			if req.Type == "" {
+				err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseChannelMessageWithSource,
+					Data: &discordgo.InteractionResponseData{
+						Content: "`claim-type` and `name` are mandatory parameters",
+					},
+				})
+				if err != nil {
+					log.Error().Err(err).Msg("failed to respond to interaction")
 				}
+				return
+			}
 
-				log.Error().Err(err).Msg("failed to acquire claim")
+			preview, err := store.PreviewClaim(ctx, campaign.ID, i.Member.User.ID, req.Name, req.Type, req.Exclusions...)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to preview claim")
 				err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 					Type: discordgo.InteractionResponseChannelMessageWithSource,
 					Data: &discordgo.InteractionResponseData{
-						Content: "failed to acquire claim :(",
+						Content: fmt.Sprintf("Failed to preview claim: %s", err),
 					},
 				})
 				if err != nil {
@@ -303,7 +715,78 @@ func main() {
 			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 				Type: discordgo.InteractionResponseChannelMessageWithSource,
 				Data: &discordgo.InteractionResponseData{
-					Content: fmt.Sprintf("Claimed %s for %s!", name, player),
+					Content: formatPreview(preview),
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+		},
+		"claim-bundle": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			zones, err := parseZones(i.ApplicationCommandData().Options[0].StringValue())
+			if err == nil && len(zones) == 0 {
+				err = fmt.Errorf("no zones given")
+			}
+			if err != nil {
+				err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseChannelMessageWithSource,
+					Data: &discordgo.InteractionResponseData{
+						Content: fmt.Sprintf("Invalid zones: %s", err),
+					},
+				})
+				if err != nil {
+					log.Error().Err(err).Msg("failed to respond to interaction")
+				}
+				return
+			}
+
+			player := i.Member.Nick
+			if player == "" {
+				player = i.Member.User.Username
+			}
+
+			userId := i.Member.User.ID
+
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
+			requests := make([]themis.ClaimRequest, 0, len(zones))
+			names := make([]string, 0, len(zones))
+			for _, z := range zones {
+				requests = append(requests, themis.ClaimRequest{Zone: z})
+				names = append(names, z.Name)
+			}
+
+			_, err = store.ClaimMany(ctx, campaign.ID, userId, player, requests...)
+			if err != nil {
+				content := fmt.Sprintf("Failed to acquire claims: %s", err)
+				var notContiguous themis.ErrNotContiguous
+				if conflict, ok := err.(themis.ErrConflict); ok {
+					content = formatConflicts(conflict.Conflicts)
+				} else if errors.As(err, &notContiguous) {
+					content = fmt.Sprintf("Claims must be contiguous in this campaign, %s", notContiguous)
+				} else {
+					log.Error().Err(err).Msg("failed to acquire claims")
+				}
+
+				err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseChannelMessageWithSource,
+					Data: &discordgo.InteractionResponseData{
+						Content: content,
+					},
+				})
+				if err != nil {
+					log.Error().Err(err).Msg("failed to respond to interaction")
+				}
+				return
+			}
+
+			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: fmt.Sprintf("Claimed %s for %s!", strings.Join(names, ", "), player),
 				},
 			})
 			if err != nil {
@@ -311,8 +794,13 @@ func main() {
 			}
 		},
 		"describe-claim": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
 			id := i.ApplicationCommandData().Options[0]
-			detail, err := store.DescribeClaim(ctx, int(id.IntValue()))
+			detail, err := store.DescribeClaim(ctx, campaign.ID, int(id.IntValue()))
 			if err != nil {
 				log.Error().Err(err).Msg("failed to describe claim")
 				err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -328,9 +816,15 @@ func main() {
 
 			sb := strings.Builder{}
 			sb.WriteString(fmt.Sprintf("#%d %s %s (%s)\n", detail.ID, detail.Name, detail.Type, detail.Player))
+			if detail.Orphaned {
+				sb.WriteString(fmt.Sprintf("%s %s no longer exists, use /remap-claim to move the claim to another zone\n", detail.Type, detail.Name))
+			}
 			for _, p := range detail.Provinces {
 				sb.WriteString(fmt.Sprintf(" - %s\n", p))
 			}
+			for _, ex := range detail.Exclusions {
+				sb.WriteString(fmt.Sprintf("except %s\n", ex))
+			}
 
 			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 				Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -343,9 +837,14 @@ func main() {
 			}
 		},
 		"delete-claim": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
 			id := i.ApplicationCommandData().Options[0]
 			userId := i.Member.User.ID
-			err := store.DeleteClaim(ctx, int(id.IntValue()), userId)
+			err := store.DeleteClaim(ctx, campaign.ID, int(id.IntValue()), userId)
 			if err != nil {
 				msg := "Oops, something went wrong :( blame @wperron"
 				if errors.Is(err, themis.ErrNoSuchClaim) {
@@ -373,6 +872,351 @@ func main() {
 				log.Error().Err(err).Msg("failed to respond to interaction")
 			}
 		},
+		"transfer-claim": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
+			opts := i.ApplicationCommandData().Options
+			id := opts[0].IntValue()
+			to := opts[1].UserValue(s)
+
+			toPlayer := to.Username
+			if member, err := s.State.Member(i.GuildID, to.ID); err == nil && member.Nick != "" {
+				toPlayer = member.Nick
+			}
+
+			msg := fmt.Sprintf("Claim #%d now belongs to %s", id, toPlayer)
+			err := store.TransferClaim(ctx, campaign.ID, int(id), i.Member.User.ID, to.ID, toPlayer)
+			if err != nil {
+				msg = "Oops, something went wrong :( blame @wperron"
+				if errors.Is(err, themis.ErrNoSuchClaim) {
+					msg = fmt.Sprintf("Claim #%d not found for %s", id, i.Member.Nick)
+				}
+				log.Error().Err(err).Msg("failed to transfer claim")
+			}
+
+			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: msg,
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+		},
+		"flagged-claims": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
+			msg := "No claims to remap, all good!"
+			flagged, err := store.ListFlaggedClaims(ctx, campaign.ID)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to list flagged claims")
+				msg = "Oops, something went wrong! :("
+			} else if len(flagged) > 0 {
+				sb := strings.Builder{}
+				sb.WriteString("These claims point to zones that no longer exist, their owners can move them with /remap-claim:\n```\n")
+				for _, f := range flagged {
+					sb.WriteString(fmt.Sprintf("%s\n", f))
+				}
+				sb.WriteString("```\n")
+				msg = sb.String()
+			}
+
+			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: msg,
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+		},
+		"remap-claim": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+				handleClaimAutocomplete(ctx, store, s, i)
+				return
+			}
+
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
+			opts := i.ApplicationCommandData().Options
+			id := opts[0].IntValue()
+			claimType, err := themis.ClaimTypeFromString(opts[1].StringValue())
+			if err != nil {
+				log.Error().Err(err).Str("claim_type", opts[1].StringValue()).Msg("failed to parse claim type")
+			}
+
+			var msg string
+			if err == nil {
+				var dropped []themis.Zone
+				dropped, err = store.RemapClaim(ctx, campaign.ID, int(id), i.Member.User.ID, themis.Zone{Type: claimType, Name: opts[2].StringValue()})
+				msg = fmt.Sprintf("Claim #%d now covers %s %s", id, claimType, opts[2].StringValue())
+				for _, ex := range dropped {
+					msg += fmt.Sprintf("\n%s is not part of it, it is no longer excluded", ex)
+				}
+			}
+			if err != nil {
+				msg = "Oops, something went wrong :( blame @wperron"
+				var conflict themis.ErrConflict
+				switch {
+				case errors.Is(err, themis.ErrNoSuchClaim):
+					msg = fmt.Sprintf("Claim #%d not found for %s", id, i.Member.Nick)
+				case errors.As(err, &conflict):
+					msg = formatConflicts(conflict.Conflicts)
+				}
+				log.Error().Err(err).Msg("failed to remap claim")
+			}
+
+			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: msg,
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+		},
+		"neighbors": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
+			id := i.ApplicationCommandData().Options[0].IntValue()
+			msg := fmt.Sprintf("No other player borders claim #%d", id)
+			neighbors, err := store.ClaimNeighbors(ctx, campaign.ID, int(id))
+			switch {
+			case errors.Is(err, themis.ErrNoSuchClaim):
+				msg = fmt.Sprintf("Claim #%d not found", id)
+			case errors.Is(err, themis.ErrNoAdjacencies):
+				msg = fmt.Sprintf("The %s dataset has no adjacency data", campaign.Dataset)
+			case err != nil:
+				log.Error().Err(err).Msg("failed to list neighbors")
+				msg = "Oops, something went wrong! :("
+			case len(neighbors) > 0:
+				sb := strings.Builder{}
+				sb.WriteString(fmt.Sprintf("Claim #%d borders:\n```\n", id))
+				for _, n := range neighbors {
+					sb.WriteString(fmt.Sprintf("%s\n", n))
+				}
+				sb.WriteString("```\n")
+				msg = sb.String()
+			}
+
+			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: msg,
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+		},
+		"where": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+				handleZoneAutocomplete(ctx, store, s, i)
+				return
+			}
+
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
+			name := i.ApplicationCommandData().Options[0].StringValue()
+			msg := fmt.Sprintf("Nothing is named %s", name)
+			locations, err := store.Locate(ctx, campaign.ID, name)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to locate zone")
+				msg = "Oops, something went wrong! :("
+			} else if len(locations) > 0 {
+				sb := strings.Builder{}
+				sb.WriteString("```\n")
+				for _, l := range locations {
+					sb.WriteString(fmt.Sprintf("%s\n", l))
+				}
+				sb.WriteString("```\n")
+				msg = sb.String()
+			}
+
+			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: msg,
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+		},
+		"trade-flow": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+				handleZoneAutocomplete(ctx, store, s, i, themis.CLAIM_TYPE_TRADE)
+				return
+			}
+
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
+			name := i.ApplicationCommandData().Options[0].StringValue()
+			node, err := store.DescribeTradeNode(ctx, campaign.ID, name)
+			var upstream, downstream []themis.TradeNode
+			if err == nil {
+				upstream, err = store.TradeUpstream(ctx, campaign.ID, name)
+			}
+			if err == nil {
+				downstream, err = store.TradeDownstream(ctx, campaign.ID, name)
+			}
+
+			var msg string
+			switch {
+			case errors.Is(err, themis.ErrNoTradeLinks):
+				msg = fmt.Sprintf("The %s dataset has no trade network data", campaign.Dataset)
+			case err != nil:
+				log.Error().Err(err).Msg("failed to get trade flow")
+				msg = fmt.Sprintf("Failed to get the trade flow of %s: %s", name, err)
+			default:
+				sb := strings.Builder{}
+				sb.WriteString(fmt.Sprintf("```\n%s\n", node))
+				sb.WriteString("\nUpstream, trade flows from:\n")
+				for _, n := range upstream {
+					sb.WriteString(fmt.Sprintf(" - %s\n", n))
+				}
+				if len(upstream) == 0 {
+					sb.WriteString(" nothing, it's a source node\n")
+				}
+				sb.WriteString("\nDownstream, trade flows to:\n")
+				for _, n := range downstream {
+					sb.WriteString(fmt.Sprintf(" - %s\n", n))
+				}
+				if len(downstream) == 0 {
+					sb.WriteString(" nothing, it's an end node\n")
+				}
+				sb.WriteString("```\n")
+				msg = sb.String()
+			}
+
+			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: msg,
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+		},
+		"history": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+				handleZoneAutocomplete(ctx, store, s, i)
+				return
+			}
+
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
+			filter := themis.HistoryFilter{}
+			var err error
+			for _, opt := range i.ApplicationCommandData().Options {
+				switch opt.Name {
+				case "player":
+					filter.UserID = opt.UserValue(nil).ID
+				case "zone":
+					filter.Zone = opt.StringValue()
+				case "since":
+					filter.Since, err = parseDate(opt.StringValue())
+				case "until":
+					filter.Until, err = parseDate(opt.StringValue())
+				}
+				if err != nil {
+					break
+				}
+			}
+
+			var msg string
+			if err != nil {
+				msg = fmt.Sprintf("Invalid date: %s", err)
+			} else {
+				events, err := store.ClaimHistory(ctx, campaign.ID, filter)
+				if err != nil {
+					log.Error().Err(err).Msg("failed to get claim history")
+					msg = "Oops, something went wrong! :("
+				} else {
+					msg = formatHistory(events)
+				}
+			}
+
+			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: msg,
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+		},
+		"available": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+				handleZoneAutocomplete(ctx, store, s, i, themis.CLAIM_TYPE_CONTINENT)
+				return
+			}
+
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
+			filter := themis.AvailabilityFilter{MinFreePercent: 100}
+			for _, opt := range i.ApplicationCommandData().Options {
+				switch opt.Name {
+				case "claim-type":
+					filter.Type = themis.ClaimType(opt.StringValue())
+				case "continent":
+					filter.Continent = opt.StringValue()
+				case "min-free":
+					filter.MinFreePercent = int(opt.IntValue())
+				}
+			}
+
+			var msg string
+			zones, err := store.Availability(ctx, campaign.ID, filter)
+			switch {
+			case errors.As(err, &themis.ErrUnknownZone{}):
+				msg = err.Error()
+			case err != nil:
+				log.Error().Err(err).Msg("failed to list availability")
+				msg = "Oops, something went wrong! :("
+			default:
+				msg = formatAvailability(zones)
+			}
+
+			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: msg,
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+		},
 		"flush": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
 			if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 				Type: discordgo.InteractionResponseModal,
@@ -384,7 +1228,7 @@ func main() {
 							Components: []discordgo.MessageComponent{
 								discordgo.TextInput{
 									CustomID:    "confirmation",
-									Label:       "Delete all claims permanently? [y/N]",
+									Label:       "Archive and remove all claims? [y/N]",
 									Style:       discordgo.TextInputShort,
 									Placeholder: "",
 									Value:       "",
@@ -394,13 +1238,229 @@ func main() {
 								},
 							},
 						},
+						discordgo.ActionsRow{
+							Components: []discordgo.MessageComponent{
+								discordgo.TextInput{
+									CustomID:    "archive-name",
+									Label:       "Name of the archive",
+									Style:       discordgo.TextInputShort,
+									Placeholder: "defaults to the campaign name and today's date",
+									Value:       "",
+									Required:    false,
+									MaxLength:   100,
+								},
+							},
+						},
 					},
 				},
 			}); err != nil {
 				log.Error().Err(err).Msg("failed to respond to interaction")
 			}
 		},
+		"archives": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			campaign, ok := activeCampaign(ctx, s, i)
+			if !ok {
+				return
+			}
+
+			sub := i.ApplicationCommandData().Options[0]
+			var msg string
+			switch sub.Name {
+			case "list":
+				archives, err := store.ListArchives(ctx, campaign.ID)
+				if err != nil {
+					log.Error().Err(err).Msg("failed to list archives")
+					msg = "Oops, something went wrong! :("
+					break
+				}
+
+				sb := strings.Builder{}
+				sb.WriteString(fmt.Sprintf("There are %d archived games in %s:\n", len(archives), campaign.Name))
+				for _, a := range archives {
+					sb.WriteString(fmt.Sprintf(" - %s\n", a))
+				}
+				msg = sb.String()
+			case "describe":
+				id := sub.Options[0].IntValue()
+				archive, err := store.DescribeArchive(ctx, campaign.ID, int(id))
+				if err != nil {
+					msg = "Oops, something went wrong! :("
+					if errors.Is(err, themis.ErrNoSuchArchive) {
+						msg = fmt.Sprintf("Archive #%d not found", id)
+					} else {
+						log.Error().Err(err).Msg("failed to describe archive")
+					}
+					break
+				}
+
+				sb := strings.Builder{}
+				sb.WriteString(fmt.Sprintf("%s\n", archive))
+				sb.WriteString("```\n")
+				sb.WriteString(formatClaimsTable(archive.Claims))
+				sb.WriteString("```\n")
+				msg = sb.String()
+			case "restore":
+				id := sub.Options[0].IntValue()
+				err := store.RestoreArchive(ctx, campaign.ID, int(id), i.Member.User.ID)
+				switch {
+				case err == nil:
+					msg = fmt.Sprintf("Restored archive #%d", id)
+				case errors.Is(err, themis.ErrNoSuchArchive):
+					msg = fmt.Sprintf("Archive #%d not found", id)
+				case errors.Is(err, themis.ErrBoardNotEmpty):
+					msg = "There are claims on the board, use `/flush` to archive them first"
+				default:
+					log.Error().Err(err).Msg("failed to restore archive")
+					msg = "Oops, something went wrong! :("
+				}
+			}
+
+			err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: msg,
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+		},
+		"campaign": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+				handleCampaignAutocomplete(ctx, store, s, i)
+				return
+			}
+
+			sub := i.ApplicationCommandData().Options[0]
+			var msg string
+			switch sub.Name {
+			case "create":
+				name := sub.Options[0].StringValue()
+				dataset := themis.DEFAULT_DATASET
+				if len(sub.Options) > 1 {
+					dataset = sub.Options[1].StringValue()
+				}
+				if !datasetExists(ctx, dataset) {
+					msg = fmt.Sprintf("No dataset named %s", dataset)
+					break
+				}
+
+				id, err := store.CreateCampaign(ctx, i.GuildID, name)
+				if err == nil && dataset != themis.DEFAULT_DATASET {
+					err = store.SetCampaignDataset(ctx, id, dataset)
+				}
+				msg = fmt.Sprintf("Started campaign %s, good luck and have fun!", name)
+				if err != nil {
+					log.Error().Err(err).Msg("failed to create campaign")
+					msg = fmt.Sprintf("Failed to create campaign %s, is the name already taken?", name)
+				}
+			case "dataset":
+				campaign, ok := activeCampaign(ctx, s, i)
+				if !ok {
+					return
+				}
+
+				dataset := sub.Options[0].StringValue()
+				err := store.SetCampaignDataset(ctx, campaign.ID, dataset)
+				switch {
+				case err == nil:
+					msg = fmt.Sprintf("Campaign %s now uses the %s dataset", campaign.Name, dataset)
+				case errors.Is(err, themis.ErrNoSuchDataset):
+					msg = fmt.Sprintf("No dataset named %s", dataset)
+				case errors.Is(err, themis.ErrBoardNotEmpty):
+					msg = fmt.Sprintf("Campaign %s already has claims, flush the board before changing its dataset", campaign.Name)
+				default:
+					log.Error().Err(err).Msg("failed to change campaign dataset")
+					msg = "Oops, something went wrong! :("
+				}
+			case "contiguous":
+				campaign, ok := activeCampaign(ctx, s, i)
+				if !ok {
+					return
+				}
+
+				enabled := sub.Options[0].BoolValue()
+				err := store.SetContiguousClaims(ctx, campaign.ID, enabled)
+				switch {
+				case err == nil && enabled:
+					msg = fmt.Sprintf("New claims of campaign %s must now touch the player's other claims", campaign.Name)
+				case err == nil:
+					msg = fmt.Sprintf("Claims of campaign %s no longer need to be contiguous", campaign.Name)
+				case errors.Is(err, themis.ErrNoAdjacencies):
+					msg = fmt.Sprintf("The %s dataset has no adjacency data, import it before requiring contiguous claims", campaign.Dataset)
+				default:
+					log.Error().Err(err).Msg("failed to change contiguous claims rule")
+					msg = "Oops, something went wrong! :("
+				}
+			case "province-types":
+				campaign, ok := activeCampaign(ctx, s, i)
+				if !ok {
+					return
+				}
+
+				types := strings.Split(sub.Options[0].StringValue(), ",")
+				if err := store.SetProvinceTypes(ctx, campaign.ID, types...); err != nil {
+					log.Error().Err(err).Msg("failed to change province types")
+					msg = fmt.Sprintf("Failed to change the province types of campaign %s: %s", campaign.Name, err)
+				} else {
+					msg = fmt.Sprintf("Claims of campaign %s now hold %s provinces", campaign.Name, sub.Options[0].StringValue())
+				}
+			case "switch", "archive":
+				name := sub.Options[0].StringValue()
+				campaign, err := store.FindCampaign(ctx, i.GuildID, name)
+				if err == nil {
+					if sub.Name == "switch" {
+						err = store.SwitchCampaign(ctx, i.GuildID, campaign.ID)
+					} else {
+						err = store.ArchiveCampaign(ctx, i.GuildID, campaign.ID)
+					}
+				}
+
+				switch {
+				case err == nil && sub.Name == "switch":
+					msg = fmt.Sprintf("Switched to campaign %s", name)
+				case err == nil:
+					msg = fmt.Sprintf("Archived campaign %s", name)
+				case errors.Is(err, themis.ErrNoSuchCampaign):
+					msg = fmt.Sprintf("No campaign named %s", name)
+				case errors.Is(err, themis.ErrCampaignArchived):
+					msg = fmt.Sprintf("Campaign %s is archived", name)
+				default:
+					log.Error().Err(err).Str("subcommand", sub.Name).Msg("failed to update campaign")
+					msg = "Oops, something went wrong! :("
+				}
+			case "list":
+				campaigns, err := store.ListCampaigns(ctx, i.GuildID)
+				if err != nil {
+					log.Error().Err(err).Msg("failed to list campaigns")
+					msg = "Oops, something went wrong! :("
+					break
+				}
+
+				sb := strings.Builder{}
+				sb.WriteString(fmt.Sprintf("There are %d campaigns:\n", len(campaigns)))
+				for _, c := range campaigns {
+					sb.WriteString(fmt.Sprintf(" - %s\n", c))
+				}
+				msg = sb.String()
+			}
+
+			err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: msg,
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+		},
 		"query": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			if i.GuildID != adminGuildId {
+				// the query command can read every guild's data
+				return
+			}
+
 			roDB, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?cache=private&mode=ro", *dbFile))
 			if err != nil {
 				log.Error().Err(err).Msg("failed to open read-only copy of databse")
@@ -438,22 +1498,46 @@ func main() {
 
 	registerHandlers(discord, handlers)
 
-	err = discord.Open()
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed to open discord websocket")
-	}
-	defer discord.Close()
+	registered := &registry{commands: make(map[string][]*discordgo.ApplicationCommand)}
+	discord.AddHandler(func(s *discordgo.Session, g *discordgo.GuildCreate) {
+		if err := store.AddGuild(ctx, g.ID, g.Name); err != nil {
+			log.Error().Err(err).Str("guild_id", g.ID).Msg("failed to add guild")
+		}
 
-	registeredCommands := make([]*discordgo.ApplicationCommand, len(commands))
-	for i, c := range commands {
-		command, err := discord.ApplicationCommandCreate(appId, guildId, c)
+		cmds := commands
+		if g.ID == adminGuildId {
+			cmds = append(cmds[:len(cmds):len(cmds)], adminCommands...)
+		}
+
+		created, err := s.ApplicationCommandBulkOverwrite(appId, g.ID, cmds)
 		if err != nil {
-			log.Fatal().Err(err).Msg("failed to register command")
+			log.Error().Err(err).Str("guild_id", g.ID).Msg("failed to register commands")
+			return
+		}
+		registered.set(g.ID, created)
+
+		log.Info().Str("guild_id", g.ID).Int("count", len(created)).Msg("registered commands")
+	})
+	discord.AddHandler(func(s *discordgo.Session, g *discordgo.GuildDelete) {
+		// Guild data is only purged when the application is removed from the
+		// guild, not when the guild is missing from Ready or unavailable
+		// because of an outage: it comes back on its own.
+		if g.Unavailable {
+			return
 		}
-		registeredCommands[i] = command
+
+		registered.remove(g.ID)
+		if err := store.RemoveGuild(ctx, g.ID); err != nil {
+			log.Error().Err(err).Str("guild_id", g.ID).Msg("failed to remove guild")
+		}
+		log.Info().Str("guild_id", g.ID).Msg("removed from guild")
+	})
+
+	err = discord.Open()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to open discord websocket")
 	}
-
-	log.Info().Int("count", len(registeredCommands)).Msg("registered commands")
+	defer discord.Close()
 
 	go func() {
 		if err := serve(":8080"); err != nil {
@@ -465,16 +1549,47 @@ func main() {
 	<-ctx.Done()
 	log.Info().Msg("context cancelled, exiting")
 
-	for _, c := range registeredCommands {
-		err = discord.ApplicationCommandDelete(appId, guildId, c.ID)
-		if err != nil {
-			log.Error().Err(err).Msg("failed to deregister commands")
+	for guildId, cmds := range registered.all() {
+		for _, c := range cmds {
+			err = discord.ApplicationCommandDelete(appId, guildId, c.ID)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to deregister commands")
+			}
 		}
 	}
 	log.Info().Msg("deregistered commands, exiting")
 	os.Exit(0)
 }
 
+// registry keeps track of the commands registered in each guild so they can be
+// removed on shutdown.
+type registry struct {
+	mu       sync.Mutex
+	commands map[string][]*discordgo.ApplicationCommand
+}
+
+func (r *registry) set(guildId string, cmds []*discordgo.ApplicationCommand) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[guildId] = cmds
+}
+
+func (r *registry) remove(guildId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.commands, guildId)
+}
+
+func (r *registry) all() map[string][]*discordgo.ApplicationCommand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := make(map[string][]*discordgo.ApplicationCommand, len(r.commands))
+	for k, v := range r.commands {
+		all[k] = v
+	}
+	return all
+}
+
 func touchDbFile(path string) error {
 	f, err := os.Open(path)
 	if err != nil {
@@ -499,17 +1614,31 @@ func registerHandlers(sess *discordgo.Session, handlers map[string]Handler) {
 	})
 	sess.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		switch i.Type {
-		case discordgo.InteractionApplicationCommand:
+		case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
 			if h, ok := handlers[i.ApplicationCommandData().Name]; ok {
 				h(s, i)
 			}
+		case discordgo.InteractionMessageComponent:
+			if strings.HasPrefix(i.MessageComponentData().CustomID, CLAIM_PICK_PREFIX) {
+				handleClaimPick(context.Background(), s, i)
+			}
 		case discordgo.InteractionModalSubmit:
 			if strings.HasPrefix(i.ModalSubmitData().CustomID, "modals_flush_") {
 				sub := i.ModalSubmitData().Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
 				sub = strings.ToLower(sub)
 				if sub == "y" || sub == "ye" || sub == "yes" {
-					err := store.Flush(context.Background())
-					msg := "Flushed all claims!"
+					campaign, ok := activeCampaign(context.Background(), s, i)
+					if !ok {
+						return
+					}
+
+					name := i.ModalSubmitData().Components[1].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
+					if name == "" {
+						name = fmt.Sprintf("%s %s", campaign.Name, time.Now().UTC().Format("2006-01-02"))
+					}
+
+					archiveId, err := store.Flush(context.Background(), campaign.ID, i.Member.User.ID, name)
+					msg := fmt.Sprintf("Flushed all claims! They were archived as #%d %s", archiveId, name)
 					if err != nil {
 						log.Error().Err(err).Msg("failed to flush claims")
 						msg = "failed to flush claims from database"
@@ -542,11 +1671,11 @@ func registerHandlers(sess *discordgo.Session, handlers map[string]Handler) {
 	})
 }
 
-const TABLE_PATTERN = "| %-*s | %-*s | %-*s | %-*s |\n"
+const TABLE_PATTERN = "| %-*s | %-*s | %-*s | %-*s | %-*s |\n"
 
 func formatClaimsTable(claims []themis.Claim) string {
 	sb := strings.Builder{}
-	maxLengths := []int{2, 6, 4, 4} // id, player, type, name
+	maxLengths := []int{2, 6, 4, 4, 16} // id, player, type, name, claimed at
 	for _, c := range claims {
 		sid := strconv.Itoa(c.ID)
 		if len(sid) > maxLengths[0] {
@@ -565,33 +1694,541 @@ func formatClaimsTable(claims []themis.Claim) string {
 		}
 	}
 
-	sb.WriteString(fmt.Sprintf(TABLE_PATTERN, maxLengths[0], "ID", maxLengths[1], "Player", maxLengths[2], "Type", maxLengths[3], "Name"))
-	sb.WriteString(fmt.Sprintf(TABLE_PATTERN, maxLengths[0], strings.Repeat("-", maxLengths[0]), maxLengths[1], strings.Repeat("-", maxLengths[1]), maxLengths[2], strings.Repeat("-", maxLengths[2]), maxLengths[3], strings.Repeat("-", maxLengths[3])))
+	sb.WriteString(fmt.Sprintf(TABLE_PATTERN, maxLengths[0], "ID", maxLengths[1], "Player", maxLengths[2], "Type", maxLengths[3], "Name", maxLengths[4], "Claimed"))
+	sb.WriteString(fmt.Sprintf(TABLE_PATTERN, maxLengths[0], strings.Repeat("-", maxLengths[0]), maxLengths[1], strings.Repeat("-", maxLengths[1]), maxLengths[2], strings.Repeat("-", maxLengths[2]), maxLengths[3], strings.Repeat("-", maxLengths[3]), maxLengths[4], strings.Repeat("-", maxLengths[4])))
 	for _, c := range claims {
-		sb.WriteString(fmt.Sprintf(TABLE_PATTERN, maxLengths[0], strconv.Itoa(c.ID), maxLengths[1], c.Player, maxLengths[2], c.Type, maxLengths[3], c.Name))
+		claimed := ""
+		if !c.CreatedAt.IsZero() {
+			claimed = c.CreatedAt.UTC().Format("2006-01-02 15:04")
+		}
+		sb.WriteString(fmt.Sprintf(TABLE_PATTERN, maxLengths[0], strconv.Itoa(c.ID), maxLengths[1], c.Player, maxLengths[2], c.Type, maxLengths[3], c.Name, maxLengths[4], claimed))
+	}
+	return sb.String()
+}
+
+// formatHistory lists the events, most recent last. When there are too many
+// events to fit in a single message, the oldest ones are left out.
+func formatHistory(events []themis.ClaimEvent) string {
+	if len(events) == 0 {
+		return "No matching changes found"
+	}
+
+	// the most recent events are kept
+	lines := make([]string, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		lines = append(lines, events[i].String())
+	}
+	lines = fitLines(lines)
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return formatListing(lines, len(events), "changes")
+}
+
+// formatAvailability lists the zones, the ones with the most free provinces
+// first. When there are too many zones to fit in a single message, the ones
+// with the fewest free provinces are left out.
+func formatAvailability(zones []themis.ZoneAvailability) string {
+	if len(zones) == 0 {
+		return "No available zones found"
+	}
+
+	sorted := make([]themis.ZoneAvailability, len(zones))
+	copy(sorted, zones)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].FreePercent() > sorted[j].FreePercent()
+	})
+
+	lines := make([]string, 0, len(sorted))
+	for _, z := range sorted {
+		lines = append(lines, z.String())
+	}
+	return formatListing(fitLines(lines), len(zones), "zones")
+}
+
+// fitLines returns the first lines that fit in a single message. 2000 is the
+// character limit for a discord message, room is left for a header and the
+// backticks, see formatListing.
+func fitLines(lines []string) []string {
+	size := 0
+	for i, line := range lines {
+		if size+len(line)+1 > 1900 {
+			return lines[:i]
+		}
+		size += len(line) + 1
+	}
+	return lines
+}
+
+// formatListing shows the lines in a code block, under a header telling how
+// many of the total items they are.
+func formatListing(lines []string, total int, items string) string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("Showing %d of %d %s:\n", len(lines), total, items))
+	sb.WriteString("```\n")
+	for _, line := range lines {
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	sb.WriteString("```\n")
+	return sb.String()
+}
+
+// claimRequestFromOptions reads the `claim-type`, `name` and `except` options
+// shared by /claim and /claim-preview. It responds to the interaction itself
+// when the options are invalid.
+func claimRequestFromOptions(s *discordgo.Session, i *discordgo.InteractionCreate) (themis.ClaimRequest, bool) {
+	// the claim type can be left out of /claim, options are looked up by name
+	var rawType, name, except string
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "claim-type":
+			rawType = opt.StringValue()
+		case "name":
+			name = opt.StringValue()
+		case "except":
+			except = opt.StringValue()
+		}
+	}
+	if name == "" {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "`claim-type` and `name` are mandatory parameters",
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to respond to interaction")
+		}
+		return themis.ClaimRequest{}, false
+	}
+
+	var claimType themis.ClaimType
+	if rawType != "" {
+		var err error
+		claimType, err = themis.ClaimTypeFromString(rawType)
+		if err != nil {
+			log.Error().Err(err).Str("claim_type", rawType).Msg("failed to parse claim")
+			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "You can only take claims of types `province`, `area`, `region`, `trade`, `superregion` or `continent`",
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+			return themis.ClaimRequest{}, false
+		}
+	}
+
+	exclusions, err := parseZones(except)
+	if err != nil {
+		err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: fmt.Sprintf("Invalid exclusions: %s", err),
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to respond to interaction")
+		}
+		return themis.ClaimRequest{}, false
+	}
+
+	return themis.ClaimRequest{
+		Zone:       themis.Zone{Type: claimType, Name: name},
+		Exclusions: exclusions,
+	}, true
+}
+
+// CLAIM_PICK_PREFIX starts the custom ID of the select menu asking players
+// which zone they meant when /claim is given an ambiguous name. It is
+// followed by the ID of the player and the exclusions of the claim.
+const CLAIM_PICK_PREFIX = "claim_pick_"
+
+// inferClaimType fills in the claim type of a request made without one. When
+// the name matches zones of several claim types, the player is asked to pick
+// one and the claim is taken by handleClaimPick. It returns false if the
+// interaction was responded to.
+func inferClaimType(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, campaignId int, req *themis.ClaimRequest) bool {
+	zones, err := store.InferClaimType(ctx, campaignId, req.Name)
+	if err != nil {
+		msg := "failed to acquire claim :("
+		var unknown themis.ErrUnknownZone
+		if errors.As(err, &unknown) {
+			msg = fmt.Sprintf("Can't claim that, %s", unknown)
+		} else {
+			log.Error().Err(err).Msg("failed to infer claim type")
+		}
+		err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: msg,
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to respond to interaction")
+		}
+		return false
+	}
+	if len(zones) == 1 {
+		req.Zone = zones[0]
+		return true
+	}
+
+	customId := CLAIM_PICK_PREFIX + i.Member.User.ID + "_" + formatZones(req.Exclusions)
+	options := make([]discordgo.SelectMenuOption, 0, len(zones))
+	for _, z := range zones {
+		options = append(options, discordgo.SelectMenuOption{
+			Label: z.String(),
+			Value: formatZones([]themis.Zone{z}),
+		})
+	}
+	response := &discordgo.InteractionResponseData{
+		Content: fmt.Sprintf("There are several zones named %s, which one do you want to claim?", req.Name),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.SelectMenu{
+						CustomID:    customId,
+						Placeholder: "Pick a zone",
+						Options:     options[:min(len(options), 25)],
+					},
+				},
+			},
+		},
+	}
+	// custom IDs are limited to 100 characters
+	if len(customId) > 100 {
+		names := make([]string, 0, len(zones))
+		for _, z := range zones {
+			names = append(names, z.String())
+		}
+		response = &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("There are several zones named %s (%s), pick one with `claim-type`", req.Name, strings.Join(names, ", ")),
+		}
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: response,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to respond to interaction")
+	}
+	return false
+}
+
+// handleClaimPick takes the claim on the zone picked in the select menu sent
+// by inferClaimType.
+func handleClaimPick(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.MessageComponentData()
+	userId, except, _ := strings.Cut(strings.TrimPrefix(data.CustomID, CLAIM_PICK_PREFIX), "_")
+	if i.Member.User.ID != userId {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "Only the player who ran `/claim` can pick the zone",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to respond to interaction")
+		}
+		return
+	}
+
+	zones, err := parseZones(strings.Join(data.Values, ","))
+	if err == nil && len(zones) != 1 {
+		err = fmt.Errorf("expected a single zone, got %d", len(zones))
+	}
+	var exclusions []themis.Zone
+	if err == nil {
+		exclusions, err = parseZones(except)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("custom_id", data.CustomID).Msg("failed to parse picked zone")
+		return
+	}
+
+	campaign, ok := activeCampaign(ctx, s, i)
+	if !ok {
+		return
+	}
+
+	player := i.Member.Nick
+	if player == "" {
+		player = i.Member.User.Username
+	}
+	takeClaim(ctx, s, i, campaign.ID, userId, player, themis.ClaimRequest{Zone: zones[0], Exclusions: exclusions})
+}
+
+// takeClaim takes the claim for the player and responds with the outcome.
+func takeClaim(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, campaignId int, userId, player string, req themis.ClaimRequest) {
+	_, err := store.Claim(ctx, campaignId, userId, player, req.Name, req.Type, req.Exclusions...)
+	if err != nil {
+		conflict, ok := err.(themis.ErrConflict)
+		if ok {
+			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: formatConflicts(conflict.Conflicts),
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+			return
+		}
+
+		var notContiguous themis.ErrNotContiguous
+		if errors.As(err, &notContiguous) {
+			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: fmt.Sprintf("Claims must be contiguous in this campaign, %s", notContiguous),
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+			return
+		}
+
+		var unknown themis.ErrUnknownZone
+		var noProvinces themis.ErrNoProvinces
+		if errors.As(err, &unknown) || errors.As(err, &noProvinces) {
+			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: fmt.Sprintf("Can't claim that, %s", err),
+				},
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to respond to interaction")
+			}
+			return
+		}
+
+		log.Error().Err(err).Msg("failed to acquire claim")
+		err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "failed to acquire claim :(",
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to respond to interaction")
+		}
+		return
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("Claimed %s for %s!", req.Name, player),
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to respond to interaction")
+	}
+}
+
+// formatPreview describes a claim preview. The list of provinces is left out
+// for large zones to stay within Discord's message size limit.
+func formatPreview(p themis.ClaimPreview) string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%s would hold %d provinces with a total development of %d\n", p.Zone, len(p.Provinces), p.Development))
+	details := strings.Builder{}
+	if len(p.Provinces) <= 50 {
+		for _, name := range p.Provinces {
+			details.WriteString(fmt.Sprintf("  - %s\n", name))
+		}
+	}
+	for _, ex := range p.Exclusions {
+		details.WriteString(fmt.Sprintf("except %s\n", ex))
+	}
+	if details.Len() > 0 {
+		sb.WriteString(fmt.Sprintf("```\n%s```\n", details.String()))
+	}
+	if len(p.Conflicts) > 0 {
+		sb.WriteString(formatConflicts(p.Conflicts))
 	}
 	return sb.String()
 }
 
+// formatConflicts lists the conflicting provinces preventing a claim.
+func formatConflicts(conflicts []themis.Conflict) string {
+	sb := strings.Builder{}
+	sb.WriteString("Some provinces are already claimed:\n```\n")
+	for _, c := range conflicts {
+		sb.WriteString(fmt.Sprintf("  - %s\n", c))
+	}
+	sb.WriteString("```\n")
+	return sb.String()
+}
+
+// parseZones parses a comma-separated list of zones in the `type:name` format.
+// Zones without a type are assumed to be provinces.
+func parseZones(s string) ([]themis.Zone, error) {
+	zones := make([]themis.Zone, 0)
+	for _, raw := range strings.Split(s, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		rawType, name, found := strings.Cut(raw, ":")
+		if !found {
+			zones = append(zones, themis.Zone{Type: themis.CLAIM_TYPE_PROVINCE, Name: raw})
+			continue
+		}
+
+		ct, err := themis.ClaimTypeFromString(strings.ToLower(strings.TrimSpace(rawType)))
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, themis.Zone{Type: ct, Name: strings.TrimSpace(name)})
+	}
+	return zones, nil
+}
+
+// formatZones formats zones the way parseZones reads them.
+func formatZones(zones []themis.Zone) string {
+	raw := make([]string, 0, len(zones))
+	for _, z := range zones {
+		raw = append(raw, fmt.Sprintf("%s:%s", string(z.Type), z.Name))
+	}
+	return strings.Join(raw, ", ")
+}
+
+// parseDate parses user-provided dates, with or without a time of day. Dates
+// are assumed to be in UTC.
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not in the YYYY-MM-DD [HH:MM] format", s)
+}
+
+func claimTypeChoices() []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(themis.ClaimTypes))
+	for _, ct := range themis.ClaimTypes {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: ct.String(), Value: string(ct)})
+	}
+	return choices
+}
+
 func handleClaimAutocomplete(ctx context.Context, store *themis.Store, s *discordgo.Session, i *discordgo.InteractionCreate) {
-	opts := i.ApplicationCommandData().Options
-	claimType, err := themis.ClaimTypeFromString(opts[0].StringValue())
+	// the options are looked up by name, /remap-claim has the claim ID first
+	var rawType string
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "claim-type" {
+			rawType = opt.StringValue()
+		}
+	}
+	if rawType == "" {
+		// /claim infers the claim type from the name
+		handleZoneAutocomplete(ctx, store, s, i)
+		return
+	}
+	claimType, err := themis.ClaimTypeFromString(rawType)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to parse claim type")
 		return
 	}
+	handleZoneAutocomplete(ctx, store, s, i, claimType)
+}
+
+// handleZoneAutocomplete suggests the zones of the given claim types, or of
+// all of them, matching the focused option. Every command taking a zone name
+// goes through it.
+func handleZoneAutocomplete(ctx context.Context, store *themis.Store, s *discordgo.Session, i *discordgo.InteractionCreate, types ...themis.ClaimType) {
+	campaign, err := store.ActiveCampaign(ctx, i.GuildID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get active campaign")
+		return
+	}
 
-	availability, err := store.ListAvailability(ctx, claimType, opts[1].StringValue())
+	var search string
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Focused {
+			search = opt.StringValue()
+		}
+	}
+	zones, err := store.SearchZones(ctx, campaign.ID, search, 25, types...)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to list availabilities")
+		log.Error().Err(err).Msg("failed to search zones")
 		return
 	}
 
-	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(availability))
-	for _, s := range availability {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(zones))
+	for _, z := range zones {
+		// choice names are limited to 100 characters
+		name := z.String()
+		if len(name) > 100 {
+			name = name[:97] + "..."
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  name,
+			Value: z.Value,
+		})
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	}); err != nil {
+		log.Error().Err(err).Msg("failed to respond to interaction")
+	}
+}
+
+func handleCampaignAutocomplete(ctx context.Context, store *themis.Store, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	sub := i.ApplicationCommandData().Options[0]
+	focused := sub.Options[0]
+	for _, opt := range sub.Options {
+		if opt.Focused {
+			focused = opt
+		}
+	}
+	search := strings.ToLower(focused.StringValue())
+
+	names := make([]string, 0)
+	if sub.Name == "dataset" || focused.Name == "dataset" {
+		datasets, err := store.ListDatasets(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to list datasets")
+			return
+		}
+		for _, d := range datasets {
+			names = append(names, d.Name)
+		}
+	} else {
+		campaigns, err := store.ListCampaigns(ctx, i.GuildID)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to list campaigns")
+			return
+		}
+		for _, c := range campaigns {
+			if !c.ArchivedAt.Valid {
+				names = append(names, c.Name)
+			}
+		}
+	}
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(names))
+	for _, name := range names {
+		if !strings.Contains(strings.ToLower(name), search) {
+			continue
+		}
 		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
-			Name:  s,
-			Value: s,
+			Name:  name,
+			Value: name,
 		})
 	}
 
@@ -605,6 +2242,49 @@ func handleClaimAutocomplete(ctx context.Context, store *themis.Store, s *discor
 	}
 }
 
+// datasetExists checks that there is a province dataset with the given name.
+func datasetExists(ctx context.Context, name string) bool {
+	datasets, err := store.ListDatasets(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list datasets")
+		return false
+	}
+	for _, d := range datasets {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// activeCampaign returns the active campaign of the guild the interaction
+// comes from. If there is none, it responds to the interaction and returns
+// false; the caller should then return immediately.
+func activeCampaign(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) (themis.Campaign, bool) {
+	campaign, err := store.ActiveCampaign(ctx, i.GuildID)
+	if err == nil {
+		return campaign, true
+	}
+
+	msg := "Oops, something went wrong! :("
+	if errors.Is(err, themis.ErrNoActiveCampaign) {
+		msg = "There is no active campaign, start one with `/campaign create`"
+	} else {
+		log.Error().Err(err).Msg("failed to get active campaign")
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: msg,
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to respond to interaction")
+	}
+	return themis.Campaign{}, false
+}
+
 func serve(address string) error {
 	http.Handle("/health", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("OK")) //nolint:errcheck // this is expected to always work, 'trust me bro' guaranteed
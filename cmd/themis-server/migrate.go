@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"go.wperron.io/themis"
+)
+
+const migrateUsage = `usage: themis-server -db <file> migrate <command> [version]
+
+commands:
+  status            list the migrations and whether they were applied
+  up [version]      apply the pending migrations, up to version if given
+  down [version]    revert the migrations applied after version, or only the
+                    last one if no version is given`
+
+// runMigrate implements the `migrate` subcommand. The server applies pending
+// migrations when it starts, this is mostly for checking the database and for
+// reverting migrations.
+func runMigrate(ctx context.Context, w io.Writer, conn string, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New(migrateUsage)
+	}
+
+	var target int
+	if len(args) == 2 {
+		var err error
+		target, err = strconv.Atoi(args[1])
+		if err != nil || target < 0 {
+			return fmt.Errorf("invalid version %q\n%s", args[1], migrateUsage)
+		}
+	}
+
+	migrator, err := themis.NewMigrator(conn)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "status":
+		states, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT\tREVERSIBLE")
+		for _, s := range states {
+			appliedAt := "pending"
+			if s.AppliedAt.Valid {
+				appliedAt = s.AppliedAt.Time.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\t%t\n", s.Version, s.Name, appliedAt, s.Reversible())
+		}
+		return tw.Flush()
+	case "up":
+		applied, err := migrator.Up(ctx, target)
+		for _, m := range applied {
+			fmt.Fprintf(w, "applied %s\n", m)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(w, "database is up to date")
+		}
+		return err
+	case "down":
+		if len(args) == 1 {
+			version, err := migrator.Version(ctx)
+			if err != nil {
+				return err
+			}
+			target = version - 1
+		}
+
+		reverted, err := migrator.Down(ctx, target)
+		for _, m := range reverted {
+			fmt.Fprintf(w, "reverted %s\n", m)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+}
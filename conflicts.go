@@ -2,7 +2,9 @@ package themis
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"strings"
 )
 
 type Conflict struct {
@@ -17,36 +19,75 @@ func (c Conflict) String() string {
 	return fmt.Sprintf("%s owned by #%d %s %s (%s)", c.Province, c.ClaimID, c.ClaimType, c.Claim, c.Player)
 }
 
-const conflictQuery string = `SELECT name, player, claim_type, val, id FROM (
-    SELECT provinces.name, claims.player, claims.claim_type, claims.val, claims.id
+// conflictBranch finds the provinces of the zone being claimed, held by
+// another player through a claim of a single claim type. The full conflicts
+// query is the union of one branch per claim type.
+// Provinces excluded from either the existing claim or the zone being claimed,
+// and provinces of types the campaign doesn't count, are never conflicting.
+const conflictBranch string = `SELECT provinces.name, claims.player, claims.claim_type, claims.val, claims.id
         FROM claims
-        LEFT JOIN provinces ON claims.val = provinces.trade_node
-        WHERE claims.claim_type = 'trade' AND claims.userid IS NOT ?
-        AND provinces.%[1]s = ?
-    UNION
-        SELECT provinces.name, claims.player, claims.claim_type, claims.val, claims.id
-        FROM claims
-        LEFT JOIN provinces ON claims.val = provinces.region
-        WHERE claims.claim_type = 'region' AND claims.userid IS NOT ?
-        AND provinces.%[1]s = ?
-    UNION
-        SELECT provinces.name, claims.player, claims.claim_type, claims.val, claims.id
-        FROM claims
-        LEFT JOIN provinces ON claims.val = provinces.area
-        WHERE claims.claim_type = 'area' AND claims.userid IS NOT ?
-        AND provinces.%[1]s = ?
-);`
+        LEFT JOIN provinces ON claims.zone_id = provinces.%[2]s
+        WHERE claims.claim_type = '%[3]s' AND claims.campaign_id = ? AND claims.userid IS NOT ?
+        AND provinces.dataset_id = ? AND provinces.%[1]s = ?
+        AND %[6]s AND NOT %[4]s%[5]s`
+
+func conflictQuery(claimType ClaimType, exclusions []Zone) string {
+	sb := strings.Builder{}
+	for _, ex := range exclusions {
+		sb.WriteString(fmt.Sprintf("\n        AND provinces.%s IS NOT ?", claimTypeToColumn[ex.Type]))
+	}
+
+	branches := make([]string, 0, len(ClaimTypes))
+	for _, ct := range ClaimTypes {
+		branches = append(branches, fmt.Sprintf(conflictBranch, claimTypeToColumn[claimType], claimTypeToIDColumn[ct], string(ct), excludedCondition("claims.id"), sb.String(), provinceTypeCondition("claims.campaign_id", "provinces.typ")))
+	}
+	return fmt.Sprintf("SELECT name, player, claim_type, val, id FROM (\n    %s\n);", strings.Join(branches, "\n    UNION\n    "))
+}
+
+// FindConflicts lists the provinces of the zone that are already held by other
+// players, leaving out the excluded zones. Zones are matched like claims, see
+// resolveZoneID.
+func (s *Store) FindConflicts(ctx context.Context, campaignId int, userId, name string, claimType ClaimType, exclusions ...Zone) ([]Conflict, error) {
+	datasetId, err := campaignDataset(ctx, s.db, campaignId)
+	if err != nil {
+		return nil, err
+	}
 
-func (s *Store) FindConflicts(ctx context.Context, userId, name string, claimType ClaimType) ([]Conflict, error) {
-	stmt, err := s.db.PrepareContext(ctx, fmt.Sprintf(conflictQuery, claimTypeToColumn[claimType]))
+	name, err = resolveZone(ctx, s.db, datasetId, Zone{Type: claimType, Name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	exclusions, err = resolveExclusions(ctx, s.db, datasetId, name, claimType, exclusions)
+	if err != nil {
+		return nil, err
+	}
+
+	return findConflicts(ctx, s.db, campaignId, datasetId, userId, name, claimType, exclusions)
+}
+
+// findConflicts runs the conflicts query for a zone and exclusions that have
+// already been resolved against the campaign's dataset.
+func findConflicts(ctx context.Context, q querier, campaignId, datasetId int, userId, name string, claimType ClaimType, exclusions []Zone) ([]Conflict, error) {
+	stmt, err := q.PrepareContext(ctx, conflictQuery(claimType, exclusions))
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare conflicts query: %w", err)
 	}
+	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx, userId, name, userId, name, userId, name)
+	params := make([]any, 0, (4+len(exclusions))*len(ClaimTypes))
+	for range ClaimTypes {
+		params = append(params, campaignId, userId, datasetId, name)
+		for _, ex := range exclusions {
+			params = append(params, ex.Name)
+		}
+	}
+
+	rows, err := stmt.QueryContext(ctx, params...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get conflicting provinces: %w", err)
 	}
+	defer rows.Close()
 
 	conflicts := make([]Conflict, 0)
 	for rows.Next() {
@@ -78,5 +119,61 @@ func (s *Store) FindConflicts(ctx context.Context, userId, name string, claimTyp
 		})
 	}
 
-	return conflicts, nil
+	return conflicts, rows.Err()
+}
+
+// claimProvincesBranch selects the provinces held through a claim of a single
+// claim type, see insertClaimProvinces.
+const claimProvincesBranch string = `SELECT claims.id, claims.campaign_id, claims.userid, provinces.id
+        FROM claims
+        JOIN campaigns ON campaigns.id = claims.campaign_id
+        JOIN provinces ON provinces.dataset_id = campaigns.dataset_id AND claims.zone_id = provinces.%[1]s
+        WHERE claims.claim_type = '%[2]s' AND claims.id = ?
+        AND %[4]s AND NOT %[3]s`
+
+// insertClaimProvinces records the provinces held through the claim, of the
+// types counted by the campaign, once it and its exclusions are inserted. The
+// check_conflict trigger aborts the insert if any of them is held by another
+// player.
+func insertClaimProvinces(ctx context.Context, tx *sql.Tx, claimId int) error {
+	branches := make([]string, 0, len(ClaimTypes))
+	params := make([]any, 0, len(ClaimTypes))
+	for _, ct := range ClaimTypes {
+		branches = append(branches, fmt.Sprintf(claimProvincesBranch, claimTypeToIDColumn[ct], string(ct), excludedCondition("claims.id"), provinceTypeCondition("claims.campaign_id", "provinces.typ")))
+		params = append(params, claimId)
+	}
+
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO claim_provinces (claim_id, campaign_id, userid, province_id)\n    %s", strings.Join(branches, "\n    UNION\n    ")), params...)
+	if err != nil {
+		return fmt.Errorf("failed to insert provinces of claim ID %d: %w", claimId, err)
+	}
+	return nil
+}
+
+// claimOverlaps lists the provinces of the claim that are also held through
+// other claims by players other than the given user. It is used to check that
+// a claim can be handed over without creating conflicts.
+func claimOverlaps(ctx context.Context, q querier, claimId int, userId string) ([]Conflict, error) {
+	rows, err := q.QueryContext(ctx, `SELECT provinces.name, claims.player, claims.claim_type, claims.val, claims.id
+	FROM claim_provinces AS mine
+	JOIN claim_provinces AS theirs ON theirs.campaign_id = mine.campaign_id AND theirs.province_id = mine.province_id AND theirs.claim_id != mine.claim_id
+	JOIN claims ON claims.id = theirs.claim_id
+	JOIN campaigns ON campaigns.id = mine.campaign_id
+	JOIN provinces ON provinces.dataset_id = campaigns.dataset_id AND provinces.id = mine.province_id
+	WHERE mine.claim_id = ? AND theirs.userid IS NOT ?
+	ORDER BY CAST(provinces.id AS INTEGER)`, claimId, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	conflicts := make([]Conflict, 0)
+	for rows.Next() {
+		c := Conflict{}
+		if err := rows.Scan(&c.Province, &c.Player, &c.ClaimType, &c.Claim, &c.ClaimID); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, rows.Err()
 }
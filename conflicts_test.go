@@ -12,8 +12,10 @@ import (
 func TestStore_FindConflicts(t *testing.T) {
 	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestStore_FindConflicts"))
 	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
 
-	id, err := store.Claim(context.TODO(), "000000000000000001", "foo", "Bordeaux", CLAIM_TYPE_TRADE)
+	id, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Bordeaux", CLAIM_TYPE_TRADE)
 	assert.NoError(t, err)
 
 	type args struct {
@@ -68,7 +70,7 @@ func TestStore_FindConflicts(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := store.FindConflicts(tt.args.ctx, tt.args.userId, tt.args.name, tt.args.claimType)
+			got, err := store.FindConflicts(tt.args.ctx, campaignId, tt.args.userId, tt.args.name, tt.args.claimType)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Store.FindConflicts() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -79,3 +81,81 @@ func TestStore_FindConflicts(t *testing.T) {
 		})
 	}
 }
+
+func TestStore_FindConflictsLargeZones(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestStore_FindConflictsLargeZones"))
+	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	id, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Western Europe", CLAIM_TYPE_SUPERREGION)
+	assert.NoError(t, err)
+
+	got, err := store.FindConflicts(context.TODO(), campaignId, "000000000000000002", "Gascony", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	assert.Equal(t, []Conflict{
+		{Province: "Armagnac", Player: "foo", ClaimType: CLAIM_TYPE_SUPERREGION, Claim: "Western Europe", ClaimID: id},
+		{Province: "Béarn", Player: "foo", ClaimType: CLAIM_TYPE_SUPERREGION, Claim: "Western Europe", ClaimID: id},
+		{Province: "Foix", Player: "foo", ClaimType: CLAIM_TYPE_SUPERREGION, Claim: "Western Europe", ClaimID: id},
+		{Province: "Labourd", Player: "foo", ClaimType: CLAIM_TYPE_SUPERREGION, Claim: "Western Europe", ClaimID: id},
+	}, got)
+
+	// claiming the whole continent conflicts with the superregion
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000002", "bar", "Europe", CLAIM_TYPE_CONTINENT)
+	assert.ErrorAs(t, err, &ErrConflict{})
+
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000002", "bar", "Oceania", CLAIM_TYPE_CONTINENT)
+	assert.NoError(t, err)
+
+	// There are 6 distinct continents with land provinces, one of which is
+	// claimed and Europe holds the claimed superregion
+	availability, err := store.ListAvailability(context.TODO(), campaignId, CLAIM_TYPE_CONTINENT)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(availability))
+	assert.NotContains(t, availability, "Europe")
+}
+
+func TestStore_FindConflictsProvinces(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestStore_FindConflictsProvinces"))
+	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	gasconyId, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Gascony", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+
+	// a province inside an existing area claim
+	got, err := store.FindConflicts(context.TODO(), campaignId, "000000000000000002", "Foix", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+	assert.Equal(t, []Conflict{
+		{Province: "Foix", Player: "foo", ClaimType: CLAIM_TYPE_AREA, Claim: "Gascony", ClaimID: gasconyId},
+	}, got)
+
+	// province IDs work just as well as names
+	got, err = store.FindConflicts(context.TODO(), campaignId, "000000000000000002", "4694", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(got))
+
+	parisId, err := store.Claim(context.TODO(), campaignId, "000000000000000002", "bar", "paris", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+
+	// an area containing an existing province claim
+	got, err = store.FindConflicts(context.TODO(), campaignId, "000000000000000003", "Île-de-France", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	assert.Equal(t, []Conflict{
+		{Province: "Paris", Player: "bar", ClaimType: CLAIM_TYPE_PROVINCE, Claim: "183", ClaimID: parisId},
+	}, got)
+
+	detail, err := store.DescribeClaim(context.TODO(), campaignId, parisId)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Paris"}, detail.Provinces)
+
+	// Beja is the name of two different provinces
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000003", "baz", "Beja", CLAIM_TYPE_PROVINCE)
+	assert.ErrorContains(t, err, "229, 1226")
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000003", "baz", "229", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000003", "baz", "999999", CLAIM_TYPE_PROVINCE)
+	assert.Error(t, err)
+}
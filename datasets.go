@@ -0,0 +1,124 @@
+package themis
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DEFAULT_DATASET is the province dataset of the unmodded game, campaigns use
+// it unless they are pinned to another one.
+const DEFAULT_DATASET = "vanilla"
+
+// Dataset is a named set of provinces, like the map of the vanilla game or of
+// a mod. Zone names only need to be unique within a dataset.
+type Dataset struct {
+	ID        int
+	Name      string
+	Provinces int
+	// Campaigns counts the campaigns pinned to the dataset.
+	Campaigns int
+	CreatedAt time.Time
+}
+
+func (d Dataset) String() string {
+	return fmt.Sprintf("%s (%d provinces, used by %d campaigns)", d.Name, d.Provinces, d.Campaigns)
+}
+
+// ListDatasets returns all the province datasets, ordered by name.
+func (s *Store) ListDatasets(ctx context.Context) ([]Dataset, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, created_at,
+		(SELECT COUNT(1) FROM province_data WHERE dataset_id = datasets.id),
+		(SELECT COUNT(1) FROM campaigns WHERE dataset_id = datasets.id)
+	FROM datasets ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	datasets := make([]Dataset, 0)
+	for rows.Next() {
+		d := Dataset{}
+		if err := rows.Scan(&d.ID, &d.Name, &d.CreatedAt, &d.Provinces, &d.Campaigns); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		datasets = append(datasets, d)
+	}
+	return datasets, rows.Err()
+}
+
+// SetCampaignDataset pins the campaign to another province dataset. Claims
+// are names resolved against the dataset, the board must be empty to switch.
+// Archived campaigns keep their dataset.
+func (s *Store) SetCampaignDataset(ctx context.Context, campaignId int, dataset string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	datasetId, err := findDataset(ctx, tx, dataset)
+	if err != nil {
+		return err
+	}
+	var archived sql.NullTime
+	err = tx.QueryRowContext(ctx, "SELECT archived_at FROM campaigns WHERE id = ?", campaignId).Scan(&archived)
+	if err == sql.ErrNoRows {
+		return ErrNoSuchCampaign
+	}
+	if err != nil {
+		return fmt.Errorf("failed to scan row: %w", err)
+	}
+	if archived.Valid {
+		return ErrCampaignArchived
+	}
+
+	var claims int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(1) FROM claims WHERE campaign_id = ?", campaignId).Scan(&claims); err != nil {
+		return fmt.Errorf("failed to count claims: %w", err)
+	}
+	if claims > 0 {
+		return ErrBoardNotEmpty
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE campaigns SET dataset_id = ? WHERE id = ?", datasetId, campaignId); err != nil {
+		return fmt.Errorf("failed to update campaign: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// findDataset returns the ID of the dataset with the given name, or of the
+// default dataset if the name is empty.
+func findDataset(ctx context.Context, q querier, name string) (int, error) {
+	if name == "" {
+		name = DEFAULT_DATASET
+	}
+
+	var id int
+	err := q.QueryRowContext(ctx, "SELECT id FROM datasets WHERE name = ?", name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrNoSuchDataset
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan row: %w", err)
+	}
+	return id, nil
+}
+
+// campaignDataset returns the ID of the dataset the campaign is pinned to.
+func campaignDataset(ctx context.Context, q querier, campaignId int) (int, error) {
+	var id int
+	err := q.QueryRowContext(ctx, "SELECT dataset_id FROM campaigns WHERE id = ?", campaignId).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrNoSuchCampaign
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan row: %w", err)
+	}
+	return id, nil
+}
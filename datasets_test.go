@@ -0,0 +1,79 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatasets(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestDatasets"))
+	assert.NoError(t, err)
+	vanillaId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, "vanilla game")
+	assert.NoError(t, err)
+	moddedId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, "modded game")
+	assert.NoError(t, err)
+
+	provinces, err := LoadGameFiles(os.DirFS("testdata/eu4"))
+	assert.NoError(t, err)
+	_, err = store.ImportProvinces(context.TODO(), "scandinavia", provinces)
+	assert.NoError(t, err)
+
+	datasets, err := store.ListDatasets(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(datasets))
+	assert.Equal(t, "scandinavia", datasets[0].Name)
+	assert.Equal(t, 9, datasets[0].Provinces)
+	assert.Equal(t, DEFAULT_DATASET, datasets[1].Name)
+	assert.Equal(t, 3925, datasets[1].Provinces)
+	assert.Equal(t, 2, datasets[1].Campaigns)
+
+	assert.ErrorIs(t, store.SetCampaignDataset(context.TODO(), moddedId, "extended timeline"), ErrNoSuchDataset)
+	assert.NoError(t, store.SetCampaignDataset(context.TODO(), moddedId, "scandinavia"))
+	campaign, err := store.ActiveCampaign(context.TODO(), TEST_GUILD_ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "scandinavia", campaign.Dataset)
+
+	// names are resolved against the campaign's dataset
+	_, err = store.Claim(context.TODO(), moddedId, "000000000000000001", "foo", "Gascony", CLAIM_TYPE_AREA)
+	assert.Error(t, err)
+	claimId, err := store.Claim(context.TODO(), moddedId, "000000000000000001", "foo", "Östergötland", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	_, err = store.Claim(context.TODO(), vanillaId, "000000000000000001", "foo", "Gascony", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+
+	detail, err := store.DescribeClaim(context.TODO(), moddedId, claimId)
+	assert.NoError(t, err)
+	assert.Equal(t, "Östergötland", detail.Name)
+	assert.Equal(t, []string{"Östergötland", "Småland", "Gotland"}, detail.Provinces)
+
+	available, err := store.ListAvailability(context.TODO(), moddedId, CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Svealand", "Skaneland"}, available)
+
+	conflicts, err := store.FindConflicts(context.TODO(), moddedId, "000000000000000002", "Baltic Sea", CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(conflicts))
+	conflicts, err = store.FindConflicts(context.TODO(), vanillaId, "000000000000000002", "Baltic Sea", CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(conflicts))
+
+	assert.ErrorIs(t, store.SetCampaignDataset(context.TODO(), moddedId, DEFAULT_DATASET), ErrBoardNotEmpty)
+
+	// importing the vanilla dataset leaves the modded campaign alone
+	vanilla, err := store.ListProvinces(context.TODO(), ProvinceFilter{})
+	assert.NoError(t, err)
+	_, err = store.ImportProvinces(context.TODO(), DEFAULT_DATASET, vanilla)
+	assert.NoError(t, err)
+	_, err = store.Claim(context.TODO(), moddedId, "000000000000000002", "bar", "Gotland", CLAIM_TYPE_PROVINCE)
+	assert.Error(t, err)
+
+	// archived campaigns keep their dataset, even once their board is empty
+	_, err = store.Flush(context.TODO(), moddedId, "000000000000000001", "modded game")
+	assert.NoError(t, err)
+	assert.NoError(t, store.ArchiveCampaign(context.TODO(), TEST_GUILD_ID, moddedId))
+	assert.ErrorIs(t, store.SetCampaignDataset(context.TODO(), moddedId, DEFAULT_DATASET), ErrCampaignArchived)
+}
@@ -3,9 +3,25 @@ package themis
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
-var ErrNoSuchClaim = errors.New("no such claim")
+var (
+	ErrNoSuchClaim      = errors.New("no such claim")
+	ErrNoSuchCampaign   = errors.New("no such campaign")
+	ErrNoActiveCampaign = errors.New("no active campaign")
+	ErrCampaignArchived = errors.New("campaign is archived")
+	ErrNoSuchArchive    = errors.New("no such archive")
+	ErrBoardNotEmpty    = errors.New("there are claims on the board")
+	ErrNoSuchProvince   = errors.New("no such province")
+	ErrNoSuchDataset    = errors.New("no such dataset")
+	ErrNoAdjacencies    = errors.New("dataset has no adjacency data")
+	ErrNoTradeLinks     = errors.New("dataset has no trade network data")
+	ErrNoSuchAlias      = errors.New("no such alias")
+
+	ErrSchemaTooNew          = errors.New("database schema is newer than this binary")
+	ErrIrreversibleMigration = errors.New("migration can't be reverted")
+)
 
 type ErrConflict struct {
 	Conflicts []Conflict
@@ -14,3 +30,69 @@ type ErrConflict struct {
 func (ec ErrConflict) Error() string {
 	return fmt.Sprintf("found %d conflicting provinces", len(ec.Conflicts))
 }
+
+// ErrNotContiguous is returned when the campaign requires claims to touch the
+// player's other claims and the claimed zone doesn't.
+type ErrNotContiguous struct {
+	Zone Zone
+}
+
+func (enc ErrNotContiguous) Error() string {
+	return fmt.Sprintf("%s doesn't touch any of the player's claims", enc.Zone)
+}
+
+// ErrNoProvinces is returned when claiming a zone without provinces of the
+// types counted by the campaign, e.g. a sea area when only land provinces
+// count.
+type ErrNoProvinces struct {
+	Zone          Zone
+	ProvinceTypes []string
+}
+
+func (enp ErrNoProvinces) Error() string {
+	return fmt.Sprintf("%s has no %s provinces", enp.Zone, strings.Join(enp.ProvinceTypes, ", "))
+}
+
+// ErrUnknownZone is returned when no zone of the claim type has the name, or
+// no zone of any claim type when Zone.Type is empty. Suggestions are the
+// zones the player may have meant, the most likely first: zones of other
+// claim types with that exact name, then zones with close names.
+type ErrUnknownZone struct {
+	Zone        Zone
+	Suggestions []Zone
+}
+
+func (euz ErrUnknownZone) Error() string {
+	msg := fmt.Sprintf("found no provinces for %s named %s", euz.Zone.Type, euz.Zone.Name)
+	switch euz.Zone.Type {
+	case "":
+		msg = fmt.Sprintf("found no zones named %s", euz.Zone.Name)
+	case CLAIM_TYPE_PROVINCE:
+		msg = fmt.Sprintf("found no provinces named %s", euz.Zone.Name)
+	}
+
+	types := make([]string, 0)
+	others := make([]string, 0, len(euz.Suggestions))
+	for _, s := range euz.Suggestions {
+		if euz.Zone.Type != "" && foldName(s.Name) == foldName(euz.Zone.Name) {
+			types = append(types, withArticle(s.Type.String()))
+			continue
+		}
+		others = append(others, s.String())
+	}
+	if len(types) > 0 {
+		return fmt.Sprintf("%s is %s, not %s", euz.Zone.Name, strings.Join(types, " or "), withArticle(euz.Zone.Type.String()))
+	}
+	if len(others) > 0 {
+		return fmt.Sprintf("%s, did you mean %s?", msg, strings.Join(others, ", "))
+	}
+	return msg
+}
+
+// withArticle prefixes a claim type name with its indefinite article.
+func withArticle(s string) string {
+	if s != "" && strings.ContainsRune("AEIOU", rune(s[0])) {
+		return "an " + s
+	}
+	return "a " + s
+}
@@ -0,0 +1,217 @@
+package themis
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type EventType string
+
+const (
+	EVENT_TYPE_CREATED     = "created"
+	EVENT_TYPE_RELEASED    = "released"
+	EVENT_TYPE_TRANSFERRED = "transferred"
+	EVENT_TYPE_FLUSHED     = "flushed"
+	EVENT_TYPE_RESTORED    = "restored"
+	EVENT_TYPE_REMAPPED    = "remapped"
+)
+
+// ClaimEvent is an entry in the append-only log of claim mutations. Events are
+// never updated nor deleted, they keep track of who held what and when even
+// after the claims themselves are gone.
+type ClaimEvent struct {
+	ID        int
+	ClaimID   int
+	Type      EventType
+	UserID    string // Discord user ID of whoever made the change
+	Timestamp time.Time
+	Payload   EventPayload
+}
+
+// EventPayload is the state of the claim after the event. For releases and
+// flushes, it is the state of the claim right before it was removed.
+// Restored claims keep the ID they had before being flushed.
+type EventPayload struct {
+	Player    string    `json:"player"`
+	UserID    string    `json:"userid"`
+	ClaimType ClaimType `json:"claim_type"`
+	Name      string    `json:"val"`
+
+	Exclusions []Zone `json:"exclusions,omitempty"`
+
+	// Only set on transfers.
+	PreviousPlayer string `json:"previous_player,omitempty"`
+	PreviousUserID string `json:"previous_userid,omitempty"`
+
+	// Only set on remaps, the zone the claim was on before.
+	PreviousClaimType ClaimType `json:"previous_claim_type,omitempty"`
+	PreviousName      string    `json:"previous_val,omitempty"`
+}
+
+func (e ClaimEvent) String() string {
+	p := e.Payload
+	switch e.Type {
+	case EVENT_TYPE_TRANSFERRED:
+		return fmt.Sprintf("%s #%d %s %s transferred from %s to %s", e.Timestamp.Format("2006-01-02 15:04"), e.ClaimID, p.ClaimType, p.Name, p.PreviousPlayer, p.Player)
+	case EVENT_TYPE_REMAPPED:
+		return fmt.Sprintf("%s #%d %s %s of %s remapped to %s %s", e.Timestamp.Format("2006-01-02 15:04"), e.ClaimID, p.PreviousClaimType, p.PreviousName, p.Player, p.ClaimType, p.Name)
+	default:
+		return fmt.Sprintf("%s #%d %s %s %s by %s", e.Timestamp.Format("2006-01-02 15:04"), e.ClaimID, p.ClaimType, p.Name, e.Type, p.Player)
+	}
+}
+
+// HistoryFilter narrows down the events returned by ClaimHistory. Zero values
+// are ignored.
+type HistoryFilter struct {
+	// UserID matches events on claims held by the user, either before or
+	// after the event.
+	UserID string
+	// Zone matches events on claims with the given name, compared folded,
+	// either before or after the event. Province claims are matched by the
+	// name or ID of the province.
+	Zone  string
+	Since time.Time
+	Until time.Time
+}
+
+// ClaimHistory returns the campaign's claim events matching the filter, oldest
+// first.
+func (s *Store) ClaimHistory(ctx context.Context, campaignId int, filter HistoryFilter) ([]ClaimEvent, error) {
+	query := `SELECT id, claim_id, event_type, userid, created_at, payload FROM claim_events WHERE campaign_id = ?`
+	params := []any{campaignId}
+
+	if filter.UserID != "" {
+		query += ` AND (json_extract(payload, '$.userid') = ? OR json_extract(payload, '$.previous_userid') = ?)`
+		params = append(params, filter.UserID, filter.UserID)
+	}
+	if filter.Zone != "" {
+		// province claims are stored by ID, the provinces with that name are
+		// matched by their IDs
+		datasetId, err := campaignDataset(ctx, s.db, campaignId)
+		if err != nil {
+			return nil, err
+		}
+		provinces, err := matchProvinces(ctx, s.db, datasetId, filter.Zone)
+		if err != nil {
+			return nil, err
+		}
+		conds := []string{
+			"fold_name(json_extract(payload, '$.val')) = ?",
+			"fold_name(json_extract(payload, '$.previous_val')) = ?",
+		}
+		params = append(params, foldName(filter.Zone), foldName(filter.Zone))
+		for _, prefix := range []string{"", "previous_"} {
+			for _, p := range provinces {
+				conds = append(conds, fmt.Sprintf("(json_extract(payload, '$.%[1]sclaim_type') = 'province' AND json_extract(payload, '$.%[1]sval') = ?)", prefix))
+				params = append(params, p.ID)
+			}
+		}
+		query += fmt.Sprintf(" AND (%s)", strings.Join(conds, " OR "))
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND created_at >= ?`
+		params = append(params, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		query += ` AND created_at < ?`
+		params = append(params, filter.Until.UTC())
+	}
+	query += ` ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]ClaimEvent, 0)
+	for rows.Next() {
+		e := ClaimEvent{}
+		var (
+			rawType    string
+			rawPayload string
+		)
+		if err := rows.Scan(&e.ID, &e.ClaimID, &rawType, &e.UserID, &e.Timestamp, &rawPayload); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		e.Type = EventType(rawType)
+		if err := json.Unmarshal([]byte(rawPayload), &e.Payload); err != nil {
+			return nil, fmt.Errorf("failed to decode event payload: %w", err)
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// ListClaimsAt rebuilds the campaign's claims as they were at the given time by
+// replaying the event log. Claims are returned in the order they were made,
+// with their creation date. Claims made before the event log existed start it
+// with a created event, see migration 0006.
+func (s *Store) ListClaimsAt(ctx context.Context, campaignId int, t time.Time) ([]Claim, error) {
+	events, err := s.ClaimHistory(ctx, campaignId, HistoryFilter{Until: t.Add(time.Nanosecond)})
+	if err != nil {
+		return nil, err
+	}
+
+	order := make([]int, 0)
+	createdAt := make(map[int]time.Time)
+	board := make(map[int]Claim)
+	for _, e := range events {
+		switch e.Type {
+		case EVENT_TYPE_CREATED, EVENT_TYPE_RESTORED:
+			if _, ok := createdAt[e.ClaimID]; !ok {
+				order = append(order, e.ClaimID)
+				createdAt[e.ClaimID] = e.Timestamp
+			}
+			board[e.ClaimID] = Claim{
+				ID:        e.ClaimID,
+				Player:    e.Payload.Player,
+				UserID:    e.Payload.UserID,
+				Name:      e.Payload.Name,
+				Type:      e.Payload.ClaimType,
+				CreatedAt: createdAt[e.ClaimID],
+			}
+		case EVENT_TYPE_TRANSFERRED:
+			if c, ok := board[e.ClaimID]; ok {
+				c.Player, c.UserID = e.Payload.Player, e.Payload.UserID
+				board[e.ClaimID] = c
+			}
+		case EVENT_TYPE_REMAPPED:
+			if c, ok := board[e.ClaimID]; ok {
+				c.Type, c.Name = e.Payload.ClaimType, e.Payload.Name
+				board[e.ClaimID] = c
+			}
+		case EVENT_TYPE_RELEASED, EVENT_TYPE_FLUSHED:
+			delete(board, e.ClaimID)
+		}
+	}
+
+	claims := make([]Claim, 0, len(board))
+	for _, id := range order {
+		if c, ok := board[id]; ok {
+			claims = append(claims, c)
+		}
+	}
+	return claims, nil
+}
+
+// recordEvent appends an event to the log as part of the transaction making
+// the change.
+func recordEvent(ctx context.Context, tx *sql.Tx, campaignId, claimId int, eventType EventType, userId string, payload EventPayload) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode event payload: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO claim_events (campaign_id, claim_id, event_type, userid, created_at, payload)
+	VALUES (?, ?, ?, ?, ?, ?)`, campaignId, claimId, eventType, userId, time.Now().UTC(), string(raw))
+	if err != nil {
+		return fmt.Errorf("failed to insert %s event: %w", eventType, err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimHistory(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestClaimHistory"))
+	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	start := time.Now()
+	genoaId, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Genoa", CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+	italyId, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Italy", CLAIM_TYPE_REGION)
+	assert.NoError(t, err)
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000002", "bar", "Scandinavia", CLAIM_TYPE_REGION)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.DeleteClaim(context.TODO(), campaignId, genoaId, "000000000000000001"))
+	// only the owner can transfer a claim
+	assert.ErrorIs(t, store.TransferClaim(context.TODO(), campaignId, italyId, "000000000000000002", "000000000000000002", "bar"), ErrNoSuchClaim)
+	assert.NoError(t, store.TransferClaim(context.TODO(), campaignId, italyId, "000000000000000001", "000000000000000002", "bar"))
+	middle := time.Now()
+	_, err = store.Flush(context.TODO(), campaignId, "000000000000000003", "test")
+	assert.NoError(t, err)
+
+	events, err := store.ClaimHistory(context.TODO(), campaignId, HistoryFilter{})
+	assert.NoError(t, err)
+	types := make([]EventType, 0, len(events))
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []EventType{
+		EVENT_TYPE_CREATED,
+		EVENT_TYPE_CREATED,
+		EVENT_TYPE_CREATED,
+		EVENT_TYPE_RELEASED,
+		EVENT_TYPE_TRANSFERRED,
+		EVENT_TYPE_FLUSHED,
+		EVENT_TYPE_FLUSHED,
+	}, types)
+	assert.Equal(t, "000000000000000003", events[5].UserID)
+	assert.Equal(t, EventPayload{
+		Player:         "bar",
+		UserID:         "000000000000000002",
+		ClaimType:      CLAIM_TYPE_REGION,
+		Name:           "Italy",
+		PreviousPlayer: "foo",
+		PreviousUserID: "000000000000000001",
+	}, events[4].Payload)
+
+	// 'foo' created two claims, released one and transferred the other
+	events, err = store.ClaimHistory(context.TODO(), campaignId, HistoryFilter{UserID: "000000000000000001"})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(events))
+
+	events, err = store.ClaimHistory(context.TODO(), campaignId, HistoryFilter{Zone: "italy"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, italyId, events[0].ClaimID)
+
+	events, err = store.ClaimHistory(context.TODO(), campaignId, HistoryFilter{Since: middle})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(events))
+
+	events, err = store.ClaimHistory(context.TODO(), campaignId, HistoryFilter{Since: start, Until: middle})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(events))
+
+	// province claims are stored by ID, and found by name
+	stockholmId, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "stockholm", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Genoa", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+	for _, zone := range []string{"Stockholm", "1"} {
+		events, err = store.ClaimHistory(context.TODO(), campaignId, HistoryFilter{Zone: zone})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(events), zone)
+		assert.Equal(t, stockholmId, events[0].ClaimID, zone)
+	}
+	// both the trade node, created then released, and the province
+	events, err = store.ClaimHistory(context.TODO(), campaignId, HistoryFilter{Zone: "genoa"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(events))
+}
+
+func TestListClaimsAt(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestListClaimsAt"))
+	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	before := time.Now()
+	bordeauxId, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Bordeaux", CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000002", "bar", "Scandinavia", CLAIM_TYPE_REGION)
+	assert.NoError(t, err)
+	afterClaims := time.Now()
+
+	assert.NoError(t, store.TransferClaim(context.TODO(), campaignId, bordeauxId, "000000000000000001", "000000000000000002", "bar"))
+	afterTransfer := time.Now()
+
+	_, err = store.Flush(context.TODO(), campaignId, "000000000000000001", "test")
+	assert.NoError(t, err)
+	afterFlush := time.Now()
+
+	claims, err := store.ListClaimsAt(context.TODO(), campaignId, before)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(claims))
+
+	claims, err = store.ListClaimsAt(context.TODO(), campaignId, afterClaims)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(claims))
+	assert.Equal(t, "Bordeaux", claims[0].Name)
+	assert.Equal(t, "foo", claims[0].Player)
+	assert.Equal(t, "Scandinavia", claims[1].Name)
+	assert.True(t, claims[0].CreatedAt.Before(claims[1].CreatedAt))
+
+	claims, err = store.ListClaimsAt(context.TODO(), campaignId, afterTransfer)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(claims))
+	assert.Equal(t, "bar", claims[0].Player)
+
+	claims, err = store.ListClaimsAt(context.TODO(), campaignId, afterFlush)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(claims))
+}
@@ -0,0 +1,83 @@
+package themis
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// excludedCondition is a SQL condition matching the provinces excluded from
+// the claim whose ID is given by the claimId expression. It must be used in a
+// query where the `provinces` table is available.
+func excludedCondition(claimId string) string {
+	conds := make([]string, 0, len(ClaimTypes))
+	for _, ct := range ClaimTypes {
+		conds = append(conds, fmt.Sprintf("(claim_exclusions.claim_type = '%s' AND claim_exclusions.val = provinces.%s)", string(ct), claimTypeToColumn[ct]))
+	}
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM claim_exclusions WHERE claim_exclusions.claim_id = %s AND (%s))", claimId, strings.Join(conds, " OR "))
+}
+
+// resolveExclusions checks that every excluded zone overlaps the claimed zone,
+// whose name is already resolved, and returns them with their canonical
+// names. Excluded zones are matched like claims, see resolveZoneID.
+func resolveExclusions(ctx context.Context, q querier, datasetId int, name string, claimType ClaimType, exclusions []Zone) ([]Zone, error) {
+	resolved := make([]Zone, 0, len(exclusions))
+	for _, ex := range exclusions {
+		column, ok := claimTypeToColumn[ex.Type]
+		if !ok {
+			return nil, fmt.Errorf("no claim type matching '%s'", ex.Type)
+		}
+
+		canonical, err := resolveZone(ctx, q, datasetId, ex)
+		if err != nil {
+			return nil, err
+		}
+
+		var count int
+		err = q.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(1) FROM provinces
+		WHERE provinces.dataset_id = ? AND provinces.%s = ? AND provinces.%s = ?`, claimTypeToColumn[claimType], column),
+			datasetId, name, canonical).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("%s is not part of %s %s", ex, claimType, name)
+		}
+
+		resolved = append(resolved, Zone{Type: ex.Type, Name: canonical})
+	}
+	return resolved, nil
+}
+
+func insertExclusions(ctx context.Context, tx *sql.Tx, claimId int, exclusions []Zone) error {
+	for _, ex := range exclusions {
+		_, err := tx.ExecContext(ctx, "INSERT INTO claim_exclusions (claim_id, claim_type, val) VALUES (?, ?, ?)", claimId, ex.Type, ex.Name)
+		if err != nil {
+			return fmt.Errorf("failed to insert exclusion: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) listExclusions(ctx context.Context, claimId int) ([]Zone, error) {
+	return claimExclusions(ctx, s.db, claimId)
+}
+
+func claimExclusions(ctx context.Context, q querier, claimId int) ([]Zone, error) {
+	rows, err := q.QueryContext(ctx, "SELECT claim_type, val FROM claim_exclusions WHERE claim_id = ?", claimId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	exclusions := make([]Zone, 0)
+	for rows.Next() {
+		z := Zone{}
+		if err := rows.Scan(&z.Type, &z.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		exclusions = append(exclusions, z)
+	}
+	return exclusions, rows.Err()
+}
@@ -0,0 +1,65 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimExclusions(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestClaimExclusions"))
+	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	id, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "North Germany", CLAIM_TYPE_REGION,
+		Zone{Type: CLAIM_TYPE_AREA, Name: "lower rhineland"},
+		Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Stade"},
+	)
+	assert.NoError(t, err)
+
+	detail, err := store.DescribeClaim(context.TODO(), campaignId, id)
+	assert.NoError(t, err)
+	// 81 provinces in North Germany, minus the 4 of Lower Rhineland and Stade
+	assert.Equal(t, 76, len(detail.Provinces))
+	assert.NotContains(t, detail.Provinces, "Aachen")
+	assert.NotContains(t, detail.Provinces, "Stade")
+	assert.Contains(t, detail.Provinces, "Oldenburg")
+	assert.Equal(t, []Zone{
+		{Type: CLAIM_TYPE_AREA, Name: "Lower Rhineland"},
+		{Type: CLAIM_TYPE_PROVINCE, Name: "54"},
+	}, detail.Exclusions)
+
+	// the excluded zones are free for the taking
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000002", "bar", "Lower Rhineland", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000002", "bar", "54", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+
+	conflicts, err := store.FindConflicts(context.TODO(), campaignId, "000000000000000002", "Weser", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(conflicts))
+
+	// exclusions of the zone being claimed are not conflicting either
+	conflicts, err = store.FindConflicts(context.TODO(), campaignId, "000000000000000002", "Weser", CLAIM_TYPE_AREA,
+		Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Bremen"},
+		Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Verden"},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []Conflict{
+		{Province: "Oldenburg", Player: "foo", ClaimType: CLAIM_TYPE_REGION, Claim: "North Germany", ClaimID: id},
+	}, conflicts)
+
+	// exclusions must be part of the claimed zone
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000003", "baz", "Gascony", CLAIM_TYPE_AREA,
+		Zone{Type: CLAIM_TYPE_AREA, Name: "Lower Rhineland"},
+	)
+	assert.ErrorContains(t, err, "is not part of")
+
+	events, err := store.ClaimHistory(context.TODO(), campaignId, HistoryFilter{Zone: "North Germany"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, 2, len(events[0].Payload.Exclusions))
+}
@@ -0,0 +1,381 @@
+package themis
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Game files read by LoadGameFiles, relative to the game or mod directory.
+const (
+	GAME_FILE_DEFINITIONS  = "map/definition.csv"
+	GAME_FILE_AREAS        = "map/area.txt"
+	GAME_FILE_REGIONS      = "map/region.txt"
+	GAME_FILE_SUPERREGIONS = "map/superregion.txt"
+	GAME_FILE_TRADE_NODES  = "common/tradenodes/00_tradenodes.txt"
+
+	// optional, all provinces are land provinces without them
+	GAME_FILE_DEFAULT_MAP = "map/default.map"
+	GAME_FILE_CLIMATE     = "map/climate.txt"
+	GAME_FILE_CONTINENTS  = "map/continent.txt"
+
+	// read by LoadAdjacencies, adjacencies.csv is optional
+	GAME_FILE_PROVINCES_MAP = "map/provinces.bmp"
+	GAME_FILE_ADJACENCIES   = "map/adjacencies.csv"
+)
+
+var localisationPattern = regexp.MustCompile(`^\s*([^\s:#]+):\d*\s*"(.*)"`)
+
+// LoadGameFiles reads the provinces and the zones they belong to from the
+// files of an EU4 installation or mod. Names are taken from the English
+// localisation files when there are any, otherwise they are derived from the
+// keys of the game files, e.g. lower_rhineland_area is named Lower Rhineland.
+// Only provinces that are part of an area or a trade node, and the seas and
+// lakes, are returned. The game files don't hold the development of the
+// provinces, it is left at zero.
+func LoadGameFiles(fsys fs.FS) ([]Province, error) {
+	names, err := loadLocalisation(fsys)
+	if err != nil {
+		return nil, err
+	}
+	localise := localiser(names)
+
+	provinces, err := readDefinitions(fsys)
+	if err != nil {
+		return nil, err
+	}
+	for id, p := range provinces {
+		if name, ok := names[fmt.Sprintf("PROV%d", id)]; ok {
+			p.Name = name
+		}
+	}
+
+	problems := make([]string, 0)
+	included := make(map[int]bool)
+	member := func(file string, node ScriptNode, id string) *Province {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s line %d: invalid province ID %q in %s", file, node.Line, id, node.Key))
+			return nil
+		}
+		p, ok := provinces[n]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s line %d: province %d of %s is not in %s", file, node.Line, n, node.Key, GAME_FILE_DEFINITIONS))
+			return nil
+		}
+		included[n] = true
+		return p
+	}
+
+	areas, err := readScript(fsys, GAME_FILE_AREAS, true)
+	if err != nil {
+		return nil, err
+	}
+	areaNames := make(map[string]string)
+	provinceArea := make(map[int]string)
+	for _, area := range areas {
+		if !area.IsBlock {
+			continue
+		}
+		areaNames[area.Key] = localise(area.Key, "_area")
+		for _, id := range area.Values() {
+			if p := member(GAME_FILE_AREAS, area, id); p != nil {
+				if p.Area != "" {
+					problems = append(problems, fmt.Sprintf("%s line %d: province %d is in both %s and %s", GAME_FILE_AREAS, area.Line, p.ID, p.Area, areaNames[area.Key]))
+				}
+				p.Area = areaNames[area.Key]
+				provinceArea[p.ID] = area.Key
+			}
+		}
+	}
+
+	regions, err := readScript(fsys, GAME_FILE_REGIONS, true)
+	if err != nil {
+		return nil, err
+	}
+	areaRegion := make(map[string]string)
+	regionNames := make(map[string]string)
+	for _, region := range regions {
+		list, ok := region.Child("areas")
+		if !ok {
+			continue
+		}
+		regionNames[region.Key] = localise(region.Key, "_region")
+		for _, area := range list.Values() {
+			if _, ok := areaNames[area]; !ok {
+				problems = append(problems, fmt.Sprintf("%s line %d: unknown area %s in %s", GAME_FILE_REGIONS, region.Line, area, region.Key))
+				continue
+			}
+			if other, ok := areaRegion[area]; ok {
+				problems = append(problems, fmt.Sprintf("%s line %d: area %s is in both %s and %s", GAME_FILE_REGIONS, region.Line, area, other, region.Key))
+			}
+			areaRegion[area] = region.Key
+		}
+	}
+
+	superregions, err := readScript(fsys, GAME_FILE_SUPERREGIONS, true)
+	if err != nil {
+		return nil, err
+	}
+	regionSuperregion := make(map[string]string)
+	for _, superregion := range superregions {
+		if !superregion.IsBlock {
+			continue
+		}
+		// superregions also list flags like restrict_charter, only regions
+		// are kept.
+		for _, region := range superregion.Values() {
+			if _, ok := regionNames[region]; ok {
+				regionSuperregion[region] = superregion.Key
+			}
+		}
+	}
+
+	for id, area := range provinceArea {
+		region, ok := areaRegion[area]
+		if !ok {
+			continue
+		}
+		provinces[id].Region = regionNames[region]
+		if superregion, ok := regionSuperregion[region]; ok {
+			provinces[id].Superregion = localise(superregion, "_superregion")
+		}
+	}
+
+	nodes, err := readScript(fsys, GAME_FILE_TRADE_NODES, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		members, ok := node.Child("members")
+		if !ok {
+			continue
+		}
+		name := localise(node.Key, "")
+		for _, id := range members.Values() {
+			if p := member(GAME_FILE_TRADE_NODES, node, id); p != nil {
+				p.TradeNode = name
+			}
+		}
+	}
+
+	continents, err := readScript(fsys, GAME_FILE_CONTINENTS, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, continent := range continents {
+		if !continent.IsBlock {
+			continue
+		}
+		for _, id := range continent.Values() {
+			if n, err := strconv.Atoi(id); err == nil && provinces[n] != nil {
+				provinces[n].Continent = localise(continent.Key, "")
+			}
+		}
+	}
+
+	for _, t := range []struct {
+		file string
+		key  string
+		typ  string
+	}{
+		{GAME_FILE_DEFAULT_MAP, "sea_starts", "Sea"},
+		{GAME_FILE_DEFAULT_MAP, "lakes", "Lake"},
+		{GAME_FILE_CLIMATE, "impassable", "Wasteland"},
+	} {
+		script, err := readScript(fsys, t.file, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range script {
+			if node.Key != t.key {
+				continue
+			}
+			for _, id := range node.Values() {
+				if n, err := strconv.Atoi(id); err == nil && provinces[n] != nil {
+					provinces[n].Type = t.typ
+					if t.typ != "Wasteland" {
+						included[n] = true
+					}
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, ValidationError{Problems: problems}
+	}
+
+	result := make([]Province, 0, len(included))
+	for id := range included {
+		p := *provinces[id]
+		if p.Type != "Land" && p.Type != "Wasteland" {
+			// seas and lakes are not part of the land hierarchy
+			p.Area, p.Region, p.Superregion = "", "", ""
+		}
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	if err := ValidateProvinces(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readDefinitions reads the provinces listed in map/definition.csv.
+func readDefinitions(fsys fs.FS) (map[int]*Province, error) {
+	records, err := readDefinitionRecords(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	provinces := make(map[int]*Province)
+	for id, record := range records {
+		provinces[id] = &Province{ID: id, Name: strings.TrimSpace(record[4]), Type: "Land", Modifiers: make([]string, 0)}
+	}
+	return provinces, nil
+}
+
+// readDefinitionRecords returns the lines of map/definition.csv by province
+// ID. The file is separated by semicolons, with the ID in the first column,
+// the color of the province on the map in the next three and the name in the
+// fifth one.
+func readDefinitionRecords(fsys fs.FS) (map[int][]string, error) {
+	raw, err := readGameFile(fsys, GAME_FILE_DEFINITIONS, true)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(raw))
+	reader.Comma = ';'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records := make(map[int][]string)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", GAME_FILE_DEFINITIONS, err)
+		}
+		// skips the header and the trailing empty lines
+		id, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil || len(record) < 5 {
+			continue
+		}
+		records[id] = record
+	}
+	return records, nil
+}
+
+// loadLocalisation reads the English localisation keys, e.g. PROV1 for the
+// name of the province with ID 1. Later files override earlier ones, like
+// the game does with the replace folder.
+func loadLocalisation(fsys fs.FS) (map[string]string, error) {
+	names := make(map[string]string)
+	files := make([]string, 0)
+	err := fs.WalkDir(fsys, "localisation", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(p, "_l_english.yml") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil && !isNotExist(err) {
+		return nil, fmt.Errorf("failed to list localisation files: %w", err)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		raw, err := readGameFile(fsys, file, true)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(raw))
+		for scanner.Scan() {
+			if m := localisationPattern.FindStringSubmatch(scanner.Text()); m != nil {
+				names[m[1]] = m[2]
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+	}
+	return names, nil
+}
+
+// localiser returns a function naming the keys of the game files, from the
+// localisation when it has them, otherwise from the key without its suffix.
+func localiser(names map[string]string) func(key, suffix string) string {
+	return func(key, suffix string) string {
+		if name, ok := names[key]; ok {
+			return name
+		}
+		return humanizeKey(strings.TrimSuffix(key, suffix))
+	}
+}
+
+func readScript(fsys fs.FS, name string, required bool) ([]ScriptNode, error) {
+	raw, err := readGameFile(fsys, name, required)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	nodes, err := ParseScript(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nodes, nil
+}
+
+// readGameFile returns the content of the file as UTF-8. Most game files are
+// encoded in Windows-1252, they are converted unless they are already valid
+// UTF-8. Missing optional files are returned as nil.
+func readGameFile(fsys fs.FS, name string, required bool) ([]byte, error) {
+	raw, err := fs.ReadFile(fsys, path.Clean(name))
+	if isNotExist(err) && !required {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read game file %s: %w", name, err)
+	}
+	if utf8.Valid(raw) {
+		return raw, nil
+	}
+
+	converted := make([]rune, 0, len(raw))
+	for _, b := range raw {
+		converted = append(converted, rune(b))
+	}
+	return []byte(string(converted)), nil
+}
+
+func isNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// humanizeKey turns a game file key like lower_rhineland into Lower Rhineland.
+func humanizeKey(key string) string {
+	words := strings.Split(key, "_")
+	for i, w := range words {
+		if w != "" {
+			r, size := utf8.DecodeRuneInString(w)
+			words[i] = strings.ToUpper(string(r)) + w[size:]
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,56 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadGameFiles(t *testing.T) {
+	provinces, err := LoadGameFiles(os.DirFS("testdata/eu4"))
+	assert.NoError(t, err)
+	assert.Equal(t, 9, len(provinces))
+
+	assert.Equal(t, Province{ID: 2, Name: "Östergötland", Type: "Land", TradeNode: "Baltic Sea", Area: "Östergötland",
+		Region: "Scandinavia", Superregion: "Europe", Continent: "Europe", Modifiers: []string{}}, provinces[1])
+	assert.Equal(t, "Skaneland", provinces[5].Area)
+	assert.Equal(t, "Lübeck", provinces[5].TradeNode)
+	assert.Equal(t, "Wasteland", provinces[6].Type)
+	assert.Equal(t, "Lapland", provinces[6].Area)
+	assert.Equal(t, Province{ID: 8, Name: "Baltic Sea", Type: "Sea", TradeNode: "Baltic Sea", Modifiers: []string{}}, provinces[7])
+	assert.Equal(t, "Lake", provinces[8].Type)
+
+	// a mod adding a province to an area without defining it
+	broken := fstest.MapFS{}
+	for _, name := range []string{GAME_FILE_DEFINITIONS, GAME_FILE_REGIONS, GAME_FILE_SUPERREGIONS, GAME_FILE_TRADE_NODES} {
+		raw, err := os.ReadFile("testdata/eu4/" + name)
+		assert.NoError(t, err)
+		broken[name] = &fstest.MapFile{Data: raw}
+	}
+	broken[GAME_FILE_AREAS] = &fstest.MapFile{Data: []byte("svealand_area = { 1 11 }\nostergotland_area = { 1 }")}
+	_, err = LoadGameFiles(broken)
+	var verr ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Contains(t, verr.Problems, "map/area.txt line 1: province 11 of svealand_area is not in map/definition.csv")
+	assert.Contains(t, verr.Problems, "map/area.txt line 2: province 1 is in both Svealand and Ostergotland")
+
+	delete(broken, GAME_FILE_TRADE_NODES)
+	_, err = LoadGameFiles(broken)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// the game files replace the provinces of the CSV data
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestLoadGameFiles"))
+	assert.NoError(t, err)
+	report, err := store.ImportProvinces(context.TODO(), DEFAULT_DATASET, provinces)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, len(report.AddedProvinces)+len(report.Renamed)+report.Updated)
+	assert.Contains(t, report.AddedZones, Zone{Type: CLAIM_TYPE_AREA, Name: "Östergötland"})
+
+	listed, err := store.ListProvinces(context.TODO(), ProvinceFilter{Region: "Scandinavia"})
+	assert.NoError(t, err)
+	assert.Equal(t, 7, len(listed))
+}
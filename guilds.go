@@ -0,0 +1,102 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Guild is a Discord server the application has been added to.
+type Guild struct {
+	ID       string
+	Name     string
+	JoinedAt time.Time
+}
+
+// AddGuild records that the application is present in the guild. It is safe to
+// call it every time the guild becomes available, the original join date is
+// preserved.
+func (s *Store) AddGuild(ctx context.Context, guildId, name string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO guilds (guild_id, name) VALUES (?, ?)
+	ON CONFLICT(guild_id) DO UPDATE SET name = excluded.name`, guildId, name)
+	if err != nil {
+		return fmt.Errorf("failed to upsert guild: %w", err)
+	}
+	return nil
+}
+
+// ListGuilds returns all the guilds the application is present in.
+func (s *Store) ListGuilds(ctx context.Context) ([]Guild, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT guild_id, name, joined_at FROM guilds")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	guilds := make([]Guild, 0)
+	for rows.Next() {
+		g := Guild{}
+		if err := rows.Scan(&g.ID, &g.Name, &g.JoinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		guilds = append(guilds, g)
+	}
+
+	return guilds, rows.Err()
+}
+
+// RemoveGuild deletes everything the store knows about a guild: its campaigns
+// and all of their claims, history and archives.
+func (s *Store) RemoveGuild(ctx context.Context, guildId string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM claim_exclusions WHERE claim_id IN (
+		SELECT claims.id FROM claims JOIN campaigns ON claims.campaign_id = campaigns.id WHERE campaigns.guild_id = ?)`, guildId)
+	if err != nil {
+		return fmt.Errorf("failed to delete claim exclusions: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM claims WHERE campaign_id IN (SELECT id FROM campaigns WHERE guild_id = ?)", guildId)
+	if err != nil {
+		return fmt.Errorf("failed to delete claims: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM claim_events WHERE campaign_id IN (SELECT id FROM campaigns WHERE guild_id = ?)", guildId)
+	if err != nil {
+		return fmt.Errorf("failed to delete claim events: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM archived_exclusions WHERE archive_id IN (SELECT archives.id FROM archives JOIN campaigns ON archives.campaign_id = campaigns.id WHERE campaigns.guild_id = ?)", guildId)
+	if err != nil {
+		return fmt.Errorf("failed to delete archived exclusions: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM archived_claims WHERE archive_id IN (SELECT archives.id FROM archives JOIN campaigns ON archives.campaign_id = campaigns.id WHERE campaigns.guild_id = ?)", guildId)
+	if err != nil {
+		return fmt.Errorf("failed to delete archived claims: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM archives WHERE campaign_id IN (SELECT id FROM campaigns WHERE guild_id = ?)", guildId)
+	if err != nil {
+		return fmt.Errorf("failed to delete archives: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM campaigns WHERE guild_id = ?", guildId)
+	if err != nil {
+		return fmt.Errorf("failed to delete campaigns: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM guilds WHERE guild_id = ?", guildId)
+	if err != nil {
+		return fmt.Errorf("failed to delete guild: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveGuild(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestRemoveGuild"))
+	assert.NoError(t, err)
+
+	const otherGuildId = "000000000000000200"
+	assert.NoError(t, store.AddGuild(context.TODO(), TEST_GUILD_ID, "test"))
+	assert.NoError(t, store.AddGuild(context.TODO(), otherGuildId, "other"))
+	// adding a guild twice is a no-op
+	assert.NoError(t, store.AddGuild(context.TODO(), otherGuildId, "other"))
+
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, "first")
+	assert.NoError(t, err)
+	otherCampaignId, err := store.CreateCampaign(context.TODO(), otherGuildId, "first")
+	assert.NoError(t, err)
+
+	claimId, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Genoa", CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+	// the same player can hold the same zone in two different guilds
+	_, err = store.Claim(context.TODO(), otherCampaignId, "000000000000000002", "bar", "Genoa", CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+
+	// claims aren't visible through another guild's campaign
+	_, err = store.DescribeClaim(context.TODO(), otherCampaignId, claimId)
+	assert.ErrorIs(t, err, ErrNoSuchClaim)
+
+	guilds, err := store.ListGuilds(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(guilds))
+
+	assert.NoError(t, store.RemoveGuild(context.TODO(), TEST_GUILD_ID))
+
+	_, err = store.DescribeClaim(context.TODO(), campaignId, claimId)
+	assert.ErrorIs(t, err, ErrNoSuchClaim)
+	campaigns, err := store.ListCampaigns(context.TODO(), TEST_GUILD_ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(campaigns))
+
+	total, _, err := store.CountClaims(context.TODO(), otherCampaignId)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	guilds, err = store.ListGuilds(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(guilds))
+	assert.Equal(t, otherGuildId, guilds[0].ID)
+}
@@ -0,0 +1,670 @@
+package themis
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProvinceTypes are the types of provinces found on the map.
+var ProvinceTypes = []string{"Land", "Sea", "Open sea", "Inland sea", "Lake", "Wasteland"}
+
+// maxValidationErrors caps the number of problems listed in a
+// ValidationError, a broken file usually has the same problem on every line.
+const maxValidationErrors = 20
+
+// ValidationError lists the problems found in a province dataset.
+type ValidationError struct {
+	Problems []string
+}
+
+func (ve ValidationError) Error() string {
+	problems := ve.Problems
+	more := ""
+	if len(problems) > maxValidationErrors {
+		more = fmt.Sprintf("\n... and %d more", len(problems)-maxValidationErrors)
+		problems = problems[:maxValidationErrors]
+	}
+	return fmt.Sprintf("found %d problems in provinces data:\n%s%s", len(ve.Problems), strings.Join(problems, "\n"), more)
+}
+
+// ParseProvincesCSV reads provinces from a CSV file with a header row, in the
+// format of data/eu4-provinces.csv. Columns are matched by name and can be in
+// any order. Modifiers are a single quoted cell with one modifier per line.
+// The provinces are validated with ValidateProvinces.
+func ParseProvincesCSV(r io.Reader) ([]Province, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ValidationError{Problems: []string{"file is empty"}}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	missing := make([]string, 0)
+	for _, required := range []string{"id", "name", "type"} {
+		if _, ok := columns[required]; !ok {
+			missing = append(missing, fmt.Sprintf("missing required column %q", required))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, ValidationError{Problems: missing}
+	}
+
+	problems := make([]string, 0)
+	provinces := make([]Province, 0)
+	lines := make([]int, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read record: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		number := func(name string) int {
+			raw := cell(name)
+			if raw == "" {
+				return 0
+			}
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				problems = append(problems, fmt.Sprintf("line %d: invalid %s %q", line, name, raw))
+			}
+			return n
+		}
+
+		p := Province{
+			Name:           cell("name"),
+			Type:           cell("type"),
+			BaseTax:        number("bt"),
+			BaseProduction: number("bp"),
+			BaseManpower:   number("bm"),
+			TradeGood:      cell("trade good"),
+			TradeNode:      cell("trade node"),
+			Area:           cell("area"),
+			Region:         cell("region"),
+			Superregion:    cell("superregion"),
+			Continent:      cell("continent"),
+			Modifiers:      make([]string, 0),
+		}
+		p.ID, err = strconv.Atoi(cell("id"))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: invalid id %q", line, cell("id")))
+		}
+		p.Development = number("development")
+		if cell("development") == "" {
+			p.Development = p.BaseTax + p.BaseProduction + p.BaseManpower
+		}
+		for _, m := range strings.Split(cell("modifiers"), "\n") {
+			if m = strings.TrimSpace(m); m != "" {
+				p.Modifiers = append(p.Modifiers, m)
+			}
+		}
+		sort.Strings(p.Modifiers)
+
+		provinces = append(provinces, p)
+		lines = append(lines, line)
+	}
+
+	problems = append(problems, validateProvinces(provinces, func(i int) string {
+		return fmt.Sprintf("line %d", lines[i])
+	})...)
+	if len(problems) > 0 {
+		return nil, ValidationError{Problems: problems}
+	}
+	return provinces, nil
+}
+
+// ValidateProvinces checks that a province dataset can be imported: IDs are
+// positive and unique, provinces have a name and a known type, and the zones
+// form a hierarchy where each area belongs to a single region and each region
+// to a single superregion.
+func ValidateProvinces(provinces []Province) error {
+	problems := validateProvinces(provinces, func(i int) string {
+		return fmt.Sprintf("province #%d", provinces[i].ID)
+	})
+	if len(problems) > 0 {
+		return ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func validateProvinces(provinces []Province, position func(int) string) []string {
+	problems := make([]string, 0)
+	types := make(map[string]bool)
+	for _, t := range ProvinceTypes {
+		types[t] = true
+	}
+
+	ids := make(map[int]int)
+	areaRegion := make(map[string]string)
+	regionSuperregion := make(map[string]string)
+	for i, p := range provinces {
+		if p.ID <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: province ID must be positive", position(i)))
+		} else if first, ok := ids[p.ID]; ok {
+			problems = append(problems, fmt.Sprintf("%s: province ID %d is already used on %s", position(i), p.ID, position(first)))
+		} else {
+			ids[p.ID] = i
+		}
+		if p.Name == "" {
+			problems = append(problems, fmt.Sprintf("%s: missing province name", position(i)))
+		}
+		if !types[p.Type] {
+			problems = append(problems, fmt.Sprintf("%s: unknown province type %q, must be one of %s", position(i), p.Type, strings.Join(ProvinceTypes, ", ")))
+		}
+
+		// regions and superregions are derived from the area, they can't be
+		// stored without one.
+		if p.Area == "" && p.Region != "" {
+			problems = append(problems, fmt.Sprintf("%s: province has a region but no area", position(i)))
+		}
+		if p.Region == "" && p.Superregion != "" {
+			problems = append(problems, fmt.Sprintf("%s: province has a superregion but no region", position(i)))
+		}
+		if p.Area != "" {
+			if region, ok := areaRegion[p.Area]; ok && region != p.Region {
+				problems = append(problems, fmt.Sprintf("%s: area %s is in region %q but was in %q before", position(i), p.Area, p.Region, region))
+			} else {
+				areaRegion[p.Area] = p.Region
+			}
+		}
+		if p.Region != "" {
+			if superregion, ok := regionSuperregion[p.Region]; ok && superregion != p.Superregion {
+				problems = append(problems, fmt.Sprintf("%s: region %s is in superregion %q but was in %q before", position(i), p.Region, p.Superregion, superregion))
+			} else {
+				regionSuperregion[p.Region] = p.Superregion
+			}
+		}
+	}
+	return problems
+}
+
+// ProvinceRename is a province or zone that kept its ID or its provinces but
+// changed name.
+type ProvinceRename struct {
+	Type ClaimType
+	ID   int // only set for provinces
+	From string
+	To   string
+}
+
+func (r ProvinceRename) String() string {
+	if r.Type == CLAIM_TYPE_PROVINCE {
+		return fmt.Sprintf("province #%d %s renamed to %s", r.ID, r.From, r.To)
+	}
+	return fmt.Sprintf("%s %s renamed to %s", r.Type, r.From, r.To)
+}
+
+// ImportReport describes the changes made by an import compared with the
+// province data that was there before.
+type ImportReport struct {
+	AddedProvinces   []Province
+	RemovedProvinces []Province
+	// Updated counts the provinces that kept their name but had any of their
+	// other attributes changed.
+	Updated      int
+	AddedZones   []Zone
+	RemovedZones []Zone
+	// Renamed lists the provinces which changed name and the zones which
+	// changed name but kept the exact same provinces.
+	Renamed []ProvinceRename
+	// Remapped lists the claims and exclusions moved to a new zone because
+	// theirs was renamed, merged or mapped explicitly.
+	Remapped []ClaimRemap
+	// Flagged lists the claims left pointing to a zone that doesn't exist
+	// anymore, their owner has to pick a new zone with RemapClaim.
+	Flagged []FlaggedClaim
+}
+
+// Empty returns true if the import didn't change anything.
+func (r ImportReport) Empty() bool {
+	return len(r.AddedProvinces) == 0 && len(r.RemovedProvinces) == 0 && r.Updated == 0 &&
+		len(r.AddedZones) == 0 && len(r.RemovedZones) == 0 && len(r.Renamed) == 0 &&
+		len(r.Remapped) == 0 && len(r.Flagged) == 0
+}
+
+func (r ImportReport) String() string {
+	if r.Empty() {
+		return "no changes"
+	}
+
+	sb := strings.Builder{}
+	for _, p := range r.AddedProvinces {
+		sb.WriteString(fmt.Sprintf("added province %s\n", p))
+	}
+	for _, p := range r.RemovedProvinces {
+		sb.WriteString(fmt.Sprintf("removed province %s\n", p))
+	}
+	for _, z := range r.AddedZones {
+		sb.WriteString(fmt.Sprintf("added %s %s\n", z.Type, z.Name))
+	}
+	for _, z := range r.RemovedZones {
+		sb.WriteString(fmt.Sprintf("removed %s %s\n", z.Type, z.Name))
+	}
+	for _, rn := range r.Renamed {
+		sb.WriteString(rn.String() + "\n")
+	}
+	for _, rm := range r.Remapped {
+		sb.WriteString(rm.String() + "\n")
+	}
+	for _, f := range r.Flagged {
+		sb.WriteString(fmt.Sprintf("flagged %s\n", f))
+	}
+	sb.WriteString(fmt.Sprintf("%d added, %d removed, %d renamed, %d updated provinces; %d added, %d removed zones",
+		len(r.AddedProvinces), len(r.RemovedProvinces), r.renamedProvinces(), r.Updated, len(r.AddedZones), len(r.RemovedZones)))
+	if len(r.Remapped) > 0 || len(r.Flagged) > 0 {
+		sb.WriteString(fmt.Sprintf("; %d remapped, %d flagged claims", len(r.Remapped), len(r.Flagged)))
+	}
+	return sb.String()
+}
+
+func (r ImportReport) renamedProvinces() int {
+	count := 0
+	for _, rn := range r.Renamed {
+		if rn.Type == CLAIM_TYPE_PROVINCE {
+			count++
+		}
+	}
+	return count
+}
+
+// ImportProvinces replaces the provinces of the named dataset, creating it if
+// it doesn't exist, and reports the differences with the previous data. The
+// default dataset is used if the name is empty. Provinces are matched by ID.
+// Claims of campaigns using the dataset whose zone disappeared are moved to
+// its replacement, see migrateClaims, then the provinces they hold are
+// recomputed against the new data. The import is rolled back if it makes
+// claims of different players overlap.
+func (s *Store) ImportProvinces(ctx context.Context, dataset string, provinces []Province, mappings ...ZoneMapping) (ImportReport, error) {
+	if err := ValidateProvinces(provinces); err != nil {
+		return ImportReport{}, err
+	}
+	if err := validateMappings(provinces, mappings); err != nil {
+		return ImportReport{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ImportReport{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if dataset == "" {
+		dataset = DEFAULT_DATASET
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO datasets (name) VALUES (?) ON CONFLICT(name) DO NOTHING", dataset); err != nil {
+		return ImportReport{}, fmt.Errorf("failed to create dataset %s: %w", dataset, err)
+	}
+	datasetId, err := findDataset(ctx, tx, dataset)
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	current, err := listProvinces(ctx, tx, ProvinceFilter{Dataset: dataset})
+	if err != nil {
+		return ImportReport{}, fmt.Errorf("failed to list current provinces: %w", err)
+	}
+	report := diffProvinces(current, provinces)
+
+	if err := writeProvinces(ctx, tx, datasetId, provinces, report.RemovedProvinces); err != nil {
+		return ImportReport{}, err
+	}
+	report.Remapped, report.Flagged, err = migrateClaims(ctx, tx, datasetId, current, provinces, mappings)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	if err := refreshZoneIds(ctx, tx, datasetId); err != nil {
+		return ImportReport{}, err
+	}
+	if err := refreshClaimProvinces(ctx, tx, datasetId); err != nil {
+		return ImportReport{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ImportReport{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	s.zones.invalidate()
+	return report, nil
+}
+
+// diffProvinces compares two province datasets.
+func diffProvinces(before, after []Province) ImportReport {
+	report := ImportReport{
+		AddedProvinces:   make([]Province, 0),
+		RemovedProvinces: make([]Province, 0),
+		AddedZones:       make([]Zone, 0),
+		RemovedZones:     make([]Zone, 0),
+		Renamed:          make([]ProvinceRename, 0),
+	}
+
+	previous := make(map[int]Province)
+	for _, p := range before {
+		previous[p.ID] = p
+	}
+	next := make(map[int]bool)
+	for _, p := range after {
+		next[p.ID] = true
+		old, ok := previous[p.ID]
+		switch {
+		case !ok:
+			report.AddedProvinces = append(report.AddedProvinces, p)
+		case old.Name != p.Name:
+			report.Renamed = append(report.Renamed, ProvinceRename{Type: CLAIM_TYPE_PROVINCE, ID: p.ID, From: old.Name, To: p.Name})
+		case !sameProvince(old, p):
+			report.Updated++
+		}
+	}
+	for _, p := range before {
+		if !next[p.ID] {
+			report.RemovedProvinces = append(report.RemovedProvinces, p)
+		}
+	}
+
+	oldZones, newZones := provinceZones(before), provinceZones(after)
+	for _, ct := range ClaimTypes {
+		if ct == CLAIM_TYPE_PROVINCE {
+			continue
+		}
+
+		// a zone that disappeared is a rename if a new zone of the same type
+		// has exactly the same provinces.
+		added := make(map[string]string)
+		for name, ids := range newZones[ct] {
+			if _, ok := oldZones[ct][name]; !ok {
+				added[ids] = name
+			}
+		}
+		renamedTo := make(map[string]bool)
+		for _, name := range sortedKeys(oldZones[ct]) {
+			if _, ok := newZones[ct][name]; ok {
+				continue
+			}
+			if to, ok := added[oldZones[ct][name]]; ok && !renamedTo[to] {
+				renamedTo[to] = true
+				report.Renamed = append(report.Renamed, ProvinceRename{Type: ct, From: name, To: to})
+				continue
+			}
+			report.RemovedZones = append(report.RemovedZones, Zone{Type: ct, Name: name})
+		}
+		for _, name := range sortedKeys(newZones[ct]) {
+			if _, ok := oldZones[ct][name]; !ok && !renamedTo[name] {
+				report.AddedZones = append(report.AddedZones, Zone{Type: ct, Name: name})
+			}
+		}
+	}
+
+	return report
+}
+
+func sameProvince(a, b Province) bool {
+	return a.Name == b.Name && a.Type == b.Type && a.Development == b.Development &&
+		a.BaseTax == b.BaseTax && a.BaseProduction == b.BaseProduction && a.BaseManpower == b.BaseManpower &&
+		a.TradeGood == b.TradeGood && a.TradeNode == b.TradeNode && a.Area == b.Area && a.Region == b.Region &&
+		a.Superregion == b.Superregion && a.Continent == b.Continent &&
+		strings.Join(a.Modifiers, "\n") == strings.Join(b.Modifiers, "\n")
+}
+
+// provinceZones returns, for each zone of each claim type, a key made of the
+// sorted IDs of its provinces.
+func provinceZones(provinces []Province) map[ClaimType]map[string]string {
+	zones := make(map[ClaimType]map[string]string)
+	for ct, byName := range zoneMembers(provinces) {
+		zones[ct] = make(map[string]string)
+		for name, ids := range byName {
+			key := make([]string, 0, len(ids))
+			for _, id := range ids {
+				key = append(key, strconv.Itoa(id))
+			}
+			zones[ct][name] = strings.Join(key, ",")
+		}
+	}
+	return zones
+}
+
+// zoneMembers returns the sorted IDs of the provinces of each zone of each
+// claim type, provinces themselves are left out.
+func zoneMembers(provinces []Province) map[ClaimType]map[string][]int {
+	members := make(map[ClaimType]map[string][]int)
+	for _, p := range provinces {
+		for _, ct := range ClaimTypes {
+			name := provinceZone(p, ct)
+			if ct == CLAIM_TYPE_PROVINCE || name == "" {
+				continue
+			}
+			if members[ct] == nil {
+				members[ct] = make(map[string][]int)
+			}
+			members[ct][name] = append(members[ct][name], p.ID)
+		}
+	}
+	for _, byName := range members {
+		for _, ids := range byName {
+			sort.Ints(ids)
+		}
+	}
+	return members
+}
+
+// provinceZone returns the name of the zone of the given type the province
+// belongs to, or its ID for provinces, as stored in claims.
+func provinceZone(p Province, ct ClaimType) string {
+	switch ct {
+	case CLAIM_TYPE_PROVINCE:
+		return strconv.Itoa(p.ID)
+	case CLAIM_TYPE_AREA:
+		return p.Area
+	case CLAIM_TYPE_REGION:
+		return p.Region
+	case CLAIM_TYPE_SUPERREGION:
+		return p.Superregion
+	case CLAIM_TYPE_CONTINENT:
+		return p.Continent
+	case CLAIM_TYPE_TRADE:
+		return p.TradeNode
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeProvinces upserts the provinces of the dataset and their zones, then
+// removes the provinces and zones that are not part of it anymore.
+func writeProvinces(ctx context.Context, tx *sql.Tx, datasetId int, provinces []Province, removed []Province) error {
+	lookups := []struct {
+		query  string
+		values func(Province) []any
+	}{
+		{`INSERT INTO continents (dataset_id, name) VALUES (?, ?) ON CONFLICT(dataset_id, name) DO NOTHING`,
+			func(p Province) []any { return []any{p.Continent} }},
+		{`INSERT INTO trade_nodes (dataset_id, name) VALUES (?, ?) ON CONFLICT(dataset_id, name) DO NOTHING`,
+			func(p Province) []any { return []any{p.TradeNode} }},
+		{`INSERT INTO superregions (dataset_id, name) VALUES (?, ?) ON CONFLICT(dataset_id, name) DO NOTHING`,
+			func(p Province) []any { return []any{p.Superregion} }},
+		{`INSERT INTO regions (dataset_id, name, superregion_id) VALUES (?1, ?2, (SELECT id FROM superregions WHERE dataset_id = ?1 AND name = ?3))
+		ON CONFLICT(dataset_id, name) DO UPDATE SET superregion_id = excluded.superregion_id`,
+			func(p Province) []any { return []any{p.Region, p.Superregion} }},
+		{`INSERT INTO areas (dataset_id, name, region_id) VALUES (?1, ?2, (SELECT id FROM regions WHERE dataset_id = ?1 AND name = ?3))
+		ON CONFLICT(dataset_id, name) DO UPDATE SET region_id = excluded.region_id`,
+			func(p Province) []any { return []any{p.Area, p.Region} }},
+	}
+	for _, l := range lookups {
+		stmt, err := tx.PrepareContext(ctx, l.query)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		seen := make(map[string]bool)
+		for _, p := range provinces {
+			values := l.values(p)
+			name := values[0].(string)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			if _, err := stmt.ExecContext(ctx, append([]any{datasetId}, values...)...); err != nil {
+				stmt.Close()
+				return fmt.Errorf("failed to insert zone %s: %w", name, err)
+			}
+		}
+		stmt.Close()
+	}
+
+	upsert, err := tx.PrepareContext(ctx, `INSERT INTO province_data (dataset_id, id, name, province_type, development, base_tax, base_production, base_manpower, trade_good, trade_node_id, area_id, continent_id)
+	VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, NULLIF(?9, ''),
+		(SELECT id FROM trade_nodes WHERE dataset_id = ?1 AND name = ?10),
+		(SELECT id FROM areas WHERE dataset_id = ?1 AND name = ?11),
+		(SELECT id FROM continents WHERE dataset_id = ?1 AND name = ?12))
+	ON CONFLICT(dataset_id, id) DO UPDATE SET name = excluded.name, province_type = excluded.province_type, development = excluded.development,
+		base_tax = excluded.base_tax, base_production = excluded.base_production, base_manpower = excluded.base_manpower,
+		trade_good = excluded.trade_good, trade_node_id = excluded.trade_node_id, area_id = excluded.area_id, continent_id = excluded.continent_id`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer upsert.Close()
+	for _, p := range provinces {
+		_, err := upsert.ExecContext(ctx, datasetId, p.ID, p.Name, p.Type, p.Development, p.BaseTax, p.BaseProduction, p.BaseManpower,
+			p.TradeGood, p.TradeNode, p.Area, p.Continent)
+		if err != nil {
+			return fmt.Errorf("failed to upsert province %s: %w", p, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM province_modifiers WHERE dataset_id = ?`, datasetId); err != nil {
+		return fmt.Errorf("failed to delete province modifiers: %w", err)
+	}
+	insertModifier, err := tx.PrepareContext(ctx, `INSERT INTO modifiers (name) VALUES (?) ON CONFLICT(name) DO NOTHING`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer insertModifier.Close()
+	linkModifier, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO province_modifiers (dataset_id, province_id, modifier_id)
+	VALUES (?, ?, (SELECT id FROM modifiers WHERE name = ?))`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer linkModifier.Close()
+	for _, p := range provinces {
+		for _, m := range p.Modifiers {
+			if _, err := insertModifier.ExecContext(ctx, m); err != nil {
+				return fmt.Errorf("failed to insert modifier %s: %w", m, err)
+			}
+			if _, err := linkModifier.ExecContext(ctx, datasetId, p.ID, m); err != nil {
+				return fmt.Errorf("failed to link modifier %s to province %s: %w", m, p, err)
+			}
+		}
+	}
+
+	for _, p := range removed {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM province_data WHERE dataset_id = ? AND id = ?`, datasetId, p.ID); err != nil {
+			return fmt.Errorf("failed to delete province %s: %w", p, err)
+		}
+	}
+
+	// zones are removed bottom-up, a region is unused once its areas are gone.
+	// Modifiers are shared by all datasets. Adjacencies of removed provinces,
+	// links of removed trade nodes and aliases of removed zones go with them.
+	for _, query := range []string{
+		`DELETE FROM areas WHERE dataset_id = ?1 AND id NOT IN (SELECT area_id FROM province_data WHERE dataset_id = ?1 AND area_id IS NOT NULL)`,
+		`DELETE FROM regions WHERE dataset_id = ?1 AND id NOT IN (SELECT region_id FROM areas WHERE dataset_id = ?1 AND region_id IS NOT NULL)`,
+		`DELETE FROM superregions WHERE dataset_id = ?1 AND id NOT IN (SELECT superregion_id FROM regions WHERE dataset_id = ?1 AND superregion_id IS NOT NULL)`,
+		`DELETE FROM continents WHERE dataset_id = ?1 AND id NOT IN (SELECT continent_id FROM province_data WHERE dataset_id = ?1 AND continent_id IS NOT NULL)`,
+		`DELETE FROM trade_links WHERE dataset_id = ?1 AND (
+			upstream_id NOT IN (SELECT trade_node_id FROM province_data WHERE dataset_id = ?1 AND trade_node_id IS NOT NULL)
+			OR downstream_id NOT IN (SELECT trade_node_id FROM province_data WHERE dataset_id = ?1 AND trade_node_id IS NOT NULL))`,
+		`DELETE FROM trade_nodes WHERE dataset_id = ?1 AND id NOT IN (SELECT trade_node_id FROM province_data WHERE dataset_id = ?1 AND trade_node_id IS NOT NULL)`,
+		`DELETE FROM modifiers WHERE id NOT IN (SELECT modifier_id FROM province_modifiers)`,
+		`DELETE FROM province_adjacencies WHERE dataset_id = ?1 AND (province_id NOT IN (SELECT id FROM province_data WHERE dataset_id = ?1)
+			OR neighbor_id NOT IN (SELECT id FROM province_data WHERE dataset_id = ?1))`,
+		`DELETE FROM zone_aliases WHERE dataset_id = ?1 AND NOT CASE claim_type
+			WHEN 'province' THEN zone_id IN (SELECT id FROM province_data WHERE dataset_id = ?1)
+			WHEN 'area' THEN zone_id IN (SELECT id FROM areas WHERE dataset_id = ?1)
+			WHEN 'region' THEN zone_id IN (SELECT id FROM regions WHERE dataset_id = ?1)
+			WHEN 'trade' THEN zone_id IN (SELECT id FROM trade_nodes WHERE dataset_id = ?1)
+			WHEN 'superregion' THEN zone_id IN (SELECT id FROM superregions WHERE dataset_id = ?1)
+			WHEN 'continent' THEN zone_id IN (SELECT id FROM continents WHERE dataset_id = ?1)
+		END`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, datasetId); err != nil {
+			return fmt.Errorf("failed to delete unused zones: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// refreshClaimProvinces recomputes the provinces held by the claims of the
+// campaigns using the dataset. The conflict trigger on claim_provinces fails
+// the import if the new data makes claims of different players overlap.
+func refreshClaimProvinces(ctx context.Context, tx *sql.Tx, datasetId int) error {
+	claimId, err := reinsertClaimProvinces(ctx, tx, "campaigns.dataset_id = ?", datasetId)
+	if err != nil && claimId != 0 {
+		return fmt.Errorf("the new provinces make claim ID %d overlap with another player's claim: %w", claimId, err)
+	}
+	return err
+}
+
+// reinsertClaimProvinces recomputes the provinces held by the claims of the
+// campaigns matching the SQL condition on the campaigns table. When the
+// provinces of a claim can't be inserted, its ID is returned along with the
+// error.
+func reinsertClaimProvinces(ctx context.Context, tx *sql.Tx, campaigns string, arg any) (int, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT claims.id FROM claims
+	JOIN campaigns ON campaigns.id = claims.campaign_id
+	WHERE %s ORDER BY claims.id`, campaigns), arg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute query: %w", err)
+	}
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan row: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM claim_provinces
+	WHERE campaign_id IN (SELECT id FROM campaigns WHERE %s)`, campaigns), arg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete claim provinces: %w", err)
+	}
+	for _, id := range ids {
+		if err := insertClaimProvinces(ctx, tx, id); err != nil {
+			return id, err
+		}
+	}
+	return 0, nil
+}
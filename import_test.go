@@ -0,0 +1,135 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProvincesCSV(t *testing.T) {
+	provinces, err := ParseProvincesCSV(strings.NewReader(`ID,Name,Development,BT,BP,BM,Trade good,Trade node,Modifiers,Type,Continent,Superregion,Region,Area
+33,Neva,7,3,3,1,,Novgorod,"Natural Harbor
+Neva Estuary",Land,Europe,Eastern Europe,Russia,South Karelia
+1300,Western Mediterranean,,,,,,,,Inland sea,,,,
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []Province{
+		{ID: 33, Name: "Neva", Type: "Land", Development: 7, BaseTax: 3, BaseProduction: 3, BaseManpower: 1, TradeNode: "Novgorod",
+			Area: "South Karelia", Region: "Russia", Superregion: "Eastern Europe", Continent: "Europe", Modifiers: []string{"Natural Harbor", "Neva Estuary"}},
+		{ID: 1300, Name: "Western Mediterranean", Type: "Inland sea", Modifiers: []string{}},
+	}, provinces)
+
+	_, err = ParseProvincesCSV(strings.NewReader(`ID,Name,Type,Area,Region
+1,Stockholm,Land,Svealand,Scandinavia
+1,Östergötland,Land,Svealand,Baltic
+x,Småland,Volcano,,Scandinavia
+`))
+	var verr ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Equal(t, []string{
+		"line 3: province ID 1 is already used on line 2",
+		`line 3: area Svealand is in region "Baltic" but was in "Scandinavia" before`,
+		"line 4: province ID must be positive",
+		`line 4: unknown province type "Volcano", must be one of Land, Sea, Open sea, Inland sea, Lake, Wasteland`,
+		"line 4: province has a region but no area",
+	}, verr.Problems[1:])
+	assert.Equal(t, `line 4: invalid id "x"`, verr.Problems[0])
+
+	_, err = ParseProvincesCSV(strings.NewReader("ID,Name\n1,Stockholm\n"))
+	assert.ErrorAs(t, err, &verr)
+}
+
+func TestImportProvinces(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestImportProvinces"))
+	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	f, err := os.Open("data/eu4-provinces.csv")
+	assert.NoError(t, err)
+	defer f.Close()
+	provinces, err := ParseProvincesCSV(f)
+	assert.NoError(t, err)
+	assert.Equal(t, 3925, len(provinces))
+
+	// the dataset is the one the database was created with
+	report, err := store.ImportProvinces(context.TODO(), DEFAULT_DATASET, provinces)
+	assert.NoError(t, err)
+	assert.True(t, report.Empty(), report.String())
+
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Gascony", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	_, err = store.Claim(context.TODO(), campaignId, "000000000000000002", "bar", "174", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+
+	// Labourd is renamed, Foix is dropped, Lower Rhineland becomes Niederrhein
+	// and a new province creates the Atlantis area.
+	modified := make([]Province, 0, len(provinces)+1)
+	for _, p := range provinces {
+		switch {
+		case p.Name == "Labourd":
+			p.Name = "Lapurdi"
+		case p.Name == "Foix":
+			continue
+		case p.Area == "Lower Rhineland":
+			p.Area = "Niederrhein"
+		case p.Name == "Roma":
+			p.Development++
+		}
+		modified = append(modified, p)
+	}
+	modified = append(modified, Province{ID: 9999, Name: "Poseidonis", Type: "Land", Development: 3, Area: "Atlantis",
+		Region: "France", Superregion: "Western Europe", Continent: "Europe", Modifiers: []string{"Sunken"}})
+
+	report, err = store.ImportProvinces(context.TODO(), DEFAULT_DATASET, modified)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(report.AddedProvinces))
+	assert.Equal(t, "Poseidonis", report.AddedProvinces[0].Name)
+	assert.Equal(t, 1, len(report.RemovedProvinces))
+	assert.Equal(t, "Foix", report.RemovedProvinces[0].Name)
+	// the provinces of Lower Rhineland and Roma
+	assert.Equal(t, 5, report.Updated)
+	assert.Equal(t, []Zone{{Type: CLAIM_TYPE_AREA, Name: "Atlantis"}}, report.AddedZones)
+	assert.Equal(t, []Zone{}, report.RemovedZones)
+	assert.Equal(t, []ProvinceRename{
+		{Type: CLAIM_TYPE_PROVINCE, ID: 173, From: "Labourd", To: "Lapurdi"},
+		{Type: CLAIM_TYPE_AREA, From: "Lower Rhineland", To: "Niederrhein"},
+	}, report.Renamed)
+
+	roma, err := store.GetProvince(context.TODO(), DEFAULT_DATASET, 118)
+	assert.NoError(t, err)
+	assert.Equal(t, 26, roma.Development)
+
+	poseidonis, err := store.GetProvince(context.TODO(), DEFAULT_DATASET, 9999)
+	assert.NoError(t, err)
+	assert.Equal(t, "France", poseidonis.Region)
+	assert.Equal(t, []string{"Sunken"}, poseidonis.Modifiers)
+
+	_, err = store.GetProvince(context.TODO(), DEFAULT_DATASET, 4694)
+	assert.ErrorIs(t, err, ErrNoSuchProvince)
+
+	// claims now hold the provinces of the new data
+	desc, err := store.DescribeClaim(context.TODO(), campaignId, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(desc.Provinces))
+
+	// moving Bordeaux to Gascony would make the claims of foo and bar overlap,
+	// nothing is imported
+	conflicting := make([]Province, 0, len(modified))
+	for _, p := range modified {
+		if p.Name == "Bordeaux" {
+			p.Area = "Gascony"
+		}
+		conflicting = append(conflicting, p)
+	}
+	_, err = store.ImportProvinces(context.TODO(), DEFAULT_DATASET, conflicting)
+	assert.Error(t, err)
+
+	bordeaux, err := store.ListProvinces(context.TODO(), ProvinceFilter{Name: "Bordeaux", Type: "Land"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Guyenne", bordeaux[0].Area)
+}
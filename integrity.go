@@ -0,0 +1,715 @@
+package themis
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ZoneMapping tells ImportProvinces which zone of the new province data
+// replaces a zone of the previous data, e.g. when a patch splits an area in
+// two and the claims on it should go to one of the halves.
+type ZoneMapping struct {
+	From Zone
+	To   Zone
+}
+
+func (m ZoneMapping) String() string {
+	return fmt.Sprintf("%s -> %s", m.From, m.To)
+}
+
+// ParseZoneMappings reads zone mappings from a CSV file without header, with
+// the claim type, the previous name and the new name of the zone on each line.
+// A fourth column gives the claim type of the new zone when it differs. Lines
+// starting with # are ignored.
+func ParseZoneMappings(r io.Reader) ([]ZoneMapping, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	mappings := make([]ZoneMapping, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mapping: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) < 3 || len(record) > 4 {
+			return nil, fmt.Errorf("line %d: expected claim type, previous name and new name", line)
+		}
+
+		from, err := ClaimTypeFromString(strings.ToLower(strings.TrimSpace(record[0])))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		to := from
+		if len(record) == 4 {
+			to, err = ClaimTypeFromString(strings.ToLower(strings.TrimSpace(record[3])))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		mappings = append(mappings, ZoneMapping{
+			From: Zone{Type: from, Name: strings.TrimSpace(record[1])},
+			To:   Zone{Type: to, Name: strings.TrimSpace(record[2])},
+		})
+	}
+	return mappings, nil
+}
+
+// validateMappings checks that the zones mappings point to exist in the new
+// province data. Provinces are designated by their ID.
+func validateMappings(provinces []Province, mappings []ZoneMapping) error {
+	zones := zoneMembers(provinces)
+	ids := make(map[string]bool)
+	for _, p := range provinces {
+		ids[strconv.Itoa(p.ID)] = true
+	}
+
+	problems := make([]string, 0)
+	for i, m := range mappings {
+		if _, ok := claimTypeToColumn[m.From.Type]; !ok {
+			problems = append(problems, fmt.Sprintf("mapping %d: no claim type matching '%s'", i+1, m.From.Type))
+			continue
+		}
+		switch m.To.Type {
+		case CLAIM_TYPE_PROVINCE:
+			if !ids[m.To.Name] {
+				problems = append(problems, fmt.Sprintf("mapping %d: found no provinces with ID %s", i+1, m.To.Name))
+			}
+		default:
+			if _, ok := zones[m.To.Type][m.To.Name]; !ok {
+				problems = append(problems, fmt.Sprintf("mapping %d: found no provinces for %s named %s", i+1, m.To.Type, m.To.Name))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// ClaimRemap is a claim, or one of its exclusions, moved to another zone by a
+// province data import.
+type ClaimRemap struct {
+	ClaimID    int
+	CampaignID int
+	Exclusion  bool
+	From       Zone
+	To         Zone
+}
+
+func (r ClaimRemap) String() string {
+	if r.Exclusion {
+		return fmt.Sprintf("claim #%d exclusion %s remapped to %s", r.ClaimID, r.From, r.To)
+	}
+	return fmt.Sprintf("claim #%d %s remapped to %s", r.ClaimID, r.From, r.To)
+}
+
+// FlaggedClaim is a claim left pointing to a zone that disappeared from the
+// province data, which couldn't be moved to a replacement automatically. It
+// holds no provinces until its owner picks a new zone with RemapClaim.
+type FlaggedClaim struct {
+	Claim
+	CampaignID int
+	Reason     string
+	// Candidates are the zones now holding the provinces of the missing zone,
+	// the ones holding the most of them first.
+	Candidates []Zone
+	FlaggedAt  time.Time
+}
+
+func (f FlaggedClaim) String() string {
+	s := fmt.Sprintf("#%d %s %s (%s): %s", f.ID, f.Type, f.Name, f.Player, f.Reason)
+	if len(f.Candidates) > 0 {
+		candidates := make([]string, 0, len(f.Candidates))
+		for _, c := range f.Candidates {
+			candidates = append(candidates, c.String())
+		}
+		s += fmt.Sprintf(", replaced by %s", strings.Join(candidates, ", "))
+	}
+	return s
+}
+
+// OrphanedClaim is a claim whose zone, or one of its exclusions, matches no
+// province of its campaign's dataset.
+type OrphanedClaim struct {
+	Claim
+	CampaignID int
+	Dataset    string
+	// Missing is true when the claimed zone itself doesn't exist.
+	Missing bool
+	// Exclusions lists the excluded zones that don't exist.
+	Exclusions []Zone
+	// Flagged is true when the owner of the claim has been asked to remap it.
+	Flagged bool
+}
+
+func (o OrphanedClaim) String() string {
+	problems := make([]string, 0, len(o.Exclusions)+1)
+	if o.Missing {
+		problems = append(problems, fmt.Sprintf("%s %s doesn't exist", o.Type, o.Name))
+	}
+	for _, ex := range o.Exclusions {
+		problems = append(problems, fmt.Sprintf("excluded %s doesn't exist", ex))
+	}
+	flagged := ""
+	if o.Flagged {
+		flagged = ", flagged"
+	}
+	return fmt.Sprintf("campaign #%d (%s) claim #%d %s %s by %s: %s%s", o.CampaignID, o.Dataset, o.ID, o.Type, o.Name, o.Player, strings.Join(problems, ", "), flagged)
+}
+
+// zoneExistsCondition is a SQL condition matching when the zone given by the
+// claim type and name expressions has provinces in the dataset. It must be
+// used in a query where the zone expressions are available.
+func zoneExistsCondition(claimType, name, datasetId string) string {
+	conds := make([]string, 0, len(ClaimTypes))
+	for _, ct := range ClaimTypes {
+		conds = append(conds, fmt.Sprintf("(%s = '%s' AND provinces.%s = %s)", claimType, string(ct), claimTypeToColumn[ct], name))
+	}
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM provinces WHERE provinces.dataset_id = %s AND (%s))", datasetId, strings.Join(conds, " OR "))
+}
+
+// CheckIntegrity lists the claims of all campaigns whose zone or exclusions
+// don't exist in the dataset of their campaign anymore, ordered by campaign.
+// Such claims silently hold fewer provinces than they should, or none.
+func (s *Store) CheckIntegrity(ctx context.Context) ([]OrphanedClaim, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`SELECT claims.id, claims.player, claims.userid, claims.claim_type, claims.val,
+		claims.campaign_id, datasets.name, NOT %s,
+		EXISTS (SELECT 1 FROM flagged_claims WHERE flagged_claims.claim_id = claims.id)
+	FROM claims
+	JOIN campaigns ON campaigns.id = claims.campaign_id
+	JOIN datasets ON datasets.id = campaigns.dataset_id
+	ORDER BY claims.campaign_id, claims.id`, zoneExistsCondition("claims.claim_type", "claims.val", "campaigns.dataset_id")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	claims := make([]OrphanedClaim, 0)
+	for rows.Next() {
+		o := OrphanedClaim{Exclusions: make([]Zone, 0)}
+		if err := rows.Scan(&o.ID, &o.Player, &o.UserID, &o.Type, &o.Name, &o.CampaignID, &o.Dataset, &o.Missing, &o.Flagged); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		claims = append(claims, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	rows, err = s.db.QueryContext(ctx, fmt.Sprintf(`SELECT claim_exclusions.claim_id, claim_exclusions.claim_type, claim_exclusions.val
+	FROM claim_exclusions
+	JOIN claims ON claims.id = claim_exclusions.claim_id
+	JOIN campaigns ON campaigns.id = claims.campaign_id
+	WHERE NOT %s
+	ORDER BY claim_exclusions.rowid`, zoneExistsCondition("claim_exclusions.claim_type", "claim_exclusions.val", "campaigns.dataset_id")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+	missing := make(map[int][]Zone)
+	for rows.Next() {
+		var id int
+		z := Zone{}
+		if err := rows.Scan(&id, &z.Type, &z.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		missing[id] = append(missing[id], z)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	orphaned := make([]OrphanedClaim, 0)
+	for _, o := range claims {
+		o.Exclusions = append(o.Exclusions, missing[o.ID]...)
+		if o.Missing || len(o.Exclusions) > 0 {
+			orphaned = append(orphaned, o)
+		}
+	}
+	return orphaned, nil
+}
+
+// ListFlaggedClaims returns the claims of the campaign waiting for their owner
+// to remap them, in the order they were made.
+func (s *Store) ListFlaggedClaims(ctx context.Context, campaignId int) ([]FlaggedClaim, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT claims.id, claims.player, claims.userid, claims.claim_type, claims.val, claims.created_at,
+		flagged_claims.reason, flagged_claims.candidates, flagged_claims.flagged_at
+	FROM flagged_claims
+	JOIN claims ON claims.id = flagged_claims.claim_id
+	WHERE flagged_claims.campaign_id = ?
+	ORDER BY claims.id`, campaignId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	flagged := make([]FlaggedClaim, 0)
+	for rows.Next() {
+		f := FlaggedClaim{CampaignID: campaignId}
+		var (
+			createdAt  sql.NullTime
+			candidates string
+		)
+		if err := rows.Scan(&f.ID, &f.Player, &f.UserID, &f.Type, &f.Name, &createdAt, &f.Reason, &candidates, &f.FlaggedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		f.CreatedAt = createdAt.Time
+		if err := json.Unmarshal([]byte(candidates), &f.Candidates); err != nil {
+			return nil, fmt.Errorf("failed to decode candidates of claim ID %d: %w", f.ID, err)
+		}
+		flagged = append(flagged, f)
+	}
+	return flagged, rows.Err()
+}
+
+// RemapClaim moves one of the user's claims to another zone, usually one of
+// the candidates of a flagged claim. The exclusions that are not part of the
+// new zone are dropped and returned. The claim must not conflict with the
+// claims of other players.
+func (s *Store) RemapClaim(ctx context.Context, campaignId, ID int, userId string, zone Zone) ([]Zone, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	c, err := getClaim(ctx, tx, campaignId, ID)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserID != userId {
+		return nil, ErrNoSuchClaim
+	}
+
+	datasetId, err := campaignDataset(ctx, tx, campaignId)
+	if err != nil {
+		return nil, err
+	}
+	zoneId, name, err := resolveZoneID(ctx, tx, datasetId, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	exclusions, err := claimExclusions(ctx, tx, ID)
+	if err != nil {
+		return nil, err
+	}
+	kept, dropped := make([]Zone, 0, len(exclusions)), make([]Zone, 0)
+	for _, ex := range exclusions {
+		resolved, err := resolveExclusions(ctx, tx, datasetId, name, zone.Type, []Zone{ex})
+		if err != nil {
+			dropped = append(dropped, ex)
+			continue
+		}
+		kept = append(kept, resolved...)
+	}
+
+	conflicts, err := findConflicts(ctx, tx, campaignId, datasetId, userId, name, zone.Type, kept)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run conflicts check: %w", err)
+	}
+	if len(conflicts) > 0 {
+		return nil, ErrConflict{Conflicts: conflicts}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE claims SET claim_type = ?, val = ?, zone_id = ? WHERE id = ?", zone.Type, name, zoneId, ID); err != nil {
+		return nil, fmt.Errorf("failed to remap claim ID %d: %w", ID, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM claim_exclusions WHERE claim_id = ?", ID); err != nil {
+		return nil, fmt.Errorf("failed to delete exclusions of claim ID %d: %w", ID, err)
+	}
+	if err := insertExclusions(ctx, tx, ID, kept); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM claim_provinces WHERE claim_id = ?", ID); err != nil {
+		return nil, fmt.Errorf("failed to delete provinces of claim ID %d: %w", ID, err)
+	}
+	if err := insertClaimProvinces(ctx, tx, ID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM flagged_claims WHERE claim_id = ?", ID); err != nil {
+		return nil, fmt.Errorf("failed to unflag claim ID %d: %w", ID, err)
+	}
+
+	payload := payloadFromClaim(c)
+	payload.PreviousClaimType, payload.PreviousName = c.Type, c.Name
+	payload.ClaimType, payload.Name = zone.Type, name
+	payload.Exclusions = kept
+	if err := recordEvent(ctx, tx, campaignId, ID, EVENT_TYPE_REMAPPED, userId, payload); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return dropped, nil
+}
+
+// migrateClaims moves the claims of the campaigns using the dataset, and
+// their exclusions, off the zones that are not part of the new province data.
+// Explicit mappings are applied first, then a missing zone whose provinces
+// all went to a single zone of the same type, because it was renamed or
+// merged into another one, is replaced by it. Claims on zones that were split
+// or removed are flagged for their owner instead, along with the zones now
+// holding their provinces. Flags of claims that are valid again are removed.
+func migrateClaims(ctx context.Context, tx *sql.Tx, datasetId int, before, after []Province, mappings []ZoneMapping) ([]ClaimRemap, []FlaggedClaim, error) {
+	claims, exclusions, err := datasetClaims(ctx, tx, datasetId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	explicit := make(map[Zone]Zone)
+	for _, m := range mappings {
+		explicit[Zone{Type: m.From.Type, Name: strings.ToLower(m.From.Name)}] = m.To
+	}
+	previous, next := zoneMembers(before), zoneMembers(after)
+	previousIDs, nextProvinces := make(map[string]bool), make(map[int]Province)
+	for _, p := range before {
+		previousIDs[strconv.Itoa(p.ID)] = true
+	}
+	for _, p := range after {
+		nextProvinces[p.ID] = p
+	}
+	exists := func(zones map[ClaimType]map[string][]int, ids map[string]bool, z Zone) bool {
+		if z.Type == CLAIM_TYPE_PROVINCE {
+			return ids[z.Name]
+		}
+		_, ok := zones[z.Type][z.Name]
+		return ok
+	}
+	nextIDs := make(map[string]bool)
+	for id := range nextProvinces {
+		nextIDs[strconv.Itoa(id)] = true
+	}
+
+	// replacement returns the zone replacing z, or the candidates to replace
+	// it when there are none or several of them.
+	replacement := func(z Zone) (Zone, bool, []Zone) {
+		if to, ok := explicit[Zone{Type: z.Type, Name: strings.ToLower(z.Name)}]; ok {
+			return to, to != z, nil
+		}
+		if exists(next, nextIDs, z) {
+			return z, false, nil
+		}
+		candidates := zoneCandidates(previous[z.Type][z.Name], nextProvinces, z.Type)
+		if len(candidates) == 1 {
+			return candidates[0], true, nil
+		}
+		return z, false, candidates
+	}
+
+	remaps := make([]ClaimRemap, 0)
+	flagged := make([]FlaggedClaim, 0)
+	for _, c := range claims {
+		zone := Zone{Type: c.Type, Name: c.Name}
+		payload := payloadFromClaim(c.Claim)
+		payload.PreviousClaimType, payload.PreviousName = c.Type, c.Name
+		payload.Exclusions = make([]Zone, 0, len(exclusions[c.ID]))
+		changed, removedNow := false, false
+		reasons := make([]string, 0)
+
+		to, ok, candidates := replacement(zone)
+		switch {
+		case ok:
+			if _, err := tx.ExecContext(ctx, "UPDATE claims SET claim_type = ?, val = ? WHERE id = ?", to.Type, to.Name, c.ID); err != nil {
+				return nil, nil, fmt.Errorf("failed to remap claim ID %d: %w", c.ID, err)
+			}
+			remaps = append(remaps, ClaimRemap{ClaimID: c.ID, CampaignID: c.CampaignID, From: zone, To: to})
+			payload.ClaimType, payload.Name = to.Type, to.Name
+			changed = true
+		case !exists(next, nextIDs, zone):
+			reasons = append(reasons, fmt.Sprintf("%s no longer exists", zone))
+			removedNow = removedNow || exists(previous, previousIDs, zone)
+		}
+
+		for _, ex := range exclusions[c.ID] {
+			exTo, ok, _ := replacement(ex)
+			switch {
+			case ok:
+				_, err := tx.ExecContext(ctx, "UPDATE claim_exclusions SET claim_type = ?, val = ? WHERE claim_id = ? AND claim_type = ? AND val = ?",
+					exTo.Type, exTo.Name, c.ID, ex.Type, ex.Name)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to remap exclusion of claim ID %d: %w", c.ID, err)
+				}
+				remaps = append(remaps, ClaimRemap{ClaimID: c.ID, CampaignID: c.CampaignID, Exclusion: true, From: ex, To: exTo})
+				payload.Exclusions = append(payload.Exclusions, exTo)
+				changed = true
+				continue
+			case !exists(next, nextIDs, ex):
+				reasons = append(reasons, fmt.Sprintf("excluded %s no longer exists", ex))
+				removedNow = removedNow || exists(previous, previousIDs, ex)
+			}
+			payload.Exclusions = append(payload.Exclusions, ex)
+		}
+
+		if changed {
+			if err := recordEvent(ctx, tx, c.CampaignID, c.ID, EVENT_TYPE_REMAPPED, "", payload); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if len(reasons) == 0 {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM flagged_claims WHERE claim_id = ?", c.ID); err != nil {
+				return nil, nil, fmt.Errorf("failed to unflag claim ID %d: %w", c.ID, err)
+			}
+			continue
+		}
+
+		f := FlaggedClaim{
+			Claim:      c.Claim,
+			CampaignID: c.CampaignID,
+			Reason:     strings.Join(reasons, ", "),
+			Candidates: candidates,
+			FlaggedAt:  time.Now().UTC(),
+		}
+		if f.Candidates == nil {
+			f.Candidates = make([]Zone, 0)
+		}
+		added, err := flagClaim(ctx, tx, f, removedNow)
+		if err != nil {
+			return nil, nil, err
+		}
+		if added {
+			flagged = append(flagged, f)
+		}
+	}
+	return remaps, flagged, nil
+}
+
+// zoneCandidates returns the zones of the given type holding the provinces
+// of a zone in the new province data, the ones holding the most first.
+func zoneCandidates(ids []int, provinces map[int]Province, claimType ClaimType) []Zone {
+	counts := make(map[string]int)
+	for _, id := range ids {
+		if p, ok := provinces[id]; ok {
+			if name := provinceZone(p, claimType); name != "" {
+				counts[name]++
+			}
+		}
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	candidates := make([]Zone, 0, len(names))
+	for _, name := range names {
+		candidates = append(candidates, Zone{Type: claimType, Name: name})
+	}
+	return candidates
+}
+
+// flagClaim records the claim as waiting for its owner to remap it. An
+// existing flag is only replaced if the zone went missing with this import,
+// claims which were already orphaned keep the candidates they were given
+// then. It returns true if the flag was written.
+func flagClaim(ctx context.Context, tx *sql.Tx, f FlaggedClaim, replace bool) (bool, error) {
+	candidates, err := json.Marshal(f.Candidates)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode candidates: %w", err)
+	}
+
+	conflict := "DO NOTHING"
+	if replace {
+		conflict = "DO UPDATE SET reason = excluded.reason, candidates = excluded.candidates, flagged_at = excluded.flagged_at"
+	}
+	res, err := tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO flagged_claims (claim_id, campaign_id, reason, candidates, flagged_at)
+	VALUES (?, ?, ?, ?, ?) ON CONFLICT(claim_id) %s`, conflict), f.ID, f.CampaignID, f.Reason, string(candidates), f.FlaggedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to flag claim ID %d: %w", f.ID, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to flag claim ID %d: %w", f.ID, err)
+	}
+	return affected > 0, nil
+}
+
+// campaignClaim is a claim along with the campaign it was made in.
+type campaignClaim struct {
+	Claim
+	CampaignID int
+}
+
+// datasetClaims returns the claims of the campaigns using the dataset and
+// their exclusions, by claim ID.
+func datasetClaims(ctx context.Context, tx *sql.Tx, datasetId int) ([]campaignClaim, map[int][]Zone, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT claims.id, claims.campaign_id, claims.player, claims.userid, claims.claim_type, claims.val, claims.created_at
+	FROM claims
+	JOIN campaigns ON campaigns.id = claims.campaign_id
+	WHERE campaigns.dataset_id = ? ORDER BY claims.id`, datasetId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	claims := make([]campaignClaim, 0)
+	for rows.Next() {
+		c := campaignClaim{}
+		var createdAt sql.NullTime
+		if err := rows.Scan(&c.ID, &c.CampaignID, &c.Player, &c.UserID, &c.Type, &c.Name, &createdAt); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		c.CreatedAt = createdAt.Time
+		claims = append(claims, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT claim_exclusions.claim_id, claim_exclusions.claim_type, claim_exclusions.val
+	FROM claim_exclusions
+	JOIN claims ON claims.id = claim_exclusions.claim_id
+	JOIN campaigns ON campaigns.id = claims.campaign_id
+	WHERE campaigns.dataset_id = ? ORDER BY claim_exclusions.rowid`, datasetId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+	exclusions := make(map[int][]Zone)
+	for rows.Next() {
+		var id int
+		z := Zone{}
+		if err := rows.Scan(&id, &z.Type, &z.Name); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		exclusions[id] = append(exclusions[id], z)
+	}
+	return claims, exclusions, rows.Err()
+}
+
+// resolveLegacyClaims points the claims whose val names no zone exactly, like
+// the misspelled names of claims made before names were folded, to the zone
+// they designate as matched by resolveZoneID. Their val and exclusions get the
+// canonical names and their provinces are recorded. Claims that still match
+// no zone, or overlap the claims of other players, are flagged for their
+// owner instead. It runs once, along with the resolve_legacy_claims migration.
+func resolveLegacyClaims(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT claims.id, claims.campaign_id, campaigns.dataset_id, claims.player, IFNULL(claims.userid, ''),
+		claims.claim_type, claims.val, claims.created_at
+	FROM claims
+	JOIN campaigns ON campaigns.id = claims.campaign_id
+	WHERE (claims.zone_id IS NULL OR (claims.claim_type = 'province' AND CAST(claims.zone_id AS TEXT) IS NOT claims.val))
+	AND claims.id NOT IN (SELECT claim_id FROM flagged_claims)
+	ORDER BY claims.id`)
+	if err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	type legacyClaim struct {
+		campaignClaim
+		datasetId int
+	}
+	claims := make([]legacyClaim, 0)
+	for rows.Next() {
+		c := legacyClaim{}
+		var createdAt sql.NullTime
+		if err := rows.Scan(&c.ID, &c.CampaignID, &c.datasetId, &c.Player, &c.UserID, &c.Type, &c.Name, &createdAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		c.CreatedAt = createdAt.Time
+		claims = append(claims, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	for _, c := range claims {
+		zone := Zone{Type: c.Type, Name: c.Name}
+		flag := func(reason string) error {
+			_, err := flagClaim(ctx, tx, FlaggedClaim{
+				Claim:      c.Claim,
+				CampaignID: c.CampaignID,
+				Reason:     reason,
+				Candidates: make([]Zone, 0),
+				FlaggedAt:  time.Now().UTC(),
+			}, false)
+			return err
+		}
+
+		zoneId, name, err := resolveZoneID(ctx, tx, c.datasetId, zone)
+		if err != nil {
+			if err := flag(fmt.Sprintf("%s doesn't exist", zone)); err != nil {
+				return err
+			}
+			continue
+		}
+		exclusions, err := claimExclusions(ctx, tx, c.ID)
+		if err != nil {
+			return err
+		}
+		resolved, err := resolveExclusions(ctx, tx, c.datasetId, name, c.Type, exclusions)
+		if err != nil {
+			if err := flag(err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// the claims before it hold their provinces already, the first one
+		// made keeps them
+		conflicts, err := findConflicts(ctx, tx, c.CampaignID, c.datasetId, c.UserID, name, c.Type, resolved)
+		if err != nil {
+			return fmt.Errorf("failed to run conflicts check: %w", err)
+		}
+		if len(conflicts) > 0 {
+			if err := flag(fmt.Sprintf("%s %s overlaps with %s", c.Type, name, conflicts[0])); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE claims SET val = ?, zone_id = ? WHERE id = ?", name, zoneId, c.ID); err != nil {
+			return fmt.Errorf("failed to resolve claim ID %d: %w", c.ID, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM claim_exclusions WHERE claim_id = ?", c.ID); err != nil {
+			return fmt.Errorf("failed to delete exclusions of claim ID %d: %w", c.ID, err)
+		}
+		if err := insertExclusions(ctx, tx, c.ID, resolved); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM claim_provinces WHERE claim_id = ?", c.ID); err != nil {
+			return fmt.Errorf("failed to delete provinces of claim ID %d: %w", c.ID, err)
+		}
+		if err := insertClaimProvinces(ctx, tx, c.ID); err != nil {
+			return err
+		}
+
+		changed := name != c.Name
+		for i := range exclusions {
+			changed = changed || exclusions[i] != resolved[i]
+		}
+		if changed {
+			payload := payloadFromClaim(c.Claim)
+			payload.PreviousClaimType, payload.PreviousName = c.Type, c.Name
+			payload.Name = name
+			payload.Exclusions = resolved
+			if err := recordEvent(ctx, tx, c.CampaignID, c.ID, EVENT_TYPE_REMAPPED, c.UserID, payload); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseZoneMappings(t *testing.T) {
+	mappings, err := ParseZoneMappings(strings.NewReader(`# gascony was split in 1.36
+area,Gascony,Pays Basque
+province,Roma,117,province
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []ZoneMapping{
+		{From: Zone{Type: CLAIM_TYPE_AREA, Name: "Gascony"}, To: Zone{Type: CLAIM_TYPE_AREA, Name: "Pays Basque"}},
+		{From: Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Roma"}, To: Zone{Type: CLAIM_TYPE_PROVINCE, Name: "117"}},
+	}, mappings)
+
+	_, err = ParseZoneMappings(strings.NewReader("area,Gascony\n"))
+	assert.EqualError(t, err, "line 1: expected claim type, previous name and new name")
+	_, err = ParseZoneMappings(strings.NewReader("county,Gascony,Pays Basque\n"))
+	assert.Error(t, err)
+}
+
+func TestIntegrity(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestIntegrity"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.TODO()
+	campaignId, err := store.CreateCampaign(ctx, TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	provinces, err := store.ListProvinces(ctx, ProvinceFilter{})
+	assert.NoError(t, err)
+
+	rhineland, err := store.Claim(ctx, campaignId, "000000000000000001", "foo", "Lower Rhineland", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	gascony, err := store.Claim(ctx, campaignId, "000000000000000002", "bar", "Gascony", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	germany, err := store.Claim(ctx, campaignId, "000000000000000003", "baz", "North Germany", CLAIM_TYPE_REGION, Zone{Type: CLAIM_TYPE_AREA, Name: "Lower Rhineland"})
+	assert.NoError(t, err)
+	roma, err := store.Claim(ctx, campaignId, "000000000000000004", "qux", "Roma", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+
+	orphaned, err := store.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, orphaned)
+
+	// Lower Rhineland loses Trier and becomes Niederrhein, Gascony is split in
+	// two and Roma is gone.
+	modified := make([]Province, 0, len(provinces))
+	for _, p := range provinces {
+		switch {
+		case p.ID == 80 || p.ID == 118:
+			continue
+		case p.Area == "Lower Rhineland":
+			p.Area = "Niederrhein"
+		case p.ID == 173 || p.ID == 176:
+			p.Area = "Pays Basque"
+		case p.Area == "Gascony":
+			p.Area = "Armagnac"
+		}
+		modified = append(modified, p)
+	}
+
+	_, err = store.ImportProvinces(ctx, DEFAULT_DATASET, modified, ZoneMapping{
+		From: Zone{Type: CLAIM_TYPE_AREA, Name: "Gascony"},
+		To:   Zone{Type: CLAIM_TYPE_AREA, Name: "Atlantis"},
+	})
+	var verr ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Equal(t, []string{"mapping 1: found no provinces for Area named Atlantis"}, verr.Problems)
+
+	report, err := store.ImportProvinces(ctx, DEFAULT_DATASET, modified)
+	assert.NoError(t, err)
+	niederrhein := Zone{Type: CLAIM_TYPE_AREA, Name: "Niederrhein"}
+	assert.Equal(t, []ClaimRemap{
+		{ClaimID: rhineland, CampaignID: campaignId, From: Zone{Type: CLAIM_TYPE_AREA, Name: "Lower Rhineland"}, To: niederrhein},
+		{ClaimID: germany, CampaignID: campaignId, Exclusion: true, From: Zone{Type: CLAIM_TYPE_AREA, Name: "Lower Rhineland"}, To: niederrhein},
+	}, report.Remapped)
+	assert.Equal(t, 2, len(report.Flagged))
+	assert.Equal(t, gascony, report.Flagged[0].ID)
+	assert.Equal(t, "Area Gascony no longer exists", report.Flagged[0].Reason)
+	assert.Equal(t, []Zone{{Type: CLAIM_TYPE_AREA, Name: "Armagnac"}, {Type: CLAIM_TYPE_AREA, Name: "Pays Basque"}}, report.Flagged[0].Candidates)
+	assert.Equal(t, roma, report.Flagged[1].ID)
+	assert.Equal(t, []Zone{}, report.Flagged[1].Candidates)
+
+	flagged, err := store.ListFlaggedClaims(ctx, campaignId)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(flagged))
+	assert.Equal(t, report.Flagged[0].Candidates, flagged[0].Candidates)
+	assert.Equal(t, "#2 Area Gascony (bar): Area Gascony no longer exists, replaced by Area Armagnac, Area Pays Basque", flagged[0].String())
+
+	orphaned, err = store.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(orphaned))
+	assert.True(t, orphaned[0].Missing)
+	assert.True(t, orphaned[0].Flagged)
+
+	detail, err := store.DescribeClaim(ctx, campaignId, gascony)
+	assert.NoError(t, err)
+	assert.True(t, detail.Orphaned)
+	assert.Empty(t, detail.Provinces)
+
+	// remapped claims keep their provinces, minus Trier
+	detail, err = store.DescribeClaim(ctx, campaignId, rhineland)
+	assert.NoError(t, err)
+	assert.Equal(t, "Niederrhein", detail.Name)
+	assert.Equal(t, 3, len(detail.Provinces))
+	detail, err = store.DescribeClaim(ctx, campaignId, germany)
+	assert.NoError(t, err)
+	assert.Equal(t, []Zone{niederrhein}, detail.Exclusions)
+
+	events, err := store.ClaimHistory(ctx, campaignId, HistoryFilter{Zone: "Lower Rhineland"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, EventType(EVENT_TYPE_REMAPPED), events[1].Type)
+	assert.Equal(t, "Niederrhein", events[1].Payload.Name)
+	claims, err := store.ListClaimsAt(ctx, campaignId, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "Niederrhein", claims[0].Name)
+
+	// the owner picks the new zone
+	_, err = store.RemapClaim(ctx, campaignId, gascony, "000000000000000001", Zone{Type: CLAIM_TYPE_AREA, Name: "Pays Basque"})
+	assert.ErrorIs(t, err, ErrNoSuchClaim)
+	_, err = store.RemapClaim(ctx, campaignId, gascony, "000000000000000002", Zone{Type: CLAIM_TYPE_AREA, Name: "Niederrhein"})
+	assert.ErrorAs(t, err, &ErrConflict{})
+	dropped, err := store.RemapClaim(ctx, campaignId, gascony, "000000000000000002", Zone{Type: CLAIM_TYPE_AREA, Name: "pays basque"})
+	assert.NoError(t, err)
+	assert.Empty(t, dropped)
+
+	detail, err = store.DescribeClaim(ctx, campaignId, gascony)
+	assert.NoError(t, err)
+	assert.Equal(t, "Pays Basque", detail.Name)
+	assert.Equal(t, []string{"Labourd", "Béarn"}, detail.Provinces)
+	assert.False(t, detail.Orphaned)
+
+	flagged, err = store.ListFlaggedClaims(ctx, campaignId)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(flagged))
+
+	// importing the same data again keeps the flag, an explicit mapping
+	// clears it
+	report, err = store.ImportProvinces(ctx, DEFAULT_DATASET, modified)
+	assert.NoError(t, err)
+	assert.True(t, report.Empty(), report.String())
+
+	report, err = store.ImportProvinces(ctx, DEFAULT_DATASET, modified, ZoneMapping{
+		From: Zone{Type: CLAIM_TYPE_PROVINCE, Name: "118"},
+		To:   Zone{Type: CLAIM_TYPE_PROVINCE, Name: "117"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(report.Remapped))
+	assert.Empty(t, report.Flagged)
+
+	orphaned, err = store.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, orphaned)
+	flagged, err = store.ListFlaggedClaims(ctx, campaignId)
+	assert.NoError(t, err)
+	assert.Empty(t, flagged)
+}
@@ -0,0 +1,195 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Location is a zone found by Locate along with where it sits in the zone
+// hierarchy and who holds it in a campaign.
+type Location struct {
+	Type ClaimType
+	Name string
+	// ProvinceID is the ID of the province for province zones, zero
+	// otherwise.
+	ProvinceID int
+	// Provinces counts the provinces of the zone, of the types counted by the
+	// campaign.
+	Provinces int
+	// Within lists the zones holding all the provinces of the zone, e.g. the
+	// area, region and trade node of a province, in the order of ClaimTypes.
+	Within []Zone
+	// Overlaps lists the zones sharing some provinces with the zone without
+	// holding it or being part of it, e.g. the trade nodes of a region.
+	Overlaps []Zone
+	// Holders are the claims holding provinces of the zone, the claims
+	// holding the most provinces first.
+	Holders []ZoneHolder
+}
+
+// ZoneHolder is a claim holding provinces of a zone.
+type ZoneHolder struct {
+	Claim
+	Provinces int
+}
+
+func (l Location) String() string {
+	sb := strings.Builder{}
+	if l.Type == CLAIM_TYPE_PROVINCE {
+		sb.WriteString(fmt.Sprintf("%s %s (#%d)\n", l.Type, l.Name, l.ProvinceID))
+	} else {
+		sb.WriteString(fmt.Sprintf("%s %s (%d provinces)\n", l.Type, l.Name, l.Provinces))
+	}
+	for _, z := range l.Within {
+		sb.WriteString(fmt.Sprintf("  in %s\n", z))
+	}
+	for _, z := range l.Overlaps {
+		sb.WriteString(fmt.Sprintf("  overlaps %s\n", z))
+	}
+	if len(l.Holders) == 0 {
+		sb.WriteString("  unclaimed\n")
+	}
+	for _, h := range l.Holders {
+		sb.WriteString(fmt.Sprintf("  held by %s through #%d %s %s (%d provinces)\n", h.Player, h.ID, h.Type, h.Name, h.Provinces))
+	}
+	return sb.String()
+}
+
+// Locate finds every zone of the campaign's dataset named name, of any claim
+// type, case-insensitively. Provinces can also be found by ID. Locations are
+// returned in the order of ClaimTypes, the slice is empty if nothing has that
+// name.
+func (s *Store) Locate(ctx context.Context, campaignId int, name string) ([]Location, error) {
+	datasetId, err := campaignDataset(ctx, s.db, campaignId)
+	if err != nil {
+		return nil, err
+	}
+
+	locations := make([]Location, 0)
+	provinces, err := s.db.QueryContext(ctx, provinceQuery+` WHERE province_data.dataset_id = ?1 AND (LOWER(province_data.name) = LOWER(?2) OR CAST(province_data.id AS TEXT) = ?2)
+	ORDER BY province_data.id`, datasetId, strings.TrimSpace(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	for provinces.Next() {
+		p, err := scanProvince(provinces)
+		if err != nil {
+			provinces.Close()
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		l := Location{Type: CLAIM_TYPE_PROVINCE, Name: p.Name, ProvinceID: p.ID, Provinces: 1, Within: make([]Zone, 0), Overlaps: make([]Zone, 0)}
+		for _, ct := range ClaimTypes[1:] {
+			if zone := provinceZone(p, ct); zone != "" {
+				l.Within = append(l.Within, Zone{Type: ct, Name: zone})
+			}
+		}
+		locations = append(locations, l)
+	}
+	provinces.Close()
+	if err := provinces.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	for _, ct := range ClaimTypes[1:] {
+		zone, err := resolveZone(ctx, s.db, datasetId, Zone{Type: ct, Name: strings.TrimSpace(name)})
+		if err != nil {
+			// the name isn't a zone of that type
+			continue
+		}
+		l, err := locateZone(ctx, s.db, campaignId, datasetId, Zone{Type: ct, Name: zone})
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+
+	for i, l := range locations {
+		zone := Zone{Type: l.Type, Name: l.Name}
+		if l.Type == CLAIM_TYPE_PROVINCE {
+			zone.Name = strconv.Itoa(l.ProvinceID)
+		}
+		locations[i].Holders, err = zoneHolders(ctx, s.db, campaignId, datasetId, zone)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return locations, nil
+}
+
+// locateZone finds the zones holding and overlapping a resolved zone, other
+// than a province, by comparing the provinces they share, of the types counted
+// by the campaign.
+func locateZone(ctx context.Context, q querier, campaignId, datasetId int, zone Zone) (Location, error) {
+	l := Location{Type: zone.Type, Name: zone.Name, Within: make([]Zone, 0), Overlaps: make([]Zone, 0)}
+	column := claimTypeToColumn[zone.Type]
+	err := q.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(1) FROM provinces WHERE dataset_id = ? AND %s = ? AND %s", column, provinceTypeCondition("?", "typ")), datasetId, zone.Name, campaignId).
+		Scan(&l.Provinces)
+	if err != nil {
+		return Location{}, fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	for _, ct := range ClaimTypes[1:] {
+		if ct == zone.Type {
+			continue
+		}
+		other := claimTypeToColumn[ct]
+		rows, err := q.QueryContext(ctx, fmt.Sprintf(`SELECT p.%[1]s, COUNT(1),
+			(SELECT COUNT(1) FROM provinces AS q WHERE q.dataset_id = ?1 AND %[4]s AND q.%[1]s = p.%[1]s)
+		FROM provinces AS p
+		WHERE p.dataset_id = ?1 AND %[3]s AND p.%[2]s = ?2 AND p.%[1]s != ''
+		GROUP BY p.%[1]s
+		ORDER BY p.%[1]s`, other, column, provinceTypeCondition("?3", "p.typ"), provinceTypeCondition("?3", "q.typ")), datasetId, zone.Name, campaignId)
+		if err != nil {
+			return Location{}, fmt.Errorf("failed to execute query: %w", err)
+		}
+		for rows.Next() {
+			var name string
+			var shared, total int
+			if err := rows.Scan(&name, &shared, &total); err != nil {
+				rows.Close()
+				return Location{}, fmt.Errorf("failed to scan row: %w", err)
+			}
+			switch {
+			case shared == l.Provinces:
+				l.Within = append(l.Within, Zone{Type: ct, Name: name})
+			case shared < total:
+				l.Overlaps = append(l.Overlaps, Zone{Type: ct, Name: name})
+			}
+			// zones whose provinces are all part of this one are left out
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return Location{}, fmt.Errorf("failed to iterate rows: %w", err)
+		}
+	}
+	return l, nil
+}
+
+// zoneHolders lists the claims of the campaign holding provinces of a resolved
+// zone.
+func zoneHolders(ctx context.Context, q querier, campaignId, datasetId int, zone Zone) ([]ZoneHolder, error) {
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(`SELECT claims.id, claims.player, IFNULL(claims.userid, ''), claims.claim_type, claims.val,
+		COUNT(DISTINCT claim_provinces.province_id)
+	FROM provinces
+	JOIN claim_provinces ON claim_provinces.campaign_id = ?1 AND claim_provinces.province_id = provinces.id
+	JOIN claims ON claims.id = claim_provinces.claim_id
+	WHERE provinces.dataset_id = ?2 AND provinces.%s = ?3
+	GROUP BY claims.id
+	ORDER BY COUNT(DISTINCT claim_provinces.province_id) DESC, claims.id`, claimTypeToColumn[zone.Type]), campaignId, datasetId, zone.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	holders := make([]ZoneHolder, 0)
+	for rows.Next() {
+		h := ZoneHolder{}
+		if err := rows.Scan(&h.ID, &h.Player, &h.UserID, &h.Type, &h.Name, &h.Provinces); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		holders = append(holders, h)
+	}
+	return holders, rows.Err()
+}
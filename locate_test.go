@@ -0,0 +1,73 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocate(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestLocate"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.TODO()
+	campaignId, err := store.CreateCampaign(ctx, TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+	rhineland, err := store.Claim(ctx, campaignId, "000000000000000001", "foo", "Lower Rhineland", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	_, err = store.Claim(ctx, campaignId, "000000000000000002", "bar", "Genoa", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+
+	// Genoa is both a province and a trade node
+	locations, err := store.Locate(ctx, campaignId, "genoa")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(locations))
+	assert.Equal(t, 101, locations[0].ProvinceID)
+	assert.Equal(t, []Zone{
+		{Type: CLAIM_TYPE_AREA, Name: "Liguria"},
+		{Type: CLAIM_TYPE_REGION, Name: "Italy"},
+		{Type: CLAIM_TYPE_TRADE, Name: "Genoa"},
+		{Type: CLAIM_TYPE_SUPERREGION, Name: "Western Europe"},
+		{Type: CLAIM_TYPE_CONTINENT, Name: "Europe"},
+	}, locations[0].Within)
+	assert.Equal(t, 1, len(locations[0].Holders))
+	assert.Equal(t, "bar", locations[0].Holders[0].Player)
+	assert.Equal(t, CLAIM_TYPE_TRADE, string(locations[1].Type))
+	assert.Contains(t, locations[1].Overlaps, Zone{Type: CLAIM_TYPE_REGION, Name: "Italy"})
+	assert.Equal(t, 1, len(locations[1].Holders))
+
+	// Italy is a region, not a trade node
+	locations, err = store.Locate(ctx, campaignId, "Italy")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(locations))
+	assert.Equal(t, CLAIM_TYPE_REGION, string(locations[0].Type))
+	assert.Contains(t, locations[0].Within, Zone{Type: CLAIM_TYPE_SUPERREGION, Name: "Western Europe"})
+	assert.Contains(t, locations[0].Overlaps, Zone{Type: CLAIM_TYPE_TRADE, Name: "Genoa"})
+	assert.NotContains(t, locations[0].Overlaps, Zone{Type: CLAIM_TYPE_AREA, Name: "Liguria"})
+
+	locations, err = store.Locate(ctx, campaignId, "Lower Rhineland")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(locations))
+	assert.Equal(t, 4, locations[0].Provinces)
+	assert.Equal(t, []Zone{
+		{Type: CLAIM_TYPE_REGION, Name: "North Germany"},
+		{Type: CLAIM_TYPE_TRADE, Name: "Rheinland"},
+		{Type: CLAIM_TYPE_SUPERREGION, Name: "Western Europe"},
+		{Type: CLAIM_TYPE_CONTINENT, Name: "Europe"},
+	}, locations[0].Within)
+	assert.Equal(t, rhineland, locations[0].Holders[0].ID)
+	assert.Equal(t, 4, locations[0].Holders[0].Provinces)
+
+	locations, err = store.Locate(ctx, campaignId, "80")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(locations))
+	assert.Equal(t, "Trier", locations[0].Name)
+	assert.Contains(t, locations[0].String(), "held by foo through #1 Area Lower Rhineland (1 provinces)")
+
+	locations, err = store.Locate(ctx, campaignId, "Atlantis")
+	assert.NoError(t, err)
+	assert.Empty(t, locations)
+}
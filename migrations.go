@@ -0,0 +1,359 @@
+package themis
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationName matches migration files, e.g. `0002_add_claims_userid.up.sql`.
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned change to the database schema. Migrations are
+// applied in order, each one exactly once and in its own transaction.
+type Migration struct {
+	Version int
+	Name    string
+
+	up   string
+	down string // empty for migrations that can't be reverted
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d %s", m.Version, m.Name)
+}
+
+// Reversible reports whether the migration can be reverted.
+func (m Migration) Reversible() bool {
+	return m.down != ""
+}
+
+// MigrationState is a migration along with when it was applied to the
+// database, if it was.
+type MigrationState struct {
+	Migration
+	AppliedAt sql.NullTime
+}
+
+// dataMigrations complete the migrations, by name, with changes that can't be
+// written in SQL. They run in the transaction of the migration once its up
+// script is applied.
+var dataMigrations = map[string]func(ctx context.Context, tx *sql.Tx) error{
+	"resolve_legacy_claims": resolveLegacyClaims,
+}
+
+func loadMigrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		match := migrationName.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file name %s", e.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+
+		raw, err := migrationFiles.ReadFile(path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", e.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.up = string(raw)
+		} else {
+			m.down = string(raw)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("missing migration %d", i+1)
+		}
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %s has no up script", m)
+		}
+	}
+	return migrations, nil
+}
+
+// Migrator applies and reverts the embedded migrations on a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator opens the database for migrations. The Store applies pending
+// migrations on its own, this is for inspecting or reverting them. It uses the
+// same driver as the Store, migrations may call its functions like fold_name.
+func NewMigrator(conn string) (*Migrator, error) {
+	db, err := sql.Open(SQLITE_DRIVER, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	m, err := newMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func newMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// Latest is the version of the most recent migration known to this binary.
+func (m *Migrator) Latest() int {
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version is the version of the most recent migration applied to the
+// database, zero for an empty database.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	if err := m.ensureSchema(ctx); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := m.db.QueryRowContext(ctx, "SELECT IFNULL(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan: %w", err)
+	}
+	return version, nil
+}
+
+// Status lists every known migration and when it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationState, error) {
+	if err := m.ensureSchema(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	states := make([]MigrationState, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		state := MigrationState{Migration: mig}
+		if t, ok := applied[mig.Version]; ok {
+			state.AppliedAt = sql.NullTime{Time: t, Valid: true}
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// Up applies the pending migrations up to the target version, or all of them
+// if target is zero. It returns the migrations that were applied.
+func (m *Migrator) Up(ctx context.Context, target int) ([]Migration, error) {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version > m.Latest() {
+		return nil, fmt.Errorf("%w: database is at version %d, latest known migration is %d", ErrSchemaTooNew, version, m.Latest())
+	}
+	if target == 0 {
+		target = m.Latest()
+	}
+	if target > m.Latest() {
+		return nil, fmt.Errorf("no migration %d, latest known migration is %d", target, m.Latest())
+	}
+
+	applied := make([]Migration, 0)
+	for _, mig := range m.migrations {
+		if mig.Version <= version || mig.Version > target {
+			continue
+		}
+		err := m.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, mig.up); err != nil {
+				return fmt.Errorf("failed to apply migration %s: %w", mig, err)
+			}
+			if data, ok := dataMigrations[mig.Name]; ok {
+				if err := data(ctx, tx); err != nil {
+					return fmt.Errorf("failed to apply migration %s: %w", mig, err)
+				}
+			}
+			_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", mig.Version, mig.Name, time.Now().UTC())
+			if err != nil {
+				return fmt.Errorf("failed to record migration %s: %w", mig, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return applied, err
+		}
+		applied = append(applied, mig)
+	}
+	return applied, nil
+}
+
+// Down reverts the applied migrations more recent than the target version,
+// most recent first. It stops at the first migration that can't be reverted
+// and returns the migrations that were reverted.
+func (m *Migrator) Down(ctx context.Context, target int) ([]Migration, error) {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version > m.Latest() {
+		return nil, fmt.Errorf("%w: database is at version %d, latest known migration is %d", ErrSchemaTooNew, version, m.Latest())
+	}
+
+	reverted := make([]Migration, 0)
+	for i := version - 1; i >= 0 && m.migrations[i].Version > target; i-- {
+		mig := m.migrations[i]
+		if !mig.Reversible() {
+			return reverted, fmt.Errorf("%w: %s", ErrIrreversibleMigration, mig)
+		}
+		err := m.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, mig.down); err != nil {
+				return fmt.Errorf("failed to revert migration %s: %w", mig, err)
+			}
+			if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", mig.Version); err != nil {
+				return fmt.Errorf("failed to record migration %s: %w", mig, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return reverted, err
+		}
+		reverted = append(reverted, mig)
+	}
+	return reverted, nil
+}
+
+func (m *Migrator) inTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := f(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ensureSchema creates the schema_migrations table. Databases created before
+// migrations were versioned are baselined: the migrations already reflected
+// in their schema are recorded as applied.
+func (m *Migrator) ensureSchema(ctx context.Context) error {
+	return m.inTx(ctx, func(tx *sql.Tx) error {
+		exists, err := tableExists(ctx, tx, "schema_migrations")
+		if err != nil || exists {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `CREATE TABLE schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)`)
+		if err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+
+		for _, mig := range m.migrations {
+			if mig.Version > len(legacyProbes) {
+				break
+			}
+			applied, err := legacyProbes[mig.Version-1](ctx, tx)
+			if err != nil {
+				return fmt.Errorf("failed to check for migration %s: %w", mig, err)
+			}
+			if !applied {
+				break
+			}
+			_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", mig.Version, mig.Name, time.Now().UTC())
+			if err != nil {
+				return fmt.Errorf("failed to record migration %s: %w", mig, err)
+			}
+		}
+		return nil
+	})
+}
+
+// legacyProbes tell whether each of the first migrations is already reflected
+// in the schema of a database created before migrations were versioned, when
+// init.sql was run on every start and the user ID column was added by hand.
+// Later migrations don't need a probe.
+var legacyProbes = []func(ctx context.Context, q querier) (bool, error){
+	func(ctx context.Context, q querier) (bool, error) { return tableExists(ctx, q, "claims") },
+	func(ctx context.Context, q querier) (bool, error) { return columnExists(ctx, q, "claims", "userid") },
+}
+
+func tableExists(ctx context.Context, q querier, table string) (bool, error) {
+	var count int
+	err := q.QueryRowContext(ctx, "SELECT COUNT(1) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to scan: %w", err)
+	}
+	return count > 0, nil
+}
+
+func columnExists(ctx context.Context, q querier, table, column string) (bool, error) {
+	var count int
+	err := q.QueryRowContext(ctx, "SELECT COUNT(1) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to scan: %w", err)
+	}
+	return count > 0, nil
+}
@@ -1,5 +1,4 @@
-BEGIN TRANSACTION;
-CREATE TABLE IF NOT EXISTS provinces(
+CREATE TABLE provinces(
   "id" TEXT,
   "name" TEXT,
   "development" TEXT,
@@ -3942,30 +3941,15 @@ INSERT OR IGNORE INTO provinces VALUES('4939','Te Urewera','8','3','4','1','','P
 INSERT OR IGNORE INTO provinces VALUES('4940','Lake Tulare','','','','','','','','Lake','','','','');
 INSERT OR IGNORE INTO provinces VALUES('4941','Lake Cahuilla','','','','','','','','Lake','','','','');
 
-CREATE TABLE IF NOT EXISTS claim_types (
+CREATE TABLE claim_types (
     claim_type TEXT PRIMARY KEY
 );
-INSERT OR IGNORE INTO claim_types (CLAIM_TYPE) VALUES ("trade"), ("region"), ("area");
+INSERT OR IGNORE INTO claim_types (claim_type) VALUES ('trade'), ('region'), ('area');
 
-CREATE TABLE IF NOT EXISTS claims (
+CREATE TABLE claims (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     player TEXT,
     claim_type TEXT,
     val TEXT,
-    userid TEXT,
     FOREIGN KEY(claim_type) REFERENCES claim_types(claim_type)
 );
-
--- CREATE TRIGGER check_conflict
--- BEFORE INSERT ON claims
--- BEGIN
---     SELECT RAISE(FAIL, "unknown claim type")
---     FROM claims
---     WHERE NEW.val IN (
-        -- select provinces.name from claims left join provinces on claims.val = provinces.trade_node where claims.claim_type = 'trade'
-        -- UNION select provinces.name from claims left join provinces on claims.val = provinces.region where claims.claim_type = 'region'
-        -- UNION select provinces.name from claims left join provinces on claims.val = provinces.area where claim_type = 'area'
-        -- );
--- END;
-
-COMMIT;
@@ -3,4 +3,4 @@ ALTER TABLE claims ADD COLUMN userid TEXT;
 UPDATE claims SET userid='212714834490294273' WHERE player = 'shinemperor';
 UPDATE claims SET userid='345340157333078016' WHERE player = 'wperron';
 UPDATE claims SET userid='203896675960487936' WHERE player = 'Gillfren';
-UPDATE claims SET userid='264861923814801408' WHERE player = 'B i r b';
\ No newline at end of file
+UPDATE claims SET userid='264861923814801408' WHERE player = 'B i r b';
@@ -0,0 +1,17 @@
+-- Scope claims to a campaign. Claims made before campaigns existed are moved to
+-- a 'default' campaign that doesn't belong to any guild yet, see
+-- Store.AdoptCampaigns.
+CREATE TABLE campaigns (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    guild_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    active INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    archived_at TIMESTAMP,
+    UNIQUE(guild_id, name)
+);
+ALTER TABLE claims ADD COLUMN campaign_id INTEGER REFERENCES campaigns(id);
+CREATE INDEX claims_campaign_id ON claims(campaign_id);
+
+INSERT INTO campaigns (guild_id, name, active) SELECT '', 'default', 1 WHERE EXISTS (SELECT 1 FROM claims);
+UPDATE claims SET campaign_id = (SELECT id FROM campaigns WHERE guild_id = '' AND name = 'default');
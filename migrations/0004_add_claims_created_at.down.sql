@@ -0,0 +1 @@
+ALTER TABLE claims DROP COLUMN created_at;
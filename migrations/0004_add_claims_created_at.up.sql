@@ -0,0 +1,2 @@
+-- Claims made before this migration have no creation date.
+ALTER TABLE claims ADD COLUMN created_at TIMESTAMP;
@@ -0,0 +1,5 @@
+CREATE TABLE guilds (
+    guild_id TEXT PRIMARY KEY,
+    name TEXT,
+    joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1 @@
+DROP TABLE claim_events;
@@ -0,0 +1,20 @@
+CREATE TABLE claim_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    campaign_id INTEGER NOT NULL,
+    claim_id INTEGER NOT NULL,
+    event_type TEXT NOT NULL,
+    userid TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    payload TEXT NOT NULL,
+    FOREIGN KEY(campaign_id) REFERENCES campaigns(id)
+);
+CREATE INDEX claim_events_campaign_id ON claim_events(campaign_id, created_at);
+
+-- The claims made before the event log existed start it, so that replaying
+-- the log gives back the current claims. Claims without a creation date are
+-- dated from this migration.
+INSERT INTO claim_events (campaign_id, claim_id, event_type, userid, created_at, payload)
+SELECT campaign_id, id, 'created', IFNULL(userid, ''), IFNULL(created_at, CURRENT_TIMESTAMP),
+    json_object('player', player, 'userid', IFNULL(userid, ''), 'claim_type', claim_type, 'val', val)
+FROM claims
+ORDER BY id;
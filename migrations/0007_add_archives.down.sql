@@ -0,0 +1,2 @@
+DROP TABLE archived_claims;
+DROP TABLE archives;
@@ -0,0 +1,19 @@
+CREATE TABLE archives (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    campaign_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY(campaign_id) REFERENCES campaigns(id)
+);
+
+CREATE TABLE archived_claims (
+    archive_id INTEGER NOT NULL,
+    claim_id INTEGER NOT NULL,
+    player TEXT,
+    claim_type TEXT,
+    val TEXT,
+    userid TEXT,
+    created_at TIMESTAMP,
+    FOREIGN KEY(archive_id) REFERENCES archives(id)
+);
+CREATE INDEX archived_claims_archive_id ON archived_claims(archive_id);
@@ -0,0 +1 @@
+DELETE FROM claim_types WHERE claim_type IN ('superregion', 'continent', 'province');
@@ -0,0 +1 @@
+INSERT OR IGNORE INTO claim_types (claim_type) VALUES ('superregion'), ('continent'), ('province');
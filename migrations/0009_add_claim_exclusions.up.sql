@@ -0,0 +1,9 @@
+-- Zones carved out of a claim. Exclusions are kept when the claim is flushed
+-- so that they come back if it is restored from its archive.
+CREATE TABLE claim_exclusions (
+    claim_id INTEGER NOT NULL,
+    claim_type TEXT NOT NULL,
+    val TEXT NOT NULL,
+    FOREIGN KEY(claim_type) REFERENCES claim_types(claim_type)
+);
+CREATE INDEX claim_exclusions_claim_id ON claim_exclusions(claim_id);
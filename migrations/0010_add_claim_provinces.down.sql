@@ -0,0 +1,5 @@
+DROP TRIGGER claims_transfer_provinces;
+DROP TRIGGER claims_release_provinces;
+DROP TRIGGER check_conflict_transfer;
+DROP TRIGGER check_conflict;
+DROP TABLE claim_provinces;
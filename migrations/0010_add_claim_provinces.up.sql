@@ -0,0 +1,119 @@
+-- Provinces held through each claim, excluded provinces left out. The
+-- check_conflict triggers guarantee that a province is never held by two
+-- players of the same campaign, whatever checks were done before writing.
+CREATE TABLE claim_provinces (
+    claim_id INTEGER NOT NULL,
+    campaign_id INTEGER NOT NULL,
+    userid TEXT,
+    province_id TEXT NOT NULL,
+    UNIQUE(claim_id, province_id)
+);
+CREATE INDEX claim_provinces_province_id ON claim_provinces(campaign_id, province_id);
+
+CREATE TRIGGER check_conflict
+BEFORE INSERT ON claim_provinces
+WHEN EXISTS (
+    SELECT 1 FROM claim_provinces
+    WHERE campaign_id = NEW.campaign_id AND province_id = NEW.province_id AND userid IS NOT NEW.userid
+)
+BEGIN
+    SELECT RAISE(ABORT, 'province is already claimed by another player');
+END;
+
+CREATE TRIGGER check_conflict_transfer
+BEFORE UPDATE OF userid ON claim_provinces
+WHEN EXISTS (
+    SELECT 1 FROM claim_provinces
+    WHERE campaign_id = NEW.campaign_id AND province_id = NEW.province_id AND userid IS NOT NEW.userid AND claim_id != NEW.claim_id
+)
+BEGIN
+    SELECT RAISE(ABORT, 'province is already claimed by another player');
+END;
+
+CREATE TRIGGER claims_release_provinces
+AFTER DELETE ON claims
+BEGIN
+    DELETE FROM claim_provinces WHERE claim_id = OLD.id;
+END;
+
+CREATE TRIGGER claims_transfer_provinces
+AFTER UPDATE OF userid ON claims
+BEGIN
+    UPDATE claim_provinces SET userid = NEW.userid WHERE claim_id = NEW.id;
+END;
+
+-- Record the provinces held through the existing claims. This fails if two
+-- players already hold the same province, release one of the conflicting
+-- claims first.
+INSERT INTO claim_provinces (claim_id, campaign_id, userid, province_id)
+SELECT claims.id, claims.campaign_id, claims.userid, provinces.id
+    FROM claims JOIN provinces ON claims.val = provinces.id
+    WHERE claims.claim_type = 'province'
+    AND NOT EXISTS (SELECT 1 FROM claim_exclusions WHERE claim_exclusions.claim_id = claims.id AND (
+        (claim_exclusions.claim_type = 'province' AND claim_exclusions.val = provinces.id)
+        OR (claim_exclusions.claim_type = 'area' AND claim_exclusions.val = provinces.area)
+        OR (claim_exclusions.claim_type = 'region' AND claim_exclusions.val = provinces.region)
+        OR (claim_exclusions.claim_type = 'trade' AND claim_exclusions.val = provinces.trade_node)
+        OR (claim_exclusions.claim_type = 'superregion' AND claim_exclusions.val = provinces.superregion)
+        OR (claim_exclusions.claim_type = 'continent' AND claim_exclusions.val = provinces.continent)
+    ))
+UNION
+SELECT claims.id, claims.campaign_id, claims.userid, provinces.id
+    FROM claims JOIN provinces ON claims.val = provinces.area
+    WHERE claims.claim_type = 'area'
+    AND NOT EXISTS (SELECT 1 FROM claim_exclusions WHERE claim_exclusions.claim_id = claims.id AND (
+        (claim_exclusions.claim_type = 'province' AND claim_exclusions.val = provinces.id)
+        OR (claim_exclusions.claim_type = 'area' AND claim_exclusions.val = provinces.area)
+        OR (claim_exclusions.claim_type = 'region' AND claim_exclusions.val = provinces.region)
+        OR (claim_exclusions.claim_type = 'trade' AND claim_exclusions.val = provinces.trade_node)
+        OR (claim_exclusions.claim_type = 'superregion' AND claim_exclusions.val = provinces.superregion)
+        OR (claim_exclusions.claim_type = 'continent' AND claim_exclusions.val = provinces.continent)
+    ))
+UNION
+SELECT claims.id, claims.campaign_id, claims.userid, provinces.id
+    FROM claims JOIN provinces ON claims.val = provinces.region
+    WHERE claims.claim_type = 'region'
+    AND NOT EXISTS (SELECT 1 FROM claim_exclusions WHERE claim_exclusions.claim_id = claims.id AND (
+        (claim_exclusions.claim_type = 'province' AND claim_exclusions.val = provinces.id)
+        OR (claim_exclusions.claim_type = 'area' AND claim_exclusions.val = provinces.area)
+        OR (claim_exclusions.claim_type = 'region' AND claim_exclusions.val = provinces.region)
+        OR (claim_exclusions.claim_type = 'trade' AND claim_exclusions.val = provinces.trade_node)
+        OR (claim_exclusions.claim_type = 'superregion' AND claim_exclusions.val = provinces.superregion)
+        OR (claim_exclusions.claim_type = 'continent' AND claim_exclusions.val = provinces.continent)
+    ))
+UNION
+SELECT claims.id, claims.campaign_id, claims.userid, provinces.id
+    FROM claims JOIN provinces ON claims.val = provinces.trade_node
+    WHERE claims.claim_type = 'trade'
+    AND NOT EXISTS (SELECT 1 FROM claim_exclusions WHERE claim_exclusions.claim_id = claims.id AND (
+        (claim_exclusions.claim_type = 'province' AND claim_exclusions.val = provinces.id)
+        OR (claim_exclusions.claim_type = 'area' AND claim_exclusions.val = provinces.area)
+        OR (claim_exclusions.claim_type = 'region' AND claim_exclusions.val = provinces.region)
+        OR (claim_exclusions.claim_type = 'trade' AND claim_exclusions.val = provinces.trade_node)
+        OR (claim_exclusions.claim_type = 'superregion' AND claim_exclusions.val = provinces.superregion)
+        OR (claim_exclusions.claim_type = 'continent' AND claim_exclusions.val = provinces.continent)
+    ))
+UNION
+SELECT claims.id, claims.campaign_id, claims.userid, provinces.id
+    FROM claims JOIN provinces ON claims.val = provinces.superregion
+    WHERE claims.claim_type = 'superregion'
+    AND NOT EXISTS (SELECT 1 FROM claim_exclusions WHERE claim_exclusions.claim_id = claims.id AND (
+        (claim_exclusions.claim_type = 'province' AND claim_exclusions.val = provinces.id)
+        OR (claim_exclusions.claim_type = 'area' AND claim_exclusions.val = provinces.area)
+        OR (claim_exclusions.claim_type = 'region' AND claim_exclusions.val = provinces.region)
+        OR (claim_exclusions.claim_type = 'trade' AND claim_exclusions.val = provinces.trade_node)
+        OR (claim_exclusions.claim_type = 'superregion' AND claim_exclusions.val = provinces.superregion)
+        OR (claim_exclusions.claim_type = 'continent' AND claim_exclusions.val = provinces.continent)
+    ))
+UNION
+SELECT claims.id, claims.campaign_id, claims.userid, provinces.id
+    FROM claims JOIN provinces ON claims.val = provinces.continent
+    WHERE claims.claim_type = 'continent'
+    AND NOT EXISTS (SELECT 1 FROM claim_exclusions WHERE claim_exclusions.claim_id = claims.id AND (
+        (claim_exclusions.claim_type = 'province' AND claim_exclusions.val = provinces.id)
+        OR (claim_exclusions.claim_type = 'area' AND claim_exclusions.val = provinces.area)
+        OR (claim_exclusions.claim_type = 'region' AND claim_exclusions.val = provinces.region)
+        OR (claim_exclusions.claim_type = 'trade' AND claim_exclusions.val = provinces.trade_node)
+        OR (claim_exclusions.claim_type = 'superregion' AND claim_exclusions.val = provinces.superregion)
+        OR (claim_exclusions.claim_type = 'continent' AND claim_exclusions.val = provinces.continent)
+    ));
@@ -0,0 +1,30 @@
+CREATE TABLE provinces_text(
+  "id" TEXT,
+  "name" TEXT,
+  "development" TEXT,
+  "BT" TEXT,
+  "BP" TEXT,
+  "BM" TEXT,
+  "trade_good" TEXT,
+  "trade_node" TEXT,
+  "modifiers" TEXT,
+  "typ" TEXT,
+  "continent" TEXT,
+  "superregion" TEXT,
+  "region" TEXT,
+  "area" TEXT,
+  UNIQUE(id, 'name')
+);
+INSERT INTO provinces_text SELECT * FROM provinces;
+
+DROP VIEW provinces;
+DROP TABLE province_modifiers;
+DROP TABLE province_data;
+DROP TABLE modifiers;
+DROP TABLE trade_nodes;
+DROP TABLE areas;
+DROP TABLE regions;
+DROP TABLE superregions;
+DROP TABLE continents;
+
+ALTER TABLE provinces_text RENAME TO provinces;
@@ -0,0 +1,120 @@
+-- Typed provinces schema. The zones provinces belong to are stored in lookup
+-- tables, areas belong to a region and regions to a superregion. The
+-- `provinces` view keeps the shape of the former table for the queries
+-- matching zones by name.
+CREATE TABLE continents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE superregions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE regions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    superregion_id INTEGER REFERENCES superregions(id)
+);
+
+CREATE TABLE areas (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    region_id INTEGER REFERENCES regions(id)
+);
+
+CREATE TABLE trade_nodes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE modifiers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE province_data (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    province_type TEXT NOT NULL,
+    development INTEGER NOT NULL DEFAULT 0,
+    base_tax INTEGER NOT NULL DEFAULT 0,
+    base_production INTEGER NOT NULL DEFAULT 0,
+    base_manpower INTEGER NOT NULL DEFAULT 0,
+    trade_good TEXT,
+    trade_node_id INTEGER REFERENCES trade_nodes(id),
+    area_id INTEGER REFERENCES areas(id),
+    continent_id INTEGER REFERENCES continents(id)
+);
+CREATE INDEX province_data_name ON province_data(name);
+
+CREATE TABLE province_modifiers (
+    province_id INTEGER NOT NULL REFERENCES province_data(id),
+    modifier_id INTEGER NOT NULL REFERENCES modifiers(id),
+    PRIMARY KEY(province_id, modifier_id)
+);
+
+INSERT INTO continents (name) SELECT DISTINCT continent FROM provinces WHERE continent != '' ORDER BY continent;
+INSERT INTO superregions (name) SELECT DISTINCT superregion FROM provinces WHERE superregion != '' ORDER BY superregion;
+INSERT INTO regions (name, superregion_id)
+    SELECT DISTINCT region, superregions.id
+    FROM provinces LEFT JOIN superregions ON superregions.name = provinces.superregion
+    WHERE region != '' ORDER BY region;
+INSERT INTO areas (name, region_id)
+    SELECT DISTINCT area, regions.id
+    FROM provinces LEFT JOIN regions ON regions.name = provinces.region
+    WHERE area != '' ORDER BY area;
+INSERT INTO trade_nodes (name) SELECT DISTINCT trade_node FROM provinces WHERE trade_node != '' ORDER BY trade_node;
+
+INSERT INTO province_data (id, name, province_type, development, base_tax, base_production, base_manpower, trade_good, trade_node_id, area_id, continent_id)
+    SELECT CAST(provinces.id AS INTEGER), provinces.name, provinces.typ,
+        CAST(provinces.development AS INTEGER), CAST(provinces.BT AS INTEGER), CAST(provinces.BP AS INTEGER), CAST(provinces.BM AS INTEGER),
+        NULLIF(provinces.trade_good, ''), trade_nodes.id, areas.id, continents.id
+    FROM provinces
+    LEFT JOIN trade_nodes ON trade_nodes.name = provinces.trade_node
+    LEFT JOIN areas ON areas.name = provinces.area
+    LEFT JOIN continents ON continents.name = provinces.continent;
+
+-- modifiers were a single cell with one modifier per line
+CREATE TEMP TABLE split_modifiers AS
+    WITH RECURSIVE split(province_id, modifier, rest) AS (
+        SELECT CAST(id AS INTEGER), '', modifiers || char(10) FROM provinces WHERE modifiers != ''
+        UNION ALL
+        SELECT province_id, substr(rest, 1, instr(rest, char(10)) - 1), substr(rest, instr(rest, char(10)) + 1)
+        FROM split WHERE rest != ''
+    )
+    SELECT province_id, TRIM(modifier) AS modifier FROM split WHERE TRIM(modifier) != '';
+INSERT INTO modifiers (name) SELECT DISTINCT modifier FROM split_modifiers ORDER BY modifier;
+INSERT OR IGNORE INTO province_modifiers (province_id, modifier_id)
+    SELECT split_modifiers.province_id, modifiers.id
+    FROM split_modifiers JOIN modifiers ON modifiers.name = split_modifiers.modifier;
+DROP TABLE split_modifiers;
+
+DROP TABLE provinces;
+
+CREATE VIEW provinces AS
+SELECT CAST(province_data.id AS TEXT) AS id,
+    province_data.name AS name,
+    province_data.development AS development,
+    province_data.base_tax AS BT,
+    province_data.base_production AS BP,
+    province_data.base_manpower AS BM,
+    IFNULL(province_data.trade_good, '') AS trade_good,
+    IFNULL(trade_nodes.name, '') AS trade_node,
+    IFNULL((
+        SELECT GROUP_CONCAT(modifiers.name, char(10))
+        FROM province_modifiers JOIN modifiers ON modifiers.id = province_modifiers.modifier_id
+        WHERE province_modifiers.province_id = province_data.id
+    ), '') AS modifiers,
+    province_data.province_type AS typ,
+    IFNULL(continents.name, '') AS continent,
+    IFNULL(superregions.name, '') AS superregion,
+    IFNULL(regions.name, '') AS region,
+    IFNULL(areas.name, '') AS area
+FROM province_data
+LEFT JOIN trade_nodes ON trade_nodes.id = province_data.trade_node_id
+LEFT JOIN areas ON areas.id = province_data.area_id
+LEFT JOIN regions ON regions.id = areas.region_id
+LEFT JOIN superregions ON superregions.id = regions.superregion_id
+LEFT JOIN continents ON continents.id = province_data.continent_id;
@@ -0,0 +1,107 @@
+-- Only the vanilla dataset is kept, campaigns pinned to other datasets fall
+-- back to it.
+DROP VIEW provinces;
+
+CREATE TABLE continents_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE superregions_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE regions_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    superregion_id INTEGER REFERENCES superregions(id)
+);
+
+CREATE TABLE areas_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    region_id INTEGER REFERENCES regions(id)
+);
+
+CREATE TABLE trade_nodes_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE province_data_old (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    province_type TEXT NOT NULL,
+    development INTEGER NOT NULL DEFAULT 0,
+    base_tax INTEGER NOT NULL DEFAULT 0,
+    base_production INTEGER NOT NULL DEFAULT 0,
+    base_manpower INTEGER NOT NULL DEFAULT 0,
+    trade_good TEXT,
+    trade_node_id INTEGER REFERENCES trade_nodes(id),
+    area_id INTEGER REFERENCES areas(id),
+    continent_id INTEGER REFERENCES continents(id)
+);
+
+CREATE TABLE province_modifiers_old (
+    province_id INTEGER NOT NULL REFERENCES province_data(id),
+    modifier_id INTEGER NOT NULL REFERENCES modifiers(id),
+    PRIMARY KEY(province_id, modifier_id)
+);
+
+INSERT INTO continents_old (id, name) SELECT id, name FROM continents WHERE dataset_id = 1;
+INSERT INTO superregions_old (id, name) SELECT id, name FROM superregions WHERE dataset_id = 1;
+INSERT INTO regions_old (id, name, superregion_id) SELECT id, name, superregion_id FROM regions WHERE dataset_id = 1;
+INSERT INTO areas_old (id, name, region_id) SELECT id, name, region_id FROM areas WHERE dataset_id = 1;
+INSERT INTO trade_nodes_old (id, name) SELECT id, name FROM trade_nodes WHERE dataset_id = 1;
+INSERT INTO province_data_old (id, name, province_type, development, base_tax, base_production, base_manpower, trade_good, trade_node_id, area_id, continent_id)
+    SELECT id, name, province_type, development, base_tax, base_production, base_manpower, trade_good, trade_node_id, area_id, continent_id
+    FROM province_data WHERE dataset_id = 1;
+INSERT INTO province_modifiers_old (province_id, modifier_id) SELECT province_id, modifier_id FROM province_modifiers WHERE dataset_id = 1;
+
+DROP TABLE province_modifiers;
+DROP TABLE province_data;
+DROP TABLE trade_nodes;
+DROP TABLE areas;
+DROP TABLE regions;
+DROP TABLE superregions;
+DROP TABLE continents;
+
+ALTER TABLE continents_old RENAME TO continents;
+ALTER TABLE superregions_old RENAME TO superregions;
+ALTER TABLE regions_old RENAME TO regions;
+ALTER TABLE areas_old RENAME TO areas;
+ALTER TABLE trade_nodes_old RENAME TO trade_nodes;
+ALTER TABLE province_data_old RENAME TO province_data;
+ALTER TABLE province_modifiers_old RENAME TO province_modifiers;
+
+CREATE INDEX province_data_name ON province_data(name);
+
+CREATE VIEW provinces AS
+SELECT CAST(province_data.id AS TEXT) AS id,
+    province_data.name AS name,
+    province_data.development AS development,
+    province_data.base_tax AS BT,
+    province_data.base_production AS BP,
+    province_data.base_manpower AS BM,
+    IFNULL(province_data.trade_good, '') AS trade_good,
+    IFNULL(trade_nodes.name, '') AS trade_node,
+    IFNULL((
+        SELECT GROUP_CONCAT(modifiers.name, char(10))
+        FROM province_modifiers JOIN modifiers ON modifiers.id = province_modifiers.modifier_id
+        WHERE province_modifiers.province_id = province_data.id
+    ), '') AS modifiers,
+    province_data.province_type AS typ,
+    IFNULL(continents.name, '') AS continent,
+    IFNULL(superregions.name, '') AS superregion,
+    IFNULL(regions.name, '') AS region,
+    IFNULL(areas.name, '') AS area
+FROM province_data
+LEFT JOIN trade_nodes ON trade_nodes.id = province_data.trade_node_id
+LEFT JOIN areas ON areas.id = province_data.area_id
+LEFT JOIN regions ON regions.id = areas.region_id
+LEFT JOIN superregions ON superregions.id = regions.superregion_id
+LEFT JOIN continents ON continents.id = province_data.continent_id;
+
+ALTER TABLE campaigns DROP COLUMN dataset_id;
+DROP TABLE datasets;
@@ -0,0 +1,129 @@
+-- Named province datasets, e.g. the vanilla map and the map of a mod, living
+-- side by side. Each campaign is pinned to a dataset, the existing province
+-- data becomes the 'vanilla' dataset which all campaigns use. Zone names are
+-- unique within a dataset, modifiers are shared.
+CREATE TABLE datasets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO datasets (id, name) VALUES (1, 'vanilla');
+
+ALTER TABLE campaigns ADD COLUMN dataset_id INTEGER NOT NULL DEFAULT 1;
+
+DROP VIEW provinces;
+
+CREATE TABLE continents_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    dataset_id INTEGER NOT NULL REFERENCES datasets(id),
+    name TEXT NOT NULL,
+    UNIQUE(dataset_id, name)
+);
+
+CREATE TABLE superregions_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    dataset_id INTEGER NOT NULL REFERENCES datasets(id),
+    name TEXT NOT NULL,
+    UNIQUE(dataset_id, name)
+);
+
+CREATE TABLE regions_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    dataset_id INTEGER NOT NULL REFERENCES datasets(id),
+    name TEXT NOT NULL,
+    superregion_id INTEGER REFERENCES superregions(id),
+    UNIQUE(dataset_id, name)
+);
+
+CREATE TABLE areas_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    dataset_id INTEGER NOT NULL REFERENCES datasets(id),
+    name TEXT NOT NULL,
+    region_id INTEGER REFERENCES regions(id),
+    UNIQUE(dataset_id, name)
+);
+
+CREATE TABLE trade_nodes_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    dataset_id INTEGER NOT NULL REFERENCES datasets(id),
+    name TEXT NOT NULL,
+    UNIQUE(dataset_id, name)
+);
+
+CREATE TABLE province_data_new (
+    dataset_id INTEGER NOT NULL REFERENCES datasets(id),
+    id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    province_type TEXT NOT NULL,
+    development INTEGER NOT NULL DEFAULT 0,
+    base_tax INTEGER NOT NULL DEFAULT 0,
+    base_production INTEGER NOT NULL DEFAULT 0,
+    base_manpower INTEGER NOT NULL DEFAULT 0,
+    trade_good TEXT,
+    trade_node_id INTEGER REFERENCES trade_nodes(id),
+    area_id INTEGER REFERENCES areas(id),
+    continent_id INTEGER REFERENCES continents(id),
+    PRIMARY KEY(dataset_id, id)
+);
+
+CREATE TABLE province_modifiers_new (
+    dataset_id INTEGER NOT NULL REFERENCES datasets(id),
+    province_id INTEGER NOT NULL,
+    modifier_id INTEGER NOT NULL REFERENCES modifiers(id),
+    PRIMARY KEY(dataset_id, province_id, modifier_id)
+);
+
+INSERT INTO continents_new (id, dataset_id, name) SELECT id, 1, name FROM continents;
+INSERT INTO superregions_new (id, dataset_id, name) SELECT id, 1, name FROM superregions;
+INSERT INTO regions_new (id, dataset_id, name, superregion_id) SELECT id, 1, name, superregion_id FROM regions;
+INSERT INTO areas_new (id, dataset_id, name, region_id) SELECT id, 1, name, region_id FROM areas;
+INSERT INTO trade_nodes_new (id, dataset_id, name) SELECT id, 1, name FROM trade_nodes;
+INSERT INTO province_data_new (dataset_id, id, name, province_type, development, base_tax, base_production, base_manpower, trade_good, trade_node_id, area_id, continent_id)
+    SELECT 1, id, name, province_type, development, base_tax, base_production, base_manpower, trade_good, trade_node_id, area_id, continent_id
+    FROM province_data;
+INSERT INTO province_modifiers_new (dataset_id, province_id, modifier_id) SELECT 1, province_id, modifier_id FROM province_modifiers;
+
+DROP TABLE province_modifiers;
+DROP TABLE province_data;
+DROP TABLE trade_nodes;
+DROP TABLE areas;
+DROP TABLE regions;
+DROP TABLE superregions;
+DROP TABLE continents;
+
+ALTER TABLE continents_new RENAME TO continents;
+ALTER TABLE superregions_new RENAME TO superregions;
+ALTER TABLE regions_new RENAME TO regions;
+ALTER TABLE areas_new RENAME TO areas;
+ALTER TABLE trade_nodes_new RENAME TO trade_nodes;
+ALTER TABLE province_data_new RENAME TO province_data;
+ALTER TABLE province_modifiers_new RENAME TO province_modifiers;
+
+CREATE INDEX province_data_name ON province_data(dataset_id, name);
+
+CREATE VIEW provinces AS
+SELECT CAST(province_data.id AS TEXT) AS id,
+    province_data.name AS name,
+    province_data.development AS development,
+    province_data.base_tax AS BT,
+    province_data.base_production AS BP,
+    province_data.base_manpower AS BM,
+    IFNULL(province_data.trade_good, '') AS trade_good,
+    IFNULL(trade_nodes.name, '') AS trade_node,
+    IFNULL((
+        SELECT GROUP_CONCAT(modifiers.name, char(10))
+        FROM province_modifiers JOIN modifiers ON modifiers.id = province_modifiers.modifier_id
+        WHERE province_modifiers.dataset_id = province_data.dataset_id AND province_modifiers.province_id = province_data.id
+    ), '') AS modifiers,
+    province_data.province_type AS typ,
+    IFNULL(continents.name, '') AS continent,
+    IFNULL(superregions.name, '') AS superregion,
+    IFNULL(regions.name, '') AS region,
+    IFNULL(areas.name, '') AS area,
+    province_data.dataset_id AS dataset_id
+FROM province_data
+LEFT JOIN trade_nodes ON trade_nodes.id = province_data.trade_node_id
+LEFT JOIN areas ON areas.id = province_data.area_id
+LEFT JOIN regions ON regions.id = areas.region_id
+LEFT JOIN superregions ON superregions.id = regions.superregion_id
+LEFT JOIN continents ON continents.id = province_data.continent_id;
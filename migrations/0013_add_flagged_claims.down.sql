@@ -0,0 +1,2 @@
+DROP TRIGGER claims_release_flags;
+DROP TABLE flagged_claims;
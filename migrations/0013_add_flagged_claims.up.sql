@@ -0,0 +1,16 @@
+-- Claims left pointing to zones that no longer exist after a dataset import,
+-- waiting for their owner to pick one of the candidate zones.
+CREATE TABLE flagged_claims (
+    claim_id INTEGER PRIMARY KEY,
+    campaign_id INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    candidates TEXT NOT NULL DEFAULT '[]',
+    flagged_at TIMESTAMP NOT NULL
+);
+CREATE INDEX flagged_claims_campaign_id ON flagged_claims(campaign_id);
+
+CREATE TRIGGER claims_release_flags
+AFTER DELETE ON claims
+BEGIN
+    DELETE FROM flagged_claims WHERE claim_id = OLD.id;
+END;
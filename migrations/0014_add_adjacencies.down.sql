@@ -0,0 +1,2 @@
+ALTER TABLE campaigns DROP COLUMN contiguous_claims;
+DROP TABLE province_adjacencies;
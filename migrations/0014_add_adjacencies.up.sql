@@ -0,0 +1,12 @@
+-- Neighboring provinces of each dataset. Every pair is stored in both
+-- directions so that the neighbors of a province are found with a single
+-- lookup.
+CREATE TABLE province_adjacencies (
+    dataset_id INTEGER NOT NULL,
+    province_id INTEGER NOT NULL,
+    neighbor_id INTEGER NOT NULL,
+    PRIMARY KEY(dataset_id, province_id, neighbor_id)
+);
+
+-- Campaigns can require new claims to touch the player's other claims.
+ALTER TABLE campaigns ADD COLUMN contiguous_claims INTEGER NOT NULL DEFAULT 0;
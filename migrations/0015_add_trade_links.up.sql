@@ -0,0 +1,9 @@
+-- Links of the trade network of each dataset, trade flows from the upstream
+-- node to the downstream one.
+CREATE TABLE trade_links (
+    dataset_id INTEGER NOT NULL REFERENCES datasets(id),
+    upstream_id INTEGER NOT NULL REFERENCES trade_nodes(id),
+    downstream_id INTEGER NOT NULL REFERENCES trade_nodes(id),
+    PRIMARY KEY(upstream_id, downstream_id)
+);
+CREATE INDEX trade_links_downstream_id ON trade_links(downstream_id);
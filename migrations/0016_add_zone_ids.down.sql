@@ -0,0 +1,30 @@
+DROP VIEW provinces;
+CREATE VIEW provinces AS
+SELECT CAST(province_data.id AS TEXT) AS id,
+    province_data.name AS name,
+    province_data.development AS development,
+    province_data.base_tax AS BT,
+    province_data.base_production AS BP,
+    province_data.base_manpower AS BM,
+    IFNULL(province_data.trade_good, '') AS trade_good,
+    IFNULL(trade_nodes.name, '') AS trade_node,
+    IFNULL((
+        SELECT GROUP_CONCAT(modifiers.name, char(10))
+        FROM province_modifiers JOIN modifiers ON modifiers.id = province_modifiers.modifier_id
+        WHERE province_modifiers.dataset_id = province_data.dataset_id AND province_modifiers.province_id = province_data.id
+    ), '') AS modifiers,
+    province_data.province_type AS typ,
+    IFNULL(continents.name, '') AS continent,
+    IFNULL(superregions.name, '') AS superregion,
+    IFNULL(regions.name, '') AS region,
+    IFNULL(areas.name, '') AS area,
+    province_data.dataset_id AS dataset_id
+FROM province_data
+LEFT JOIN trade_nodes ON trade_nodes.id = province_data.trade_node_id
+LEFT JOIN areas ON areas.id = province_data.area_id
+LEFT JOIN regions ON regions.id = areas.region_id
+LEFT JOIN superregions ON superregions.id = regions.superregion_id
+LEFT JOIN continents ON continents.id = province_data.continent_id;
+
+DROP TABLE zone_aliases;
+ALTER TABLE claims DROP COLUMN zone_id;
@@ -0,0 +1,62 @@
+-- Claims point to their zone by ID: the province ID for provinces, the ID of
+-- the row of the zone's table for the other claim types. The name in val is
+-- kept as the canonical name of the zone.
+ALTER TABLE claims ADD COLUMN zone_id INTEGER;
+
+UPDATE claims SET zone_id = CASE claim_type
+    WHEN 'province' THEN CAST(val AS INTEGER)
+    WHEN 'area' THEN (SELECT areas.id FROM areas JOIN campaigns ON campaigns.dataset_id = areas.dataset_id
+        WHERE campaigns.id = claims.campaign_id AND areas.name = claims.val)
+    WHEN 'region' THEN (SELECT regions.id FROM regions JOIN campaigns ON campaigns.dataset_id = regions.dataset_id
+        WHERE campaigns.id = claims.campaign_id AND regions.name = claims.val)
+    WHEN 'trade' THEN (SELECT trade_nodes.id FROM trade_nodes JOIN campaigns ON campaigns.dataset_id = trade_nodes.dataset_id
+        WHERE campaigns.id = claims.campaign_id AND trade_nodes.name = claims.val)
+    WHEN 'superregion' THEN (SELECT superregions.id FROM superregions JOIN campaigns ON campaigns.dataset_id = superregions.dataset_id
+        WHERE campaigns.id = claims.campaign_id AND superregions.name = claims.val)
+    WHEN 'continent' THEN (SELECT continents.id FROM continents JOIN campaigns ON campaigns.dataset_id = continents.dataset_id
+        WHERE campaigns.id = claims.campaign_id AND continents.name = claims.val)
+END;
+
+-- Other names players can use for a zone, e.g. a former name or a spelling
+-- without diacritics that doesn't fold to the canonical one.
+CREATE TABLE zone_aliases (
+    dataset_id INTEGER NOT NULL REFERENCES datasets(id),
+    claim_type TEXT NOT NULL REFERENCES claim_types(claim_type),
+    alias TEXT NOT NULL,
+    zone_id INTEGER NOT NULL,
+    PRIMARY KEY(dataset_id, claim_type, alias)
+);
+
+-- The provinces view exposes the IDs of the zones of each province.
+DROP VIEW provinces;
+CREATE VIEW provinces AS
+SELECT CAST(province_data.id AS TEXT) AS id,
+    province_data.name AS name,
+    province_data.development AS development,
+    province_data.base_tax AS BT,
+    province_data.base_production AS BP,
+    province_data.base_manpower AS BM,
+    IFNULL(province_data.trade_good, '') AS trade_good,
+    IFNULL(trade_nodes.name, '') AS trade_node,
+    IFNULL((
+        SELECT GROUP_CONCAT(modifiers.name, char(10))
+        FROM province_modifiers JOIN modifiers ON modifiers.id = province_modifiers.modifier_id
+        WHERE province_modifiers.dataset_id = province_data.dataset_id AND province_modifiers.province_id = province_data.id
+    ), '') AS modifiers,
+    province_data.province_type AS typ,
+    IFNULL(continents.name, '') AS continent,
+    IFNULL(superregions.name, '') AS superregion,
+    IFNULL(regions.name, '') AS region,
+    IFNULL(areas.name, '') AS area,
+    province_data.dataset_id AS dataset_id,
+    province_data.trade_node_id AS trade_node_id,
+    province_data.continent_id AS continent_id,
+    regions.superregion_id AS superregion_id,
+    areas.region_id AS region_id,
+    province_data.area_id AS area_id
+FROM province_data
+LEFT JOIN trade_nodes ON trade_nodes.id = province_data.trade_node_id
+LEFT JOIN areas ON areas.id = province_data.area_id
+LEFT JOIN regions ON regions.id = areas.region_id
+LEFT JOIN superregions ON superregions.id = regions.superregion_id
+LEFT JOIN continents ON continents.id = province_data.continent_id;
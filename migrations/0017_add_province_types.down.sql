@@ -0,0 +1,3 @@
+-- Claims only hold their other provinces back once the provinces of their
+-- dataset are imported again.
+ALTER TABLE campaigns DROP COLUMN province_types;
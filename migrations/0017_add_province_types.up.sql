@@ -0,0 +1,11 @@
+-- Campaigns choose the types of the provinces that claims hold, as a comma
+-- separated list of province types. Only land provinces count by default.
+ALTER TABLE campaigns ADD COLUMN province_types TEXT NOT NULL DEFAULT 'Land';
+
+-- Claims used to hold the provinces of every type, sea tiles included.
+DELETE FROM claim_provinces WHERE NOT EXISTS (
+    SELECT 1 FROM campaigns
+    JOIN provinces ON provinces.dataset_id = campaigns.dataset_id
+    WHERE campaigns.id = claim_provinces.campaign_id AND provinces.id = claim_provinces.province_id
+    AND provinces.typ = 'Land'
+);
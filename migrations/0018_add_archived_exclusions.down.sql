@@ -0,0 +1,7 @@
+-- Exclusions of flushed claims go back to claim_exclusions, where restoring
+-- an archive used to find them.
+INSERT INTO claim_exclusions (claim_id, claim_type, val)
+SELECT DISTINCT claim_id, claim_type, val FROM archived_exclusions
+WHERE claim_id NOT IN (SELECT id FROM claims);
+
+DROP TABLE archived_exclusions;
@@ -0,0 +1,18 @@
+-- Exclusions of the archived claims, restored along with them. Exclusions of
+-- flushed claims used to stay in claim_exclusions, they are moved to the
+-- archives holding their claim.
+CREATE TABLE archived_exclusions (
+    archive_id INTEGER NOT NULL,
+    claim_id INTEGER NOT NULL,
+    claim_type TEXT NOT NULL,
+    val TEXT NOT NULL,
+    FOREIGN KEY(archive_id) REFERENCES archives(id)
+);
+CREATE INDEX archived_exclusions_archive_id ON archived_exclusions(archive_id);
+
+INSERT INTO archived_exclusions (archive_id, claim_id, claim_type, val)
+SELECT archived_claims.archive_id, claim_exclusions.claim_id, claim_exclusions.claim_type, claim_exclusions.val
+FROM claim_exclusions
+JOIN archived_claims ON archived_claims.claim_id = claim_exclusions.claim_id;
+
+DELETE FROM claim_exclusions WHERE claim_id NOT IN (SELECT id FROM claims);
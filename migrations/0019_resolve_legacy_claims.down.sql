@@ -0,0 +1,2 @@
+-- Resolved claims keep their canonical names.
+SELECT 1;
@@ -0,0 +1,5 @@
+-- Claims made before zone names were folded store the names as players typed
+-- them, which may match no zone exactly and leave the claim without a zone ID.
+-- They are resolved like the names of new claims by resolveLegacyClaims, run
+-- along with this migration, or flagged for their owner.
+SELECT 1;
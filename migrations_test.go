@@ -0,0 +1,125 @@
+package themis
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations(t *testing.T) {
+	conn := fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestMigrations")
+	store, err := NewStore(conn)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	migrator, err := NewMigrator(conn)
+	assert.NoError(t, err)
+	defer migrator.Close()
+
+	// migrations can use the functions of the Store's driver
+	var folded string
+	assert.NoError(t, migrator.db.QueryRowContext(context.TODO(), "SELECT fold_name('Östra Svealand')").Scan(&folded))
+	assert.Equal(t, "ostra svealand", folded)
+
+	version, err := migrator.Version(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, migrator.Latest(), version)
+
+	states, err := migrator.Status(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, migrator.Latest(), len(states))
+	for _, s := range states {
+		assert.True(t, s.AppliedAt.Valid, "%s is not applied", s)
+	}
+
+	// campaigns can't be reverted, the migrations before it stay applied
+	reverted, err := migrator.Down(context.TODO(), 0)
+	assert.ErrorIs(t, err, ErrIrreversibleMigration)
+	assert.Equal(t, migrator.Latest()-3, len(reverted))
+	version, err = migrator.Version(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, version)
+	exists, err := tableExists(context.TODO(), migrator.db, "claim_events")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	applied, err := migrator.Up(context.TODO(), 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(applied))
+
+	// migrations are applied only once
+	store, err = NewStore(conn)
+	assert.NoError(t, err)
+	applied, err = migrator.Up(context.TODO(), 0)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	_, err = store.db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (9999, 'from_the_future', CURRENT_TIMESTAMP)")
+	assert.NoError(t, err)
+	_, err = NewStore(conn)
+	assert.ErrorIs(t, err, ErrSchemaTooNew)
+}
+
+func TestMigrationsLegacyDatabase(t *testing.T) {
+	conn := fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestMigrationsLegacyDatabase")
+
+	// a database created before migrations were versioned, with the user ID
+	// column added by hand
+	db, err := sql.Open("sqlite3", conn)
+	assert.NoError(t, err)
+	defer db.Close()
+	migrations, err := loadMigrations()
+	assert.NoError(t, err)
+	for _, m := range migrations[:2] {
+		_, err = db.Exec(m.up)
+		assert.NoError(t, err)
+	}
+	// names were stored as typed, they are resolved like the names of claims
+	// once migrated, or flagged when they name no zone
+	_, err = db.Exec(`INSERT INTO claims (player, claim_type, val, userid) VALUES
+		('foo', 'trade', 'genoa', '000000000000000001'),
+		('bar', 'area', 'Atlantis', '000000000000000002')`)
+	assert.NoError(t, err)
+
+	store, err := NewStore(conn)
+	assert.NoError(t, err)
+
+	var baseline int
+	err = store.db.QueryRow("SELECT COUNT(1) FROM schema_migrations WHERE version <= 2").Scan(&baseline)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, baseline)
+
+	// the legacy claims belong to the admin guild once it adopts them
+	adopted, err := store.AdoptCampaigns(context.TODO(), TEST_GUILD_ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, adopted)
+
+	campaign, err := store.ActiveCampaign(context.TODO(), TEST_GUILD_ID)
+	assert.NoError(t, err)
+	claims, err := store.ListClaims(context.TODO(), campaign.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(claims))
+	assert.Equal(t, "Genoa", claims[0].Name)
+	flagged, err := store.ListFlaggedClaims(context.TODO(), campaign.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(flagged))
+	assert.Equal(t, claims[1].ID, flagged[0].ID)
+	assert.Equal(t, "Area Atlantis doesn't exist", flagged[0].Reason)
+
+	// they start the event log, the board rebuilt from it is the same
+	at, err := store.ListClaimsAt(context.TODO(), campaign.ID, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(at))
+	assert.Equal(t, claims[0].ID, at[0].ID)
+	assert.Equal(t, "Genoa", at[0].Name)
+	assert.Equal(t, "000000000000000001", at[0].UserID)
+
+	// and their provinces are recorded, conflicts are enforced
+	_, err = store.Claim(context.TODO(), campaign.ID, "000000000000000002", "bar", "Liguria", CLAIM_TYPE_AREA)
+	var conflict ErrConflict
+	assert.ErrorAs(t, err, &conflict)
+}
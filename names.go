@@ -0,0 +1,522 @@
+package themis
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLITE_DRIVER is the database/sql driver used by the Store, the sqlite3
+// driver with the fold_name function, see foldName.
+const SQLITE_DRIVER = "sqlite3_themis"
+
+func init() {
+	sql.Register(SQLITE_DRIVER, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("fold_name", foldNameSQL, true)
+		},
+	})
+}
+
+// foldedLetters maps the letters with diacritics found in the names of the
+// game to their plain spelling.
+var foldedLetters = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae",
+	'ç': "c", 'ć': "c", 'ĉ': "c", 'č': "c",
+	'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ĕ': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ĝ': "g", 'ġ': "g", 'ģ': "g",
+	'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ĩ': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ĵ': "j",
+	'ķ': "k",
+	'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o",
+	'œ': "oe",
+	'ŕ': "r", 'ř': "r",
+	'ś': "s", 'ŝ': "s", 'ş': "s", 'š': "s", 'ș': "s",
+	'ß': "ss",
+	'ţ': "t", 'ť': "t", 'ț': "t",
+	'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ũ': "u", 'ū': "u", 'ŭ': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y",
+	'ź': "z", 'ż': "z", 'ž': "z",
+	'’': "'", '‘': "'", '`': "'",
+}
+
+// foldName returns the key zone names are matched on: lowercase, without
+// diacritics and with single spaces, so that "ostra  svealand" matches
+// "Östra Svealand". Diacritics are dropped whether the letters are precomposed
+// or followed by combining marks. It is also available in SQL as fold_name.
+func foldName(name string) string {
+	sb := strings.Builder{}
+	space := false
+	for _, r := range strings.TrimSpace(name) {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if unicode.Is(unicode.Mn, r) {
+			// decomposed diacritics, e.g. "O\u0308stra"
+			continue
+		}
+		if space {
+			sb.WriteRune(' ')
+			space = false
+		}
+		r = unicode.ToLower(r)
+		if folded, ok := foldedLetters[r]; ok {
+			sb.WriteString(folded)
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// foldNameSQL is foldName as the fold_name SQL function, other values than
+// text, like NULL, are returned as is.
+func foldNameSQL(v any) any {
+	switch name := v.(type) {
+	case string:
+		return foldName(name)
+	case []byte:
+		// the driver passes NULL as a nil slice
+		if name == nil {
+			return nil
+		}
+		return foldName(string(name))
+	default:
+		return v
+	}
+}
+
+// ZoneAlias is another name for a zone of a dataset.
+type ZoneAlias struct {
+	Alias string
+	// Zone is the zone the alias designates, by ID for provinces.
+	Zone Zone
+}
+
+func (a ZoneAlias) String() string {
+	return fmt.Sprintf("%s -> %s", a.Alias, a.Zone)
+}
+
+// AddZoneAlias makes alias another name for the zone of the dataset. The alias
+// must not already be the name or alias of another zone of the same claim
+// type.
+func (s *Store) AddZoneAlias(ctx context.Context, dataset string, zone Zone, alias string) error {
+	alias = strings.TrimSpace(alias)
+	if alias == "" {
+		return fmt.Errorf("alias is empty")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	datasetId, err := findDataset(ctx, tx, dataset)
+	if err != nil {
+		return err
+	}
+	zoneId, _, err := resolveZoneID(ctx, tx, datasetId, zone)
+	if err != nil {
+		return err
+	}
+	// the alias is compared folded, like lookups compare it
+	_, other, err := resolveZoneID(ctx, tx, datasetId, Zone{Type: zone.Type, Name: foldName(alias)})
+	var unknown ErrUnknownZone
+	switch {
+	case err == nil:
+		return fmt.Errorf("%s is already the name of %s %s", alias, zone.Type, other)
+	case !errors.As(err, &unknown):
+		// ambiguous names are taken too
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO zone_aliases (dataset_id, claim_type, alias, zone_id) VALUES (?, ?, ?, ?)", datasetId, string(zone.Type), alias, zoneId)
+	if err != nil {
+		return fmt.Errorf("failed to insert alias: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	s.zones.invalidate()
+	return nil
+}
+
+// RemoveZoneAlias removes an alias of the dataset, matched like zone names.
+func (s *Store) RemoveZoneAlias(ctx context.Context, dataset string, claimType ClaimType, alias string) error {
+	datasetId, err := findDataset(ctx, s.db, dataset)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM zone_aliases WHERE dataset_id = ? AND claim_type = ? AND fold_name(alias) = ?", datasetId, string(claimType), foldName(alias))
+	if err != nil {
+		return fmt.Errorf("failed to delete alias: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoSuchAlias
+	}
+	s.zones.invalidate()
+	return nil
+}
+
+// ListZoneAliases lists the aliases of the zones of the dataset, by claim type
+// and alias.
+func (s *Store) ListZoneAliases(ctx context.Context, dataset string) ([]ZoneAlias, error) {
+	datasetId, err := findDataset(ctx, s.db, dataset)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT alias, claim_type, zone_id FROM zone_aliases WHERE dataset_id = ? ORDER BY claim_type, alias`, datasetId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	aliases := make([]ZoneAlias, 0)
+	ids := make([]int, 0)
+	for rows.Next() {
+		var a ZoneAlias
+		var zoneId int
+		if err := rows.Scan(&a.Alias, &a.Zone.Type, &zoneId); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		aliases = append(aliases, a)
+		ids = append(ids, zoneId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	for i := range aliases {
+		aliases[i].Zone.Name, err = zoneName(ctx, s.db, datasetId, aliases[i].Zone.Type, ids[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return aliases, nil
+}
+
+// claimTypeToTable gives the table holding the zones of each claim type,
+// provinces are in province_data.
+var claimTypeToTable = map[ClaimType]string{
+	CLAIM_TYPE_AREA:        "areas",
+	CLAIM_TYPE_REGION:      "regions",
+	CLAIM_TYPE_TRADE:       "trade_nodes",
+	CLAIM_TYPE_SUPERREGION: "superregions",
+	CLAIM_TYPE_CONTINENT:   "continents",
+}
+
+// resolveZoneID finds the zone of the dataset, comparing folded names, then
+// aliases. It returns the ID of the zone and the name claims store it under:
+// the province ID for provinces, the canonical name for all other claim
+// types. When the name is ambiguous the ID of one of the zones is returned
+// along with the error.
+func resolveZoneID(ctx context.Context, q querier, datasetId int, zone Zone) (int, string, error) {
+	if zone.Type == CLAIM_TYPE_PROVINCE {
+		id, err := resolveProvince(ctx, q, datasetId, zone.Name)
+		if err != nil {
+			return 0, "", err
+		}
+		n, _ := strconv.Atoi(id)
+		return n, id, nil
+	}
+
+	table, ok := claimTypeToTable[zone.Type]
+	if !ok {
+		return 0, "", fmt.Errorf("no claim type matching '%s'", zone.Type)
+	}
+
+	type match struct {
+		id   int
+		name string
+	}
+	matches := make([]match, 0, 1)
+	for _, query := range []string{
+		fmt.Sprintf("SELECT id, name FROM %s WHERE dataset_id = ? AND fold_name(name) = ? ORDER BY name", table),
+		fmt.Sprintf(`SELECT %[1]s.id, %[1]s.name FROM zone_aliases
+		JOIN %[1]s ON %[1]s.id = zone_aliases.zone_id
+		WHERE zone_aliases.dataset_id = ? AND zone_aliases.claim_type = '%[2]s' AND fold_name(zone_aliases.alias) = ?
+		ORDER BY %[1]s.name`, table, string(zone.Type)),
+	} {
+		rows, err := q.QueryContext(ctx, query, datasetId, foldName(zone.Name))
+		if err != nil {
+			return 0, "", fmt.Errorf("failed to execute query: %w", err)
+		}
+		for rows.Next() {
+			var m match
+			if err := rows.Scan(&m.id, &m.name); err != nil {
+				rows.Close()
+				return 0, "", fmt.Errorf("failed to scan row: %w", err)
+			}
+			matches = append(matches, m)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, "", fmt.Errorf("failed to iterate rows: %w", err)
+		}
+		if len(matches) > 0 {
+			break
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return 0, "", ErrUnknownZone{Zone: zone}
+	case 1:
+		return matches[0].id, matches[0].name, nil
+	}
+	// names only differing by their diacritics, the exact spelling wins
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if strings.EqualFold(m.name, strings.TrimSpace(zone.Name)) {
+			return m.id, m.name, nil
+		}
+		names = append(names, m.name)
+	}
+	return matches[0].id, "", fmt.Errorf("%s named %s is ambiguous, it could be any of %s", zone.Type, zone.Name, strings.Join(names, ", "))
+}
+
+// zoneName returns the name claims store a zone under from its ID.
+func zoneName(ctx context.Context, q querier, datasetId int, claimType ClaimType, zoneId int) (string, error) {
+	if claimType == CLAIM_TYPE_PROVINCE {
+		return strconv.Itoa(zoneId), nil
+	}
+	table, ok := claimTypeToTable[claimType]
+	if !ok {
+		return "", fmt.Errorf("no claim type matching '%s'", claimType)
+	}
+
+	var name string
+	err := q.QueryRowContext(ctx, fmt.Sprintf("SELECT name FROM %s WHERE dataset_id = ? AND id = ?", table), datasetId, zoneId).Scan(&name)
+	if err != nil {
+		return "", fmt.Errorf("failed to find %s ID %d: %w", claimType, zoneId, err)
+	}
+	return name, nil
+}
+
+// zoneIdExpression is a SQL expression giving the ID of the zone named by the
+// claimType and name expressions in the dataset, NULL if there is none.
+func zoneIdExpression(claimType, name, datasetId string) string {
+	cases := make([]string, 0, len(ClaimTypes))
+	for _, ct := range ClaimTypes {
+		if ct == CLAIM_TYPE_PROVINCE {
+			cases = append(cases, fmt.Sprintf("WHEN '%s' THEN CAST(%s AS INTEGER)", string(ct), name))
+			continue
+		}
+		table := claimTypeToTable[ct]
+		cases = append(cases, fmt.Sprintf("WHEN '%[1]s' THEN (SELECT %[2]s.id FROM %[2]s WHERE %[2]s.dataset_id = %[3]s AND %[2]s.name = %[4]s)",
+			string(ct), table, datasetId, name))
+	}
+	return fmt.Sprintf("CASE %s %s END", claimType, strings.Join(cases, " "))
+}
+
+// refreshZoneIds points the claims of the campaigns using the dataset to the
+// zones named by their val, once imports or remaps changed them.
+func refreshZoneIds(ctx context.Context, tx *sql.Tx, datasetId int) error {
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`UPDATE claims SET zone_id = %s
+	WHERE campaign_id IN (SELECT id FROM campaigns WHERE dataset_id = ?1)`, zoneIdExpression("claims.claim_type", "claims.val", "?1")), datasetId)
+	if err != nil {
+		return fmt.Errorf("failed to update zone IDs of claims: %w", err)
+	}
+	return nil
+}
+
+// MAX_SUGGESTIONS is the number of zones suggested at most when a zone name
+// is unknown.
+const MAX_SUGGESTIONS = 5
+
+// InferClaimType finds the zones of every claim type of the campaign's
+// dataset named name, matched like claims, in the order of ClaimTypes. It
+// returns an ErrUnknownZone with suggestions when there are none. Provinces
+// are named like players designate them: by name, or by ID when several
+// provinces share the name.
+func (s *Store) InferClaimType(ctx context.Context, campaignId int, name string) ([]Zone, error) {
+	datasetId, err := campaignDataset(ctx, s.db, campaignId)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := make([]Zone, 0, 1)
+	if _, err := strconv.Atoi(strings.TrimSpace(name)); err == nil {
+		if id, err := resolveProvince(ctx, s.db, datasetId, name); err == nil {
+			zones = append(zones, Zone{Type: CLAIM_TYPE_PROVINCE, Name: id})
+		}
+	} else {
+		provinces, err := matchProvinces(ctx, s.db, datasetId, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range provinces {
+			z := Zone{Type: CLAIM_TYPE_PROVINCE, Name: p.Name}
+			if len(provinces) > 1 {
+				z.Name = p.ID
+			}
+			zones = append(zones, z)
+		}
+	}
+
+	for _, ct := range ClaimTypes[1:] {
+		_, canonical, err := resolveZoneID(ctx, s.db, datasetId, Zone{Type: ct, Name: name})
+		if errors.As(err, &ErrUnknownZone{}) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, Zone{Type: ct, Name: canonical})
+	}
+
+	if len(zones) == 0 {
+		return nil, withSuggestions(ctx, s.db, datasetId, ErrUnknownZone{Zone: Zone{Name: strings.TrimSpace(name)}})
+	}
+	return zones, nil
+}
+
+// withSuggestions fills in the suggestions of err if it is an ErrUnknownZone,
+// other errors are returned as is.
+func withSuggestions(ctx context.Context, q querier, datasetId int, err error) error {
+	var unknown ErrUnknownZone
+	if !errors.As(err, &unknown) {
+		return err
+	}
+	suggestions, serr := suggestZones(ctx, q, datasetId, unknown.Zone)
+	if serr != nil {
+		return serr
+	}
+	unknown.Suggestions = suggestions
+	return unknown
+}
+
+// suggestZones lists the zones of the dataset the player may have meant when
+// naming an unknown zone: zones of other claim types with that name first,
+// then zones whose folded names are at most a third of the name's length of
+// edits away, the closest first.
+func suggestZones(ctx context.Context, q querier, datasetId int, zone Zone) ([]Zone, error) {
+	zones, err := listZoneNames(ctx, q, datasetId)
+	if err != nil {
+		return nil, err
+	}
+
+	target := foldName(zone.Name)
+	maxDistance := len([]rune(target)) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	rank := make(map[ClaimType]int, len(ClaimTypes))
+	for i, ct := range ClaimTypes {
+		rank[ct] = i
+	}
+
+	type suggestion struct {
+		zone     Zone
+		distance int
+	}
+	suggestions := make([]suggestion, 0)
+	for _, z := range zones {
+		d := editDistance(foldName(z.Name), target)
+		if d > maxDistance || (d == 0 && z.Type == zone.Type) {
+			continue
+		}
+		suggestions = append(suggestions, suggestion{zone: z, distance: d})
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		if (a.zone.Type == zone.Type) != (b.zone.Type == zone.Type) {
+			return a.zone.Type == zone.Type
+		}
+		if a.zone.Type != b.zone.Type {
+			return rank[a.zone.Type] < rank[b.zone.Type]
+		}
+		return a.zone.Name < b.zone.Name
+	})
+
+	res := make([]Zone, 0, MAX_SUGGESTIONS)
+	for _, s := range suggestions {
+		if len(res) == MAX_SUGGESTIONS {
+			break
+		}
+		res = append(res, s.zone)
+	}
+	return res, nil
+}
+
+// listZoneNames lists the zones of every claim type of the dataset, provinces
+// by name.
+func listZoneNames(ctx context.Context, q querier, datasetId int) ([]Zone, error) {
+	branches := make([]string, 0, len(ClaimTypes))
+	for _, ct := range ClaimTypes {
+		column := claimTypeToColumn[ct]
+		if ct == CLAIM_TYPE_PROVINCE {
+			column = "name"
+		}
+		branches = append(branches, fmt.Sprintf("SELECT DISTINCT %[1]s, '%[2]s' FROM provinces WHERE dataset_id = ?1 AND %[1]s != ''", column, string(ct)))
+	}
+	rows, err := q.QueryContext(ctx, strings.Join(branches, "\nUNION ALL\n"), datasetId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	zones := make([]Zone, 0)
+	for rows.Next() {
+		var z Zone
+		if err := rows.Scan(&z.Name, &z.Type); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		zones = append(zones, z)
+	}
+	return zones, rows.Err()
+}
+
+// editDistance is the number of rune insertions, deletions, substitutions
+// and swaps of adjacent runes turning a into b, the optimal string alignment
+// distance.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// rows i-2, i-1 and i of the distance matrix
+	before, prev, cur := make([]int, len(rb)+1), make([]int, len(rb)+1), make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && before[j-2]+1 < cur[j] {
+				cur[j] = before[j-2] + 1
+			}
+		}
+		before, prev, cur = prev, cur, before
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,200 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFoldName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Östra Svealand":       "ostra svealand",
+		"  ostra   Svealand":   "ostra svealand",
+		"Jülich":               "julich",
+		"Ærø":                  "aero",
+		"Gdańsk":               "gdansk",
+		"Île-de-France":        "ile-de-france",
+		"Genoa":                "genoa",
+		"O\u0308stra Svealand": "ostra svealand",
+		"Gdan\u0301sk":         "gdansk",
+	} {
+		assert.Equal(t, expected, foldName(name), name)
+	}
+}
+
+func TestZoneNames(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestZoneNames"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.TODO()
+	campaignId, err := store.CreateCampaign(ctx, TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	id, err := store.Claim(ctx, campaignId, "000000000000000001", "foo", "ostra svealand", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	detail, err := store.DescribeClaim(ctx, campaignId, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Östra Svealand", detail.Name)
+	var zoneId int
+	assert.NoError(t, store.db.QueryRowContext(ctx, "SELECT zone_id FROM claims WHERE id = ?", id).Scan(&zoneId))
+	assert.NoError(t, store.db.QueryRowContext(ctx, "SELECT area_id FROM provinces WHERE dataset_id = 1 AND id = '1'").Scan(&id))
+	assert.Equal(t, id, zoneId)
+
+	// province names fold too, and conflicts go through the zone IDs
+	_, err = store.Claim(ctx, campaignId, "000000000000000002", "bar", "varmland", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+	conflicts, err := store.FindConflicts(ctx, campaignId, "000000000000000003", "SCANDINAVIA", CLAIM_TYPE_REGION, Zone{Type: CLAIM_TYPE_AREA, Name: "vastra svealand"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, conflicts)
+	for _, c := range conflicts {
+		assert.Equal(t, "foo", c.Player)
+		assert.Equal(t, "Östra Svealand", c.Claim)
+	}
+	_, err = store.Claim(ctx, campaignId, "000000000000000003", "baz", "Scandinavia", CLAIM_TYPE_REGION)
+	assert.ErrorAs(t, err, &ErrConflict{})
+
+	// aliases
+	assert.NoError(t, store.AddZoneAlias(ctx, DEFAULT_DATASET, Zone{Type: CLAIM_TYPE_AREA, Name: "Västra Svealand"}, "Värmland and Dal"))
+	assert.NoError(t, store.AddZoneAlias(ctx, DEFAULT_DATASET, Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Stockholm"}, "Holmia"))
+	assert.Error(t, store.AddZoneAlias(ctx, DEFAULT_DATASET, Zone{Type: CLAIM_TYPE_AREA, Name: "Östra Svealand"}, "varmland and dal"))
+	assert.Error(t, store.AddZoneAlias(ctx, DEFAULT_DATASET, Zone{Type: CLAIM_TYPE_AREA, Name: "Östra Svealand"}, "Västra Svealand"))
+	assert.Error(t, store.AddZoneAlias(ctx, DEFAULT_DATASET, Zone{Type: CLAIM_TYPE_AREA, Name: "Atlantis"}, "Mu"))
+	assert.Error(t, store.AddZoneAlias(ctx, DEFAULT_DATASET, Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Kalmar"}, " HOLMIA "))
+
+	aliases, err := store.ListZoneAliases(ctx, DEFAULT_DATASET)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZoneAlias{
+		{Alias: "Värmland and Dal", Zone: Zone{Type: CLAIM_TYPE_AREA, Name: "Västra Svealand"}},
+		{Alias: "Holmia", Zone: Zone{Type: CLAIM_TYPE_PROVINCE, Name: "1"}},
+	}, aliases)
+
+	conflicts, err = store.FindConflicts(ctx, campaignId, "000000000000000003", "holmia", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(conflicts))
+	_, err = store.Claim(ctx, campaignId, "000000000000000003", "baz", "varmland and dal", CLAIM_TYPE_AREA)
+	assert.Error(t, err, "Värmland is held by bar")
+	_, err = store.ClaimMany(ctx, campaignId, "000000000000000002", "bar", ClaimRequest{
+		Zone:       Zone{Type: CLAIM_TYPE_AREA, Name: "Värmland and Dal"},
+		Exclusions: []Zone{{Type: CLAIM_TYPE_PROVINCE, Name: "narke"}},
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.RemoveZoneAlias(ctx, DEFAULT_DATASET, CLAIM_TYPE_PROVINCE, "HOLMIA"))
+	assert.ErrorIs(t, store.RemoveZoneAlias(ctx, DEFAULT_DATASET, CLAIM_TYPE_PROVINCE, "Holmia"), ErrNoSuchAlias)
+	_, err = store.FindConflicts(ctx, campaignId, "000000000000000003", "holmia", CLAIM_TYPE_PROVINCE)
+	assert.Error(t, err)
+}
+
+func TestZoneIdsAfterImport(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestZoneIdsAfterImport"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.TODO()
+	provinces, err := LoadGameFiles(os.DirFS("testdata/eu4"))
+	assert.NoError(t, err)
+	_, err = store.ImportProvinces(ctx, "scandinavia", provinces)
+	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(ctx, TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+	assert.NoError(t, store.SetCampaignDataset(ctx, campaignId, "scandinavia"))
+
+	assert.NoError(t, store.AddZoneAlias(ctx, "scandinavia", Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Lake Vanern"}, "Vänern"))
+	assert.NoError(t, store.AddZoneAlias(ctx, "scandinavia", Zone{Type: CLAIM_TYPE_AREA, Name: "ostergotland"}, "East Gothland"))
+	_, err = store.Claim(ctx, campaignId, "000000000000000001", "foo", "East Gothland", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+
+	// the province removed takes its alias with it, claims follow the zones
+	// to their new IDs
+	modified := make([]Province, 0, len(provinces))
+	for _, p := range provinces {
+		if p.ID != 9 {
+			modified = append(modified, p)
+		}
+	}
+	_, err = store.ImportProvinces(ctx, "scandinavia", modified)
+	assert.NoError(t, err)
+	aliases, err := store.ListZoneAliases(ctx, "scandinavia")
+	assert.NoError(t, err)
+	assert.Equal(t, []ZoneAlias{{Alias: "East Gothland", Zone: Zone{Type: CLAIM_TYPE_AREA, Name: "Östergötland"}}}, aliases)
+
+	_, err = store.Claim(ctx, campaignId, "000000000000000002", "bar", "Gotland", CLAIM_TYPE_PROVINCE)
+	assert.ErrorAs(t, err, &ErrConflict{})
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("genoa", "genoa"))
+	assert.Equal(t, 1, editDistance("itly", "italy"))
+	assert.Equal(t, 1, editDistance("itlay", "italy"))
+	assert.Equal(t, 2, editDistance("gascogne", "gascony"))
+	assert.Equal(t, 5, editDistance("", "genoa"))
+	assert.Equal(t, 1, editDistance("värmland", "varmland"))
+}
+
+func TestUnknownZone(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestUnknownZone"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.TODO()
+	campaignId, err := store.CreateCampaign(ctx, TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	_, err = store.Claim(ctx, campaignId, "000000000000000001", "foo", "Italy", CLAIM_TYPE_TRADE)
+	var unknown ErrUnknownZone
+	assert.ErrorAs(t, err, &unknown)
+	assert.Equal(t, Zone{Type: CLAIM_TYPE_TRADE, Name: "Italy"}, unknown.Zone)
+	assert.Equal(t, Zone{Type: CLAIM_TYPE_REGION, Name: "Italy"}, unknown.Suggestions[0])
+	assert.Equal(t, "Italy is a Region, not a Trade Node", err.Error())
+
+	_, err = store.Claim(ctx, campaignId, "000000000000000001", "foo", "Gascogne", CLAIM_TYPE_AREA)
+	assert.ErrorAs(t, err, &unknown)
+	assert.Contains(t, unknown.Suggestions, Zone{Type: CLAIM_TYPE_AREA, Name: "Gascony"})
+	assert.LessOrEqual(t, len(unknown.Suggestions), MAX_SUGGESTIONS)
+	assert.Contains(t, err.Error(), "found no provinces for Area named Gascogne, did you mean ")
+
+	// exclusions get suggestions too
+	_, err = store.Claim(ctx, campaignId, "000000000000000001", "foo", "Italy", CLAIM_TYPE_REGION, Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Genova"})
+	assert.ErrorAs(t, err, &unknown)
+	assert.Equal(t, Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Genova"}, unknown.Zone)
+	assert.Equal(t, "found no provinces named Genova, did you mean Province Geneva, Province Genoa, Trade Node Genoa?", err.Error())
+
+	assert.Equal(t, "found no zones named Xyzzy", ErrUnknownZone{Zone: Zone{Name: "Xyzzy"}}.Error())
+}
+
+func TestInferClaimType(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestInferClaimType"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.TODO()
+	campaignId, err := store.CreateCampaign(ctx, TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	zones, err := store.InferClaimType(ctx, campaignId, "genoa")
+	assert.NoError(t, err)
+	assert.Equal(t, []Zone{{Type: CLAIM_TYPE_PROVINCE, Name: "Genoa"}, {Type: CLAIM_TYPE_TRADE, Name: "Genoa"}}, zones)
+
+	zones, err = store.InferClaimType(ctx, campaignId, "ITALY")
+	assert.NoError(t, err)
+	assert.Equal(t, []Zone{{Type: CLAIM_TYPE_REGION, Name: "Italy"}}, zones)
+
+	// provinces sharing their name are given by ID
+	zones, err = store.InferClaimType(ctx, campaignId, "Beja")
+	assert.NoError(t, err)
+	assert.Equal(t, []Zone{{Type: CLAIM_TYPE_PROVINCE, Name: "229"}, {Type: CLAIM_TYPE_PROVINCE, Name: "1226"}}, zones)
+
+	zones, err = store.InferClaimType(ctx, campaignId, "101")
+	assert.NoError(t, err)
+	assert.Equal(t, []Zone{{Type: CLAIM_TYPE_PROVINCE, Name: "101"}}, zones)
+
+	_, err = store.InferClaimType(ctx, campaignId, "Itlay")
+	var unknown ErrUnknownZone
+	assert.ErrorAs(t, err, &unknown)
+	assert.Equal(t, Zone{Name: "Itlay"}, unknown.Zone)
+	assert.Contains(t, unknown.Suggestions, Zone{Type: CLAIM_TYPE_REGION, Name: "Italy"})
+}
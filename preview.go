@@ -0,0 +1,71 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ClaimPreview is what a claim would hold if it was taken right now.
+type ClaimPreview struct {
+	Zone
+	Exclusions []Zone
+	// Provinces the claim would hold, excluded provinces are left out.
+	Provinces []string
+	// Total development of the provinces.
+	Development int
+	// Provinces already held by other players, the claim would be refused if
+	// there are any.
+	Conflicts []Conflict
+}
+
+func (cp ClaimPreview) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%s: %d provinces, %d development\n", cp.Zone, len(cp.Provinces), cp.Development))
+	for _, p := range cp.Provinces {
+		sb.WriteString(fmt.Sprintf("  - %s\n", p))
+	}
+	for _, ex := range cp.Exclusions {
+		sb.WriteString(fmt.Sprintf("  except %s\n", ex))
+	}
+	for _, c := range cp.Conflicts {
+		sb.WriteString(fmt.Sprintf("  conflicts with %s\n", c))
+	}
+	return sb.String()
+}
+
+// PreviewClaim runs the same validation and conflicts check as Claim without
+// taking the claim.
+func (s *Store) PreviewClaim(ctx context.Context, campaignId int, userId, name string, claimType ClaimType, exclusions ...Zone) (ClaimPreview, error) {
+	datasetId, err := campaignDataset(ctx, s.db, campaignId)
+	if err != nil {
+		return ClaimPreview{}, err
+	}
+
+	req, err := resolveRequest(ctx, s.db, datasetId, ClaimRequest{Zone: Zone{Type: claimType, Name: name}, Exclusions: exclusions})
+	if err != nil {
+		return ClaimPreview{}, err
+	}
+
+	provinces, err := zoneProvinces(ctx, s.db, campaignId, datasetId, req.Name, req.Type, req.Exclusions)
+	if err != nil {
+		return ClaimPreview{}, err
+	}
+
+	conflicts, err := findConflicts(ctx, s.db, campaignId, datasetId, userId, req.Name, req.Type, req.Exclusions)
+	if err != nil {
+		return ClaimPreview{}, fmt.Errorf("failed to run conflicts check: %w", err)
+	}
+
+	preview := ClaimPreview{
+		Zone:       req.Zone,
+		Exclusions: req.Exclusions,
+		Provinces:  make([]string, 0, len(provinces)),
+		Conflicts:  conflicts,
+	}
+	for _, p := range provinces {
+		preview.Provinces = append(preview.Provinces, p.Name)
+		preview.Development += p.Development
+	}
+	return preview, nil
+}
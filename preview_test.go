@@ -0,0 +1,36 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewClaim(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestPreviewClaim"))
+	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	fooId, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Béarn", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+
+	preview, err := store.PreviewClaim(context.TODO(), campaignId, "000000000000000002", "gascony", CLAIM_TYPE_AREA, Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Foix"})
+	assert.NoError(t, err)
+	assert.Equal(t, Zone{Type: CLAIM_TYPE_AREA, Name: "Gascony"}, preview.Zone)
+	assert.Equal(t, []string{"Labourd", "Armagnac", "Béarn"}, preview.Provinces)
+	assert.Equal(t, 37, preview.Development)
+	assert.Equal(t, []Conflict{
+		{Province: "Béarn", Player: "foo", ClaimType: CLAIM_TYPE_PROVINCE, Claim: "176", ClaimID: fooId},
+	}, preview.Conflicts)
+
+	// nothing is claimed by a preview
+	total, _, err := store.CountClaims(context.TODO(), campaignId)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	_, err = store.PreviewClaim(context.TODO(), campaignId, "000000000000000002", "Atlantis", CLAIM_TYPE_AREA)
+	assert.Error(t, err)
+}
@@ -0,0 +1,317 @@
+package themis
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Province is a province of the map along with the zones it belongs to. Zones
+// a province doesn't belong to, like the area of a wasteland, are empty.
+type Province struct {
+	ID             int
+	Name           string
+	Type           string // Land, Sea, Open sea, Inland sea, Lake or Wasteland
+	Development    int
+	BaseTax        int
+	BaseProduction int
+	BaseManpower   int
+	TradeGood      string
+	TradeNode      string
+	Area           string
+	Region         string
+	Superregion    string
+	Continent      string
+	Modifiers      []string
+}
+
+func (p Province) String() string {
+	return fmt.Sprintf("#%d %s (%s, %d development)", p.ID, p.Name, p.Area, p.Development)
+}
+
+// ProvinceFilter narrows down the provinces returned by ListProvinces. Zero
+// values are ignored, names are matched case-insensitively.
+type ProvinceFilter struct {
+	// Dataset is the name of the dataset to list the provinces of, the
+	// default dataset if empty.
+	Dataset string
+
+	// Name matches provinces whose name contains it.
+	Name           string
+	Type           string
+	TradeNode      string
+	Area           string
+	Region         string
+	Superregion    string
+	Continent      string
+	Modifier       string
+	MinDevelopment int
+}
+
+const provinceQuery string = `SELECT province_data.id, province_data.name, province_data.province_type,
+	province_data.development, province_data.base_tax, province_data.base_production, province_data.base_manpower,
+	IFNULL(province_data.trade_good, ''), IFNULL(trade_nodes.name, ''), IFNULL(areas.name, ''),
+	IFNULL(regions.name, ''), IFNULL(superregions.name, ''), IFNULL(continents.name, ''),
+	IFNULL((
+		SELECT GROUP_CONCAT(modifiers.name, char(10))
+		FROM province_modifiers JOIN modifiers ON modifiers.id = province_modifiers.modifier_id
+		WHERE province_modifiers.dataset_id = province_data.dataset_id AND province_modifiers.province_id = province_data.id
+	), '')
+	FROM province_data
+	LEFT JOIN trade_nodes ON trade_nodes.id = province_data.trade_node_id
+	LEFT JOIN areas ON areas.id = province_data.area_id
+	LEFT JOIN regions ON regions.id = areas.region_id
+	LEFT JOIN superregions ON superregions.id = regions.superregion_id
+	LEFT JOIN continents ON continents.id = province_data.continent_id`
+
+// GetProvince returns the province of the dataset with the given ID. The
+// default dataset is used if dataset is empty.
+func (s *Store) GetProvince(ctx context.Context, dataset string, ID int) (Province, error) {
+	datasetId, err := findDataset(ctx, s.db, dataset)
+	if err != nil {
+		return Province{}, err
+	}
+
+	p, err := scanProvince(s.db.QueryRowContext(ctx, provinceQuery+" WHERE province_data.dataset_id = ? AND province_data.id = ?", datasetId, ID))
+	if err == sql.ErrNoRows {
+		return Province{}, ErrNoSuchProvince
+	}
+	if err != nil {
+		return Province{}, fmt.Errorf("failed to scan row: %w", err)
+	}
+	return p, nil
+}
+
+// ListProvinces returns the provinces matching the filter, ordered by ID.
+func (s *Store) ListProvinces(ctx context.Context, filter ProvinceFilter) ([]Province, error) {
+	return listProvinces(ctx, s.db, filter)
+}
+
+func listProvinces(ctx context.Context, q querier, filter ProvinceFilter) ([]Province, error) {
+	datasetId, err := findDataset(ctx, q, filter.Dataset)
+	if err != nil {
+		return nil, err
+	}
+
+	query := provinceQuery + " WHERE province_data.dataset_id = ?"
+	params := []any{datasetId}
+
+	if filter.Name != "" {
+		query += " AND LOWER(province_data.name) LIKE LOWER(?)"
+		params = append(params, fmt.Sprintf("%%%s%%", filter.Name))
+	}
+	for _, f := range []struct {
+		column string
+		value  string
+	}{
+		{"province_data.province_type", filter.Type},
+		{"trade_nodes.name", filter.TradeNode},
+		{"areas.name", filter.Area},
+		{"regions.name", filter.Region},
+		{"superregions.name", filter.Superregion},
+		{"continents.name", filter.Continent},
+	} {
+		if f.value != "" {
+			query += fmt.Sprintf(" AND LOWER(%s) = LOWER(?)", f.column)
+			params = append(params, f.value)
+		}
+	}
+	if filter.Modifier != "" {
+		query += ` AND EXISTS (SELECT 1 FROM province_modifiers JOIN modifiers ON modifiers.id = province_modifiers.modifier_id
+		WHERE province_modifiers.dataset_id = province_data.dataset_id AND province_modifiers.province_id = province_data.id
+		AND LOWER(modifiers.name) = LOWER(?))`
+		params = append(params, filter.Modifier)
+	}
+	if filter.MinDevelopment > 0 {
+		query += " AND province_data.development >= ?"
+		params = append(params, filter.MinDevelopment)
+	}
+	query += " ORDER BY province_data.id"
+
+	rows, err := q.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	provinces := make([]Province, 0)
+	for rows.Next() {
+		p, err := scanProvince(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		provinces = append(provinces, p)
+	}
+	return provinces, rows.Err()
+}
+
+func scanProvince(row scanner) (Province, error) {
+	p := Province{}
+	var modifiers string
+	err := row.Scan(&p.ID, &p.Name, &p.Type, &p.Development, &p.BaseTax, &p.BaseProduction, &p.BaseManpower,
+		&p.TradeGood, &p.TradeNode, &p.Area, &p.Region, &p.Superregion, &p.Continent, &modifiers)
+	if err != nil {
+		return Province{}, err
+	}
+
+	p.Modifiers = make([]string, 0)
+	if modifiers != "" {
+		p.Modifiers = strings.Split(modifiers, "\n")
+		sort.Strings(p.Modifiers)
+	}
+	return p, nil
+}
+
+// resolveProvince returns the ID of the province designated by s, which is
+// either a province ID, a province name or one of its aliases. Names are
+// compared folded, see foldName. Province names are not unique, ambiguous
+// names must be replaced with the province ID.
+func resolveProvince(ctx context.Context, q querier, datasetId int, province string) (string, error) {
+	province = strings.TrimSpace(province)
+	if _, err := strconv.Atoi(province); err == nil {
+		var count int
+		err := q.QueryRowContext(ctx, "SELECT COUNT(1) FROM provinces WHERE dataset_id = ? AND id = ?", datasetId, province).Scan(&count)
+		if err != nil {
+			return "", fmt.Errorf("failed to scan: %w", err)
+		}
+		if count == 0 {
+			return "", fmt.Errorf("found no provinces with ID %s", province)
+		}
+		return province, nil
+	}
+
+	matches, err := matchProvinces(ctx, q, datasetId, province)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", ErrUnknownZone{Zone: Zone{Type: CLAIM_TYPE_PROVINCE, Name: province}}
+	case 1:
+		return matches[0].ID, nil
+	default:
+		ids := make([]string, 0, len(matches))
+		for _, m := range matches {
+			ids = append(ids, m.ID)
+		}
+		return "", fmt.Errorf("there are %d provinces named %s, use the province ID instead (one of %s)", len(ids), province, strings.Join(ids, ", "))
+	}
+}
+
+// provinceMatch is a province found by name, as returned by matchProvinces.
+type provinceMatch struct {
+	ID   string
+	Name string
+}
+
+// matchProvinces lists the provinces named name, or with name as an alias if
+// there are none, ordered by ID. Names are compared folded, and when several
+// provinces match only the ones spelled exactly like name are kept.
+func matchProvinces(ctx context.Context, q querier, datasetId int, name string) ([]provinceMatch, error) {
+	name = strings.TrimSpace(name)
+	matches := make([]provinceMatch, 0, 1)
+	for _, query := range []string{
+		"SELECT id, name FROM provinces WHERE dataset_id = ? AND fold_name(name) = ? ORDER BY CAST(id AS INTEGER)",
+		`SELECT provinces.id, provinces.name FROM zone_aliases
+		JOIN provinces ON provinces.dataset_id = zone_aliases.dataset_id AND provinces.id = zone_aliases.zone_id
+		WHERE zone_aliases.dataset_id = ? AND zone_aliases.claim_type = 'province' AND fold_name(zone_aliases.alias) = ?
+		ORDER BY CAST(provinces.id AS INTEGER)`,
+	} {
+		rows, err := q.QueryContext(ctx, query, datasetId, foldName(name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute query: %w", err)
+		}
+		for rows.Next() {
+			var m provinceMatch
+			if err := rows.Scan(&m.ID, &m.Name); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan row: %w", err)
+			}
+			matches = append(matches, m)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to iterate rows: %w", err)
+		}
+		if len(matches) > 0 {
+			break
+		}
+	}
+
+	// names only differing by their diacritics, the exact spellings win
+	exact := make([]provinceMatch, 0, len(matches))
+	for _, m := range matches {
+		if strings.EqualFold(m.Name, name) {
+			exact = append(exact, m)
+		}
+	}
+	if len(exact) > 0 {
+		return exact, nil
+	}
+	return matches, nil
+}
+
+// resolveZone returns the name under which the zone is stored in claims: the
+// province ID for provinces, the canonical name of the zone for all other
+// claim types. See resolveZoneID.
+func resolveZone(ctx context.Context, q querier, datasetId int, zone Zone) (string, error) {
+	_, name, err := resolveZoneID(ctx, q, datasetId, zone)
+	return name, err
+}
+
+// resolveRequest validates the zone and exclusions of a claim request and
+// returns them as they are stored in claims. Unknown zones come with
+// suggestions, see ErrUnknownZone.
+func resolveRequest(ctx context.Context, q querier, datasetId int, req ClaimRequest) (ClaimRequest, error) {
+	name, err := resolveZone(ctx, q, datasetId, req.Zone)
+	if err != nil {
+		return ClaimRequest{}, withSuggestions(ctx, q, datasetId, err)
+	}
+
+	exclusions, err := resolveExclusions(ctx, q, datasetId, name, req.Type, req.Exclusions)
+	if err != nil {
+		return ClaimRequest{}, withSuggestions(ctx, q, datasetId, err)
+	}
+
+	return ClaimRequest{Zone: Zone{Type: req.Type, Name: name}, Exclusions: exclusions}, nil
+}
+
+// zoneProvince is a province of a zone, as returned by zoneProvinces.
+type zoneProvince struct {
+	ID          string
+	Name        string
+	Development int
+}
+
+// zoneProvinces returns the provinces of a resolved zone of the types counted
+// by the campaign, leaving out the excluded zones.
+func zoneProvinces(ctx context.Context, q querier, campaignId, datasetId int, name string, claimType ClaimType, exclusions []Zone) ([]zoneProvince, error) {
+	query := fmt.Sprintf("SELECT id, name, CAST(development AS INTEGER) FROM provinces WHERE provinces.dataset_id = ? AND provinces.%s = ? AND %s",
+		claimTypeToColumn[claimType], provinceTypeCondition("?", "provinces.typ"))
+	params := []any{datasetId, name, campaignId}
+	for _, ex := range exclusions {
+		query += fmt.Sprintf(" AND provinces.%s IS NOT ?", claimTypeToColumn[ex.Type])
+		params = append(params, ex.Name)
+	}
+	query += " ORDER BY CAST(id AS INTEGER)"
+
+	rows, err := q.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	provinces := make([]zoneProvince, 0)
+	for rows.Next() {
+		p := zoneProvince{}
+		if err := rows.Scan(&p.ID, &p.Name, &p.Development); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		provinces = append(provinces, p)
+	}
+	return provinces, rows.Err()
+}
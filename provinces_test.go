@@ -0,0 +1,72 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProvince(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestGetProvince"))
+	assert.NoError(t, err)
+
+	p, err := store.GetProvince(context.TODO(), DEFAULT_DATASET, 118)
+	assert.NoError(t, err)
+	assert.Equal(t, Province{
+		ID:             118,
+		Name:           "Roma",
+		Type:           "Land",
+		Development:    25,
+		BaseTax:        9,
+		BaseProduction: 9,
+		BaseManpower:   7,
+		TradeNode:      "Genoa",
+		Area:           "Lazio-Umbria",
+		Region:         "Italy",
+		Superregion:    "Western Europe",
+		Continent:      "Europe",
+		Modifiers:      []string{"Natural Harbor", "Religious Center", "The Conquest of Rome"},
+	}, p)
+
+	// sea provinces have no development nor continent
+	p, err = store.GetProvince(context.TODO(), DEFAULT_DATASET, 1300)
+	assert.NoError(t, err)
+	assert.Equal(t, "Inland sea", p.Type)
+	assert.Equal(t, 0, p.Development)
+	assert.Equal(t, "", p.Continent)
+	assert.Equal(t, "Mediterranean", p.Region)
+
+	_, err = store.GetProvince(context.TODO(), DEFAULT_DATASET, 99999)
+	assert.ErrorIs(t, err, ErrNoSuchProvince)
+}
+
+func TestListProvinces(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestListProvinces"))
+	assert.NoError(t, err)
+
+	provinces, err := store.ListProvinces(context.TODO(), ProvinceFilter{Area: "gascony"})
+	assert.NoError(t, err)
+	names := make([]string, 0, len(provinces))
+	for _, p := range provinces {
+		names = append(names, p.Name)
+	}
+	assert.Equal(t, []string{"Labourd", "Armagnac", "Béarn", "Foix"}, names)
+
+	provinces, err = store.ListProvinces(context.TODO(), ProvinceFilter{Type: "Land", Continent: "Europe", MinDevelopment: 20})
+	assert.NoError(t, err)
+	assert.Equal(t, 18, len(provinces))
+
+	provinces, err = store.ListProvinces(context.TODO(), ProvinceFilter{Modifier: "Religious Center"})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, len(provinces))
+
+	provinces, err = store.ListProvinces(context.TODO(), ProvinceFilter{Name: "beja"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(provinces))
+
+	provinces, err = store.ListProvinces(context.TODO(), ProvinceFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3925, len(provinces))
+}
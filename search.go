@@ -0,0 +1,329 @@
+package themis
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ZoneMatch is a zone found by SearchZones along with how much of it the
+// claims of the campaign hold, through any claim type.
+type ZoneMatch struct {
+	ZoneAvailability
+	// Value designates the zone in claims: its name, or its ID for provinces
+	// sharing their name with another province.
+	Value string
+}
+
+// zoneEntry is a name a zone can be searched by, either its own or an alias.
+type zoneEntry struct {
+	match ZoneMatch
+	// zoneId is the ID of the zone, see claimTypeToIDColumn.
+	zoneId int
+	// key is the folded name, words holds the offsets of its words.
+	key   string
+	words []int
+}
+
+// zoneIndex caches the zone names of each dataset for SearchZones. It is
+// rebuilt when the Store changes the zones, or when another connection wrote
+// to the database as told by SQLite's data_version. The version is read on a
+// connection of its own, data_version is only comparable on one connection.
+type zoneIndex struct {
+	mu       sync.Mutex
+	conn     *sql.Conn
+	version  int64
+	datasets map[int][]zoneEntry
+}
+
+// invalidate drops the cached zones, once the Store changed them.
+func (idx *zoneIndex) invalidate() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.datasets = nil
+}
+
+// close releases the connection the version is read on.
+func (idx *zoneIndex) close() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.conn == nil {
+		return nil
+	}
+	err := idx.conn.Close()
+	idx.conn = nil
+	return err
+}
+
+// entries returns the searchable names of the zones of the dataset.
+func (idx *zoneIndex) entries(ctx context.Context, db *sql.DB, datasetId int) ([]zoneEntry, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.conn == nil {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open connection: %w", err)
+		}
+		idx.conn = conn
+	}
+	var version int64
+	if err := idx.conn.QueryRowContext(ctx, "PRAGMA data_version").Scan(&version); err != nil {
+		return nil, fmt.Errorf("failed to get data version: %w", err)
+	}
+	if version != idx.version || idx.datasets == nil {
+		idx.version = version
+		idx.datasets = make(map[int][]zoneEntry)
+	}
+	if entries, ok := idx.datasets[datasetId]; ok {
+		return entries, nil
+	}
+
+	entries, err := loadZoneEntries(ctx, db, datasetId)
+	if err != nil {
+		return nil, err
+	}
+	idx.datasets[datasetId] = entries
+	return entries, nil
+}
+
+// loadZoneEntries lists the names and aliases of the zones of the dataset.
+func loadZoneEntries(ctx context.Context, q querier, datasetId int) ([]zoneEntry, error) {
+	entries := make([]zoneEntry, 0)
+	zones := make(map[Zone]zoneEntry)
+	for _, ct := range ClaimTypes {
+		column := claimTypeToColumn[ct]
+		if ct == CLAIM_TYPE_PROVINCE {
+			column = "name"
+		}
+		rows, err := q.QueryContext(ctx, fmt.Sprintf(`SELECT %[1]s, MIN(%[2]s)
+		FROM provinces
+		WHERE dataset_id = ? AND %[1]s IS NOT NULL
+		GROUP BY %[1]s`, claimTypeToIDColumn[ct], column), datasetId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute query: %w", err)
+		}
+		for rows.Next() {
+			var e zoneEntry
+			e.match.Type = ct
+			if err := rows.Scan(&e.zoneId, &e.match.Name); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan row: %w", err)
+			}
+			e.match.Value = e.match.Name
+			if ct == CLAIM_TYPE_PROVINCE {
+				e.match.ProvinceID = e.zoneId
+			}
+			e.key, e.words = foldName(e.match.Name), wordOffsets(foldName(e.match.Name))
+			entries = append(entries, e)
+			zones[Zone{Type: ct, Name: strconv.Itoa(e.zoneId)}] = e
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to iterate rows: %w", err)
+		}
+	}
+
+	// provinces sharing their name are designated by ID
+	names := make(map[string]int)
+	for _, e := range entries {
+		if e.match.Type == CLAIM_TYPE_PROVINCE {
+			names[e.key]++
+		}
+	}
+	for i, e := range entries {
+		if e.match.Type == CLAIM_TYPE_PROVINCE && names[e.key] > 1 {
+			entries[i].match.Value = strconv.Itoa(e.zoneId)
+		}
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT claim_type, alias, zone_id FROM zone_aliases WHERE dataset_id = ?", datasetId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ct ClaimType
+		var alias string
+		var zoneId int
+		if err := rows.Scan(&ct, &alias, &zoneId); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		e, ok := zones[Zone{Type: ct, Name: strconv.Itoa(zoneId)}]
+		if !ok {
+			continue
+		}
+		e.key, e.words = foldName(alias), wordOffsets(foldName(alias))
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// wordOffsets lists the byte offsets of the words of a folded name.
+func wordOffsets(key string) []int {
+	offsets := make([]int, 0, 1)
+	start := true
+	for i, r := range key {
+		separator := r == ' ' || r == '-' || r == '\'' || r == '(' || r == '/'
+		if !separator && start {
+			offsets = append(offsets, i)
+		}
+		start = separator
+	}
+	return offsets
+}
+
+// Match tiers of SearchZones, the lowest first.
+const (
+	tierPrefix = iota
+	tierWordPrefix
+	tierSubstring
+	tierFuzzy
+)
+
+// rankEntry tells how well the entry matches the folded search: its tier and
+// how far from the search it is within the tier. ok is false if it doesn't
+// match at all.
+func rankEntry(e zoneEntry, search string) (tier, distance int, ok bool) {
+	if search == "" {
+		// everything matches, by name
+		return tierPrefix, 0, true
+	}
+	if strings.HasPrefix(e.key, search) {
+		return tierPrefix, len(e.key) - len(search), true
+	}
+	for _, offset := range e.words {
+		if strings.HasPrefix(e.key[offset:], search) {
+			return tierWordPrefix, len(e.key) - len(search), true
+		}
+	}
+	if strings.Contains(e.key, search) {
+		return tierSubstring, len(e.key) - len(search), true
+	}
+
+	// typos: the search is compared with the start of every word
+	searchLen := len([]rune(search))
+	if searchLen < 3 {
+		return 0, 0, false
+	}
+	maxDistance := searchLen / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	best := maxDistance + 1
+	for _, offset := range e.words {
+		word := []rune(e.key[offset:])
+		for _, n := range []int{searchLen - 1, searchLen, searchLen + 1} {
+			if n > len(word) || n <= 0 {
+				continue
+			}
+			if d := editDistance(string(word[:n]), search); d < best {
+				best = d
+			}
+		}
+	}
+	if best > maxDistance {
+		return 0, 0, false
+	}
+	return tierFuzzy, best, true
+}
+
+// SearchZones finds the zones of the campaign's dataset matching search, of
+// the given claim types or of all of them if none are given. Names and
+// aliases are compared folded, see foldName, zones whose name starts with the
+// search come first, then zones with a word starting with it, zones whose name
+// contains it, and finally zones with a word starting with a close spelling.
+// At most limit zones are returned, along with their availability, none when
+// limit isn't positive.
+func (s *Store) SearchZones(ctx context.Context, campaignId int, search string, limit int, types ...ClaimType) ([]ZoneMatch, error) {
+	if limit <= 0 {
+		return []ZoneMatch{}, nil
+	}
+	datasetId, err := campaignDataset(ctx, s.db, campaignId)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.zones.entries(ctx, s.db, datasetId)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[ClaimType]bool, len(types))
+	for _, ct := range types {
+		wanted[ct] = true
+	}
+	rank := make(map[ClaimType]int, len(ClaimTypes))
+	for i, ct := range ClaimTypes {
+		rank[ct] = i
+	}
+
+	type result struct {
+		entry    zoneEntry
+		tier     int
+		distance int
+	}
+	// a zone matching both by its name and an alias is listed once, with its
+	// best rank
+	best := make(map[ZoneMatch]result)
+	search = foldName(search)
+	for _, e := range entries {
+		if len(wanted) > 0 && !wanted[e.match.Type] {
+			continue
+		}
+		tier, distance, ok := rankEntry(e, search)
+		if !ok {
+			continue
+		}
+		if r, ok := best[e.match]; ok && (r.tier < tier || (r.tier == tier && r.distance <= distance)) {
+			continue
+		}
+		best[e.match] = result{entry: e, tier: tier, distance: distance}
+	}
+
+	results := make([]result, 0, len(best))
+	for _, r := range best {
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.tier != b.tier {
+			return a.tier < b.tier
+		}
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		if a.entry.match.Name != b.entry.match.Name {
+			return a.entry.match.Name < b.entry.match.Name
+		}
+		if a.entry.match.Type != b.entry.match.Type {
+			return rank[a.entry.match.Type] < rank[b.entry.match.Type]
+		}
+		return a.entry.zoneId < b.entry.zoneId
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	matches := make([]ZoneMatch, 0, len(results))
+	for _, r := range results {
+		m := r.entry.match
+		idColumn, column := claimTypeToIDColumn[m.Type], claimTypeToColumn[m.Type]
+		if m.Type == CLAIM_TYPE_PROVINCE {
+			column = "name"
+		}
+		query := fmt.Sprintf(availabilityQuery, idColumn, column, fmt.Sprintf(" AND provinces.%s = ?3", idColumn), provinceTypeCondition("?2", "provinces.typ"))
+		err := s.db.QueryRowContext(ctx, query, datasetId, campaignId, r.entry.zoneId).
+			Scan(new(int), new(string), &m.Provinces, &m.Free, &m.Development, &m.FreeDevelopment)
+		// zones without provinces of the types counted by the campaign are
+		// left empty
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to count free provinces: %w", err)
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
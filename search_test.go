@@ -0,0 +1,81 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchZones(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestSearchZones"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.TODO()
+	campaignId, err := store.CreateCampaign(ctx, TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+	_, err = store.Claim(ctx, campaignId, "000000000000000001", "foo", "Liguria", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+
+	// names starting with the search first, the shortest first
+	matches, err := store.SearchZones(ctx, campaignId, "gen", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZoneMatch{
+		{ZoneAvailability: ZoneAvailability{Zone: Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Genoa"}, ProvinceID: 101, Provinces: 1, Development: 25}, Value: "Genoa"},
+		{ZoneAvailability: ZoneAvailability{Zone: Zone{Type: CLAIM_TYPE_TRADE, Name: "Genoa"}, Provinces: 41, Free: 38, Development: 509, FreeDevelopment: 461}, Value: "Genoa"},
+		{ZoneAvailability: ZoneAvailability{Zone: Zone{Type: CLAIM_TYPE_PROVINCE, Name: "Geneva"}, ProvinceID: 4720, Provinces: 1, Free: 1, Development: 13, FreeDevelopment: 13}, Value: "Geneva"},
+	}, matches)
+	assert.Equal(t, "Genoa (Province #101) - taken, 25 development", matches[0].String())
+	assert.Equal(t, "Genoa (Trade Node) - partially taken, 38/41 provinces free, 461/509 development free", matches[1].String())
+	assert.Equal(t, "Geneva (Province #4720) - free, 13 development", matches[2].String())
+
+	// then names with a word starting with it, then names containing it
+	matches, err = store.SearchZones(ctx, campaignId, "geneva", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "Geneva", matches[0].Name)
+	assert.Equal(t, "Lake Geneva", matches[1].Name)
+	matches, err = store.SearchZones(ctx, campaignId, "hinel", 25, CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	assert.Equal(t, []Zone{{Type: CLAIM_TYPE_AREA, Name: "Lower Rhineland"}}, []Zone{matches[0].Zone})
+
+	// diacritics and typos
+	matches, err = store.SearchZones(ctx, campaignId, "ostra sv", 25)
+	assert.NoError(t, err)
+	assert.Equal(t, "Östra Svealand", matches[0].Name)
+	matches, err = store.SearchZones(ctx, campaignId, "genao", 2, CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(matches))
+	assert.Equal(t, "Genoa", matches[0].Name)
+
+	// provinces sharing their name are designated by ID
+	matches, err = store.SearchZones(ctx, campaignId, "beja", 2, CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+	assert.Equal(t, "229", matches[0].Value)
+	assert.Equal(t, "1226", matches[1].Value)
+
+	// aliases lead to their zone, which is listed once
+	assert.NoError(t, store.AddZoneAlias(ctx, DEFAULT_DATASET, Zone{Type: CLAIM_TYPE_TRADE, Name: "Genoa"}, "Zena"))
+	matches, err = store.SearchZones(ctx, campaignId, "genoa", 25, CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(matches))
+	matches, err = store.SearchZones(ctx, campaignId, "zen", 1, CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+	assert.Equal(t, "Genoa", matches[0].Value)
+
+	matches, err = store.SearchZones(ctx, campaignId, "Xyzzy", 25)
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+	for _, limit := range []int{0, -1} {
+		matches, err = store.SearchZones(ctx, campaignId, "genoa", limit)
+		assert.NoError(t, err)
+		assert.Empty(t, matches)
+	}
+
+	var null bool
+	var folded string
+	assert.NoError(t, store.db.QueryRowContext(ctx, "SELECT fold_name(NULL) IS NULL, fold_name('Östra Svealand')").Scan(&null, &folded))
+	assert.True(t, null)
+	assert.Equal(t, "ostra svealand", folded)
+}
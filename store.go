@@ -3,29 +3,37 @@ package themis
 import (
 	"context"
 	"database/sql"
-	_ "embed"
 	"fmt"
 	"strings"
-
-	_ "github.com/mattn/go-sqlite3"
+	"time"
 )
 
-//go:embed migrations/init.sql
-var initScript string
+// CONN_STRING_PATTERN is the connection string of a database file, formatted
+// with its path. Transactions take the write lock as they begin and wait for
+// the other connections, of this process or of another one, to release it
+// instead of failing with SQLITE_BUSY.
+const CONN_STRING_PATTERN = "file:%s?mode=rw&_journal_mode=WAL&_txlock=immediate&_busy_timeout=10000"
 
 type Store struct {
-	db *sql.DB
+	db    *sql.DB
+	zones zoneIndex
 }
 
+// NewStore opens the database and applies the pending migrations. It fails if
+// the database was migrated by a more recent version of themis.
 func NewStore(conn string) (*Store, error) {
-	db, err := sql.Open("sqlite3", conn)
+	db, err := sql.Open(SQLITE_DRIVER, conn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	_, err = db.Exec(initScript)
+	migrator, err := newMigrator(db)
 	if err != nil {
-		return nil, fmt.Errorf("failed to run init script: %w", err)
+		return nil, err
+	}
+
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	return &Store{
@@ -34,113 +42,198 @@ func NewStore(conn string) (*Store, error) {
 }
 
 func (s *Store) Close() error {
+	if err := s.zones.close(); err != nil {
+		return err
+	}
 	return s.db.Close()
 }
 
-func (s *Store) Claim(ctx context.Context, userId, player, province string, claimType ClaimType) (int, error) {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return 0, fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Commit() //nolint:errcheck
+// querier is implemented by both *sql.DB and *sql.Tx, it lets the same
+// queries run on their own or as part of a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
 
-	conflicts, err := s.FindConflicts(ctx, userId, province, claimType)
+// Claim takes a claim on a zone for the player. Provinces of the excluded zones
+// are not part of the claim, they must be inside the claimed zone.
+func (s *Store) Claim(ctx context.Context, campaignId int, userId, player, province string, claimType ClaimType, exclusions ...Zone) (int, error) {
+	ids, err := s.ClaimMany(ctx, campaignId, userId, player, ClaimRequest{
+		Zone:       Zone{Type: claimType, Name: province},
+		Exclusions: exclusions,
+	})
 	if err != nil {
-		return 0, fmt.Errorf("failed to run conflicts check: %w", err)
+		return 0, err
 	}
+	return ids[0], nil
+}
 
-	if len(conflicts) > 0 {
-		return 0, ErrConflict{Conflicts: conflicts}
+// ClaimMany takes claims on all the requested zones for the player, or none of
+// them. The zones are checked against the claims of other players, the
+// returned ErrConflict holds the conflicts of every zone. They may overlap each
+// other, like the claims a player makes one at a time. When the
+// campaign requires contiguous claims, each zone must touch the player's other
+// claims, including the ones before it in the request, or ErrNotContiguous is
+// returned. Zones without provinces of the types counted by the campaign can't
+// be claimed, see ErrNoProvinces. It returns the claim IDs in the order of the
+// requests.
+func (s *Store) ClaimMany(ctx context.Context, campaignId int, userId, player string, requests ...ClaimRequest) ([]int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback() //nolint:errcheck
 
-	// check that provided name matches the claim type
-	stmt, err := s.db.PrepareContext(ctx, fmt.Sprintf(`SELECT COUNT(1) FROM provinces WHERE LOWER(provinces.%s) = ?`, claimTypeToColumn[claimType]))
+	datasetId, err := campaignDataset(ctx, tx, campaignId)
 	if err != nil {
-		return 0, fmt.Errorf("failed to prepare count query: %w", err)
+		return nil, err
 	}
-
-	row := stmt.QueryRowContext(ctx, strings.ToLower(province))
-	var count int
-	err = row.Scan(&count)
+	var contiguous bool
+	var provinceTypes string
+	err = tx.QueryRowContext(ctx, "SELECT contiguous_claims, province_types FROM campaigns WHERE id = ?", campaignId).Scan(&contiguous, &provinceTypes)
 	if err != nil {
-		return 0, fmt.Errorf("failed to scan: %w", err)
+		return nil, fmt.Errorf("failed to scan row: %w", err)
 	}
 
-	if count == 0 {
-		return 0, fmt.Errorf("found no provinces for %s named %s", claimType, province)
-	}
+	resolved := make([]ClaimRequest, 0, len(requests))
+	conflicts := make([]Conflict, 0)
+	for i, req := range requests {
+		req, err = resolveRequest(ctx, tx, datasetId, req)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, req)
 
-	stmt, err = s.db.PrepareContext(ctx, "INSERT INTO claims (player, claim_type, val, userid) VALUES (?, ?, ?, ?)")
-	if err != nil {
-		return 0, fmt.Errorf("failed to prepare claim query: %w", err)
+		found, err := findConflicts(ctx, tx, campaignId, datasetId, userId, req.Name, req.Type, req.Exclusions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run conflicts check: %w", err)
+		}
+		conflicts = append(conflicts, found...)
+
+		provinces, err := zoneProvinces(ctx, tx, campaignId, datasetId, req.Name, req.Type, req.Exclusions)
+		if err != nil {
+			return nil, err
+		}
+		if len(provinces) == 0 {
+			return nil, ErrNoProvinces{Zone: requests[i].Zone, ProvinceTypes: strings.Split(provinceTypes, ",")}
+		}
 	}
 
-	res, err := stmt.ExecContext(ctx, player, claimType, province, userId)
-	if err != nil {
-		return 0, fmt.Errorf("failed to insert claim: %w", err)
+	if len(conflicts) > 0 {
+		return nil, ErrConflict{Conflicts: conflicts}
 	}
 
-	id, err := res.LastInsertId()
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO claims (player, claim_type, val, zone_id, userid, campaign_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
-		return 0, fmt.Errorf("failed to get last ID: %w", err)
+		return nil, fmt.Errorf("failed to prepare claim query: %w", err)
 	}
 
-	return int(id), nil
-}
+	ids := make([]int, 0, len(resolved))
+	for i, req := range resolved {
+		zoneId, _, err := resolveZoneID(ctx, tx, datasetId, req.Zone)
+		if err != nil {
+			return nil, err
+		}
+		res, err := stmt.ExecContext(ctx, player, req.Type, req.Name, zoneId, userId, campaignId, time.Now().UTC())
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert claim: %w", err)
+		}
 
-func (s *Store) ListAvailability(ctx context.Context, claimType ClaimType, search ...string) ([]string, error) {
-	queryParams := []any{string(claimType)}
-	queryPattern := `SELECT DISTINCT(provinces.%[1]s)
-	FROM provinces LEFT JOIN claims ON provinces.%[1]s = claims.val AND claims.claim_type = ?
-	WHERE claims.val IS NULL
-	AND provinces.typ = 'Land'`
-	if len(search) > 0 && search[0] != "" {
-		// only take one search param, ignore the rest
-		queryPattern += `AND provinces.%[1]s LIKE ?`
-		queryParams = append(queryParams, fmt.Sprintf("%%%s%%", search[0]))
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get last ID: %w", err)
+		}
+
+		if err := insertExclusions(ctx, tx, int(id), req.Exclusions); err != nil {
+			return nil, err
+		}
+
+		if err := insertClaimProvinces(ctx, tx, int(id)); err != nil {
+			return nil, err
+		}
+
+		// claims of a bundle may touch the ones before them
+		if contiguous {
+			touches, err := claimTouchesHoldings(ctx, tx, datasetId, int(id))
+			if err != nil {
+				return nil, err
+			}
+			if !touches {
+				return nil, ErrNotContiguous{Zone: requests[i].Zone}
+			}
+		}
+
+		err = recordEvent(ctx, tx, campaignId, int(id), EVENT_TYPE_CREATED, userId, EventPayload{
+			Player:     player,
+			UserID:     userId,
+			ClaimType:  req.Type,
+			Name:       req.Name,
+			Exclusions: req.Exclusions,
+		})
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, int(id))
 	}
 
-	stmt, err := s.db.PrepareContext(ctx, fmt.Sprintf(queryPattern, claimTypeToColumn[claimType]))
-	if err != nil {
-		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	rows, err := stmt.QueryContext(ctx, queryParams...)
+	return ids, nil
+}
+
+// ListAvailability lists the names of the zones of the claim type that can be
+// claimed whole, none of their provinces being held by a claim of any
+// type. Only the first search term is used, it keeps the zones whose name
+// contains it.
+func (s *Store) ListAvailability(ctx context.Context, campaignId int, claimType ClaimType, search ...string) ([]string, error) {
+	filter := AvailabilityFilter{Type: claimType, MinFreePercent: 100}
+	if len(search) > 0 {
+		filter.Search = search[0]
+	}
+	zones, err := s.Availability(ctx, campaignId, filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %w", err)
+		return nil, err
 	}
 
-	avail := make([]string, 0)
-	for rows.Next() {
-		var s string
-		if err := rows.Scan(&s); err != nil {
-			return nil, fmt.Errorf("failed to scan rows: %w", err)
+	avail := make([]string, 0, len(zones))
+	for _, z := range zones {
+		// provinces sharing their name are listed once
+		if len(avail) > 0 && avail[len(avail)-1] == z.Name {
+			continue
 		}
-		avail = append(avail, s)
+		avail = append(avail, z.Name)
 	}
-
 	return avail, nil
 }
 
-func (s *Store) ListClaims(ctx context.Context) ([]Claim, error) {
-	stmt, err := s.db.PrepareContext(ctx, `SELECT id, player, claim_type, val FROM claims`)
+func (s *Store) ListClaims(ctx context.Context, campaignId int) ([]Claim, error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT id, player, claim_type, val, userid, created_at FROM claims WHERE campaign_id = ? ORDER BY id`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare query: %w", err)
 	}
 
-	rows, err := stmt.QueryContext(ctx)
+	rows, err := stmt.QueryContext(ctx, campaignId)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
+	defer rows.Close()
 
 	claims := make([]Claim, 0)
 	for rows.Next() {
 		c := Claim{}
-		var rawType string
-		err = rows.Scan(&c.ID, &c.Player, &rawType, &c.Name)
+		var (
+			rawType   string
+			createdAt sql.NullTime
+		)
+		err = rows.Scan(&c.ID, &c.Player, &rawType, &c.Name, &c.UserID, &createdAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
+		c.CreatedAt = createdAt.Time
 		cl, err := ClaimTypeFromString(rawType)
 		if err != nil {
 			return nil, fmt.Errorf("unexpected error converting raw claim type: %w", err)
@@ -155,29 +248,40 @@ func (s *Store) ListClaims(ctx context.Context) ([]Claim, error) {
 
 type ClaimDetail struct {
 	Claim
-	Provinces []string
+	// Provinces held through the claim, excluded provinces are left out.
+	Provinces  []string
+	Exclusions []Zone
+	// Orphaned is true when the claimed zone doesn't exist in the campaign's
+	// dataset anymore, the claim holds no provinces until it is remapped.
+	Orphaned bool
 }
 
 func (cd ClaimDetail) String() string {
 	sb := strings.Builder{}
 	sb.WriteString(fmt.Sprintf("%s\n", cd.Claim))
+	if cd.Orphaned {
+		sb.WriteString(fmt.Sprintf("  %s %s no longer exists, the claim must be remapped\n", cd.Type, cd.Name))
+	}
 	for _, p := range cd.Provinces {
 		sb.WriteString(fmt.Sprintf("  - %s\n", p))
 	}
+	for _, ex := range cd.Exclusions {
+		sb.WriteString(fmt.Sprintf("  except %s\n", ex))
+	}
 	return sb.String()
 }
 
-func (s *Store) DescribeClaim(ctx context.Context, ID int) (ClaimDetail, error) {
-	stmt, err := s.db.PrepareContext(ctx, `SELECT id, player, claim_type, val FROM claims WHERE id = ?`)
+func (s *Store) DescribeClaim(ctx context.Context, campaignId, ID int) (ClaimDetail, error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT id, player, claim_type, val, userid FROM claims WHERE id = ? AND campaign_id = ?`)
 	if err != nil {
 		return ClaimDetail{}, fmt.Errorf("failed to get claim: %w", err)
 	}
 
-	row := stmt.QueryRowContext(ctx, ID)
+	row := stmt.QueryRowContext(ctx, ID, campaignId)
 
 	c := Claim{}
 	var rawType string
-	err = row.Scan(&c.ID, &c.Player, &rawType, &c.Name)
+	err = row.Scan(&c.ID, &c.Player, &rawType, &c.Name, &c.UserID)
 	if err == sql.ErrNoRows {
 		return ClaimDetail{}, ErrNoSuchClaim
 	}
@@ -190,15 +294,22 @@ func (s *Store) DescribeClaim(ctx context.Context, ID int) (ClaimDetail, error)
 	}
 	c.Type = cl
 
-	stmt, err = s.db.PrepareContext(ctx, fmt.Sprintf(`SELECT name FROM provinces where provinces.%s = ?`, claimTypeToColumn[cl]))
+	datasetId, err := campaignDataset(ctx, s.db, campaignId)
+	if err != nil {
+		return ClaimDetail{}, err
+	}
+
+	stmt, err = s.db.PrepareContext(ctx, fmt.Sprintf(`SELECT name FROM provinces where provinces.dataset_id = ? AND provinces.%s = ? AND NOT %s AND %s`,
+		claimTypeToColumn[cl], excludedCondition("?"), provinceTypeCondition("?", "provinces.typ")))
 	if err != nil {
 		return ClaimDetail{}, fmt.Errorf("failed to prepare query: %w", err)
 	}
 
-	rows, err := stmt.QueryContext(ctx, c.Name)
+	rows, err := stmt.QueryContext(ctx, datasetId, c.Name, c.ID, campaignId)
 	if err != nil {
 		return ClaimDetail{}, fmt.Errorf("failed to execute query: %w", err)
 	}
+	defer rows.Close()
 
 	provinces := make([]string, 0)
 	for rows.Next() {
@@ -210,40 +321,115 @@ func (s *Store) DescribeClaim(ctx context.Context, ID int) (ClaimDetail, error)
 		provinces = append(provinces, p)
 	}
 
+	if err := rows.Err(); err != nil {
+		return ClaimDetail{}, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	exclusions, err := s.listExclusions(ctx, c.ID)
+	if err != nil {
+		return ClaimDetail{}, err
+	}
+
+	orphaned := false
+	if len(provinces) == 0 {
+		err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT NOT %s", zoneExistsCondition("?1", "?2", "?3")), c.Type, c.Name, datasetId).Scan(&orphaned)
+		if err != nil {
+			return ClaimDetail{}, fmt.Errorf("failed to check claimed zone: %w", err)
+		}
+	}
+
 	return ClaimDetail{
-		Claim:     c,
-		Provinces: provinces,
+		Claim:      c,
+		Provinces:  provinces,
+		Exclusions: exclusions,
+		Orphaned:   orphaned,
 	}, nil
 }
 
-func (s *Store) DeleteClaim(ctx context.Context, ID int, userId string) error {
-	stmt, err := s.db.PrepareContext(ctx, "DELETE FROM claims WHERE id = ? AND userid = ?")
+func (s *Store) DeleteClaim(ctx context.Context, campaignId, ID int, userId string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to prepare query: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback() //nolint:errcheck
 
-	res, err := stmt.ExecContext(ctx, ID, userId)
+	c, err := getClaim(ctx, tx, campaignId, ID)
+	if err != nil {
+		return err
+	}
+	if c.UserID != userId {
+		return ErrNoSuchClaim
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM claims WHERE id = ?", ID)
 	if err != nil {
 		return fmt.Errorf("failed to delete claim ID %d: %w", ID, err)
 	}
 
-	rows, err := res.RowsAffected()
+	_, err = tx.ExecContext(ctx, "DELETE FROM claim_exclusions WHERE claim_id = ?", ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete exclusions of claim ID %d: %w", ID, err)
+	}
+
+	if err := recordEvent(ctx, tx, campaignId, ID, EVENT_TYPE_RELEASED, userId, payloadFromClaim(c)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// TransferClaim hands over one of the user's claims to another player.
+func (s *Store) TransferClaim(ctx context.Context, campaignId, ID int, userId, toUserId, toPlayer string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	c, err := getClaim(ctx, tx, campaignId, ID)
 	if err != nil {
-		return fmt.Errorf("failed to get affected rows: %w", err)
+		return err
 	}
-	if rows == 0 {
+	if c.UserID != userId {
 		return ErrNoSuchClaim
 	}
+
+	conflicts, err := claimOverlaps(ctx, tx, ID, toUserId)
+	if err != nil {
+		return fmt.Errorf("failed to run conflicts check: %w", err)
+	}
+	if len(conflicts) > 0 {
+		return ErrConflict{Conflicts: conflicts}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE claims SET player = ?, userid = ? WHERE id = ?", toPlayer, toUserId, ID)
+	if err != nil {
+		return fmt.Errorf("failed to transfer claim ID %d: %w", ID, err)
+	}
+
+	payload := payloadFromClaim(c)
+	payload.PreviousPlayer, payload.PreviousUserID = payload.Player, payload.UserID
+	payload.Player, payload.UserID = toPlayer, toUserId
+	if err := recordEvent(ctx, tx, campaignId, ID, EVENT_TYPE_TRANSFERRED, userId, payload); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
 
-func (s *Store) CountClaims(ctx context.Context) (total, uniquePlayers int, err error) {
-	stmt, err := s.db.PrepareContext(ctx, "SELECT COUNT(1), COUNT(DISTINCT(userid)) FROM claims")
+func (s *Store) CountClaims(ctx context.Context, campaignId int) (total, uniquePlayers int, err error) {
+	stmt, err := s.db.PrepareContext(ctx, "SELECT COUNT(1), COUNT(DISTINCT(userid)) FROM claims WHERE campaign_id = ?")
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to prepare query: %w", err)
 	}
 
-	res := stmt.QueryRowContext(ctx)
+	res := stmt.QueryRowContext(ctx, campaignId)
 
 	if err := res.Scan(&total, &uniquePlayers); err != nil {
 		return 0, 0, fmt.Errorf("failed to scan result: %w", err)
@@ -252,10 +438,85 @@ func (s *Store) CountClaims(ctx context.Context) (total, uniquePlayers int, err
 	return total, uniquePlayers, nil
 }
 
-func (s *Store) Flush(ctx context.Context) error {
-	_, err := s.db.ExecContext(ctx, "DELETE FROM claims;")
+// Flush clears the campaign's board. The claims and their exclusions are first
+// archived under the given name, see RestoreArchive to put them back. It
+// returns the ID of the archive.
+func (s *Store) Flush(ctx context.Context, campaignId int, userId, name string) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to execute delete query: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, player, claim_type, val, userid FROM claims WHERE campaign_id = ?", campaignId)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute query: %w", err)
+	}
+	claims := make([]Claim, 0)
+	for rows.Next() {
+		c := Claim{}
+		if err := rows.Scan(&c.ID, &c.Player, &c.Type, &c.Name, &c.UserID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan row: %w", err)
+		}
+		claims = append(claims, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	archiveId, err := archiveClaims(ctx, tx, campaignId, name)
+	if err != nil {
+		return 0, err
+	}
+	exclusions, err := archivedExclusions(ctx, tx, archiveId)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, c := range claims {
+		payload := payloadFromClaim(c)
+		payload.Exclusions = exclusions[c.ID]
+		if err := recordEvent(ctx, tx, campaignId, c.ID, EVENT_TYPE_FLUSHED, userId, payload); err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM claim_exclusions WHERE claim_id IN (SELECT id FROM claims WHERE campaign_id = ?)", campaignId)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete claim exclusions: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM claims WHERE campaign_id = ?;", campaignId)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute delete query: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return archiveId, nil
+}
+
+func getClaim(ctx context.Context, tx *sql.Tx, campaignId, ID int) (Claim, error) {
+	c := Claim{}
+	err := tx.QueryRowContext(ctx, "SELECT id, player, claim_type, val, userid FROM claims WHERE id = ? AND campaign_id = ?", ID, campaignId).
+		Scan(&c.ID, &c.Player, &c.Type, &c.Name, &c.UserID)
+	if err == sql.ErrNoRows {
+		return Claim{}, ErrNoSuchClaim
+	}
+	if err != nil {
+		return Claim{}, fmt.Errorf("failed to scan row: %w", err)
+	}
+	return c, nil
+}
+
+func payloadFromClaim(c Claim) EventPayload {
+	return EventPayload{
+		Player:    c.Player,
+		UserID:    c.UserID,
+		ClaimType: c.Type,
+		Name:      c.Name,
 	}
-	return nil
 }
@@ -4,18 +4,27 @@ package themis
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
 
-const TEST_CONN_STRING_PATTERN = "file:%s?mode=memory&cache=shared"
+const (
+	TEST_CONN_STRING_PATTERN = "file:%s?mode=memory&cache=shared"
+	TEST_GUILD_ID            = "000000000000000100"
+)
 
 func TestStore_Claim(t *testing.T) {
 	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestStore_Claim"))
 	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
 
 	type args struct {
 		player    string
@@ -68,6 +77,26 @@ func TestStore_Claim(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "superregion",
+			args: args{
+				player:    "baz",
+				province:  "Far East",
+				claimType: CLAIM_TYPE_SUPERREGION,
+				userId:    "000000000000000003",
+			},
+			wantErr: false,
+		},
+		{
+			name: "continent conflicts",
+			args: args{
+				player:    "bar",
+				province:  "Europe",
+				claimType: CLAIM_TYPE_CONTINENT,
+				userId:    "000000000000000002",
+			},
+			wantErr: true,
+		},
 		{
 			name: "case sensitivity lower",
 			args: args{
@@ -91,7 +120,7 @@ func TestStore_Claim(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if _, err := store.Claim(context.TODO(), tt.args.userId, tt.args.player, tt.args.province, tt.args.claimType); (err != nil) != tt.wantErr {
+			if _, err := store.Claim(context.TODO(), campaignId, tt.args.userId, tt.args.player, tt.args.province, tt.args.claimType); (err != nil) != tt.wantErr {
 				t.Errorf("Store.Claim() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -101,70 +130,105 @@ func TestStore_Claim(t *testing.T) {
 func TestAvailability(t *testing.T) {
 	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestAvailability"))
 	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
 
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Genoa", CLAIM_TYPE_TRADE)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Venice", CLAIM_TYPE_TRADE)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "English Channel", CLAIM_TYPE_TRADE)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Genoa", CLAIM_TYPE_TRADE)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Venice", CLAIM_TYPE_TRADE)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "English Channel", CLAIM_TYPE_TRADE)
 
 	// There's a total of 80 distinct trade nodes, there should be 77 available
 	// after the three claims above
-	availability, err := store.ListAvailability(context.TODO(), CLAIM_TYPE_TRADE)
+	availability, err := store.ListAvailability(context.TODO(), campaignId, CLAIM_TYPE_TRADE)
 	assert.NoError(t, err)
 	assert.Equal(t, 77, len(availability))
 
-	store.Claim(context.TODO(), "000000000000000001", "foo", "France", CLAIM_TYPE_REGION)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Italy", CLAIM_TYPE_REGION)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "France", CLAIM_TYPE_REGION)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Italy", CLAIM_TYPE_REGION)
+
+	// There's a total of 73 distinct regions, France and Italy are claimed and
+	// the trade nodes claimed above hold provinces in three more, leaving 68
+	// regions that can be claimed whole
+	availability, err = store.ListAvailability(context.TODO(), campaignId, CLAIM_TYPE_REGION)
+	assert.NoError(t, err)
+	assert.Equal(t, 68, len(availability))
+	assert.NotContains(t, availability, "Low Countries")
+
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Normandy", CLAIM_TYPE_AREA)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Champagne", CLAIM_TYPE_AREA)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Lorraine", CLAIM_TYPE_AREA)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Provence", CLAIM_TYPE_AREA)
 
-	// There's a total of 73 distinct regions, there should be 71 available
-	// after the two claims above
-	availability, err = store.ListAvailability(context.TODO(), CLAIM_TYPE_REGION)
+	// There's a total of 823 distinct areas, the ones overlapping the claims of
+	// any type above aren't available anymore
+	availability, err = store.ListAvailability(context.TODO(), campaignId, CLAIM_TYPE_AREA)
 	assert.NoError(t, err)
-	assert.Equal(t, 71, len(availability))
+	assert.Equal(t, 778, len(availability))
 
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Normandy", CLAIM_TYPE_AREA)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Champagne", CLAIM_TYPE_AREA)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Lorraine", CLAIM_TYPE_AREA)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Provence", CLAIM_TYPE_AREA)
+	// There is both a Trade Node and an Area called 'Valencia', once the trade
+	// node is claimed the area is taken too
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Valencia", CLAIM_TYPE_TRADE)
+	availability, err = store.ListAvailability(context.TODO(), campaignId, CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	assert.Equal(t, 774, len(availability))
+	assert.NotContains(t, availability, "Valencia")
+
+	availability, err = store.ListAvailability(context.TODO(), campaignId, CLAIM_TYPE_AREA, "bay")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(availability))
 
-	// There's a total of 823 distinct regions, there should be 819 available
-	// after the four claims above
-	availability, err = store.ListAvailability(context.TODO(), CLAIM_TYPE_AREA)
+	// partially taken zones
+	zones, err := store.Availability(context.TODO(), campaignId, AvailabilityFilter{Type: CLAIM_TYPE_REGION, Search: "low countries"})
 	assert.NoError(t, err)
-	assert.Equal(t, 819, len(availability))
+	assert.Equal(t, []ZoneAvailability{
+		{Zone: Zone{Type: CLAIM_TYPE_REGION, Name: "Low Countries"}, Provinces: 22, Free: 4, Development: 291, FreeDevelopment: 59},
+	}, zones)
+	assert.Equal(t, AVAILABILITY_PARTIAL, zones[0].Status())
+	assert.Equal(t, 18, zones[0].FreePercent())
+	assert.Equal(t, "Low Countries (Region) - partially taken, 4/22 provinces free, 59/291 development free", zones[0].String())
 
-	// There is both a Trade Node and an Area called 'Valencia', while the trade
-	// node is claimed, the area should show up in the availability list (even
-	// though there are conflicting provinces)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Valencia", CLAIM_TYPE_TRADE)
-	availability, err = store.ListAvailability(context.TODO(), CLAIM_TYPE_AREA)
+	zones, err = store.Availability(context.TODO(), campaignId, AvailabilityFilter{Type: CLAIM_TYPE_REGION, Search: "france"})
 	assert.NoError(t, err)
-	assert.Equal(t, 819, len(availability)) // availability for areas should be the same as before
+	assert.Equal(t, AVAILABILITY_TAKEN, zones[0].Status())
+	assert.Equal(t, "France (Region) - taken, 66 provinces, 806 development", zones[0].String())
 
-	availability, err = store.ListAvailability(context.TODO(), CLAIM_TYPE_AREA, "bay")
+	// continent and minimum free percentage
+	zones, err = store.Availability(context.TODO(), campaignId, AvailabilityFilter{Type: CLAIM_TYPE_AREA, Continent: "europe"})
 	assert.NoError(t, err)
-	assert.Equal(t, 3, len(availability)) // availability for areas should be the same as before
+	assert.Equal(t, 214, len(zones))
+	zones, err = store.Availability(context.TODO(), campaignId, AvailabilityFilter{Type: CLAIM_TYPE_AREA, Continent: "europe", MinFreePercent: 50})
+	assert.NoError(t, err)
+	assert.Equal(t, 165, len(zones))
+	for _, z := range zones {
+		assert.GreaterOrEqual(t, z.FreePercent(), 50, z.Name)
+	}
+
+	_, err = store.Availability(context.TODO(), campaignId, AvailabilityFilter{Continent: "europa"})
+	assert.ErrorAs(t, err, &ErrUnknownZone{})
 }
 
 func TestDeleteClaim(t *testing.T) {
 	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestDeleteClaim"))
 	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
 	// make sure all claims are gone, this is due to how the in-memory database
 	// with a shared cache interacts with other tests running in parallel
 	_, err = store.db.ExecContext(context.TODO(), "DELETE FROM claims")
 	assert.NoError(t, err)
 
-	fooId, _ := store.Claim(context.TODO(), "000000000000000001", "foo", "Genoa", CLAIM_TYPE_TRADE)
-	barId, _ := store.Claim(context.TODO(), "000000000000000002", "bar", "Balkans", CLAIM_TYPE_REGION)
-	store.Claim(context.TODO(), "000000000000000003", "baz", "English Channel", CLAIM_TYPE_TRADE)
+	fooId, _ := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Genoa", CLAIM_TYPE_TRADE)
+	barId, _ := store.Claim(context.TODO(), campaignId, "000000000000000002", "bar", "Balkans", CLAIM_TYPE_REGION)
+	store.Claim(context.TODO(), campaignId, "000000000000000003", "baz", "English Channel", CLAIM_TYPE_TRADE)
 
-	claims, err := store.ListClaims(context.TODO())
+	claims, err := store.ListClaims(context.TODO(), campaignId)
 	assert.NoError(t, err)
 	fmt.Print(claims)
 
-	err = store.DeleteClaim(context.TODO(), fooId, "000000000000000001")
+	err = store.DeleteClaim(context.TODO(), campaignId, fooId, "000000000000000001")
 	assert.NoError(t, err)
 
-	err = store.DeleteClaim(context.TODO(), barId, "000000000000000001")
+	err = store.DeleteClaim(context.TODO(), campaignId, barId, "000000000000000001")
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, ErrNoSuchClaim)
 }
@@ -172,30 +236,34 @@ func TestDeleteClaim(t *testing.T) {
 func TestDescribeClaim(t *testing.T) {
 	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestDescribeClaim"))
 	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
 
-	id, err := store.Claim(context.TODO(), "000000000000000001", "foo", "Genoa", CLAIM_TYPE_TRADE)
+	id, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Genoa", CLAIM_TYPE_TRADE)
 	assert.NoError(t, err)
 
-	detail, err := store.DescribeClaim(context.TODO(), id)
+	detail, err := store.DescribeClaim(context.TODO(), campaignId, id)
 	assert.NoError(t, err)
 	assert.Equal(t, "Genoa", detail.Name)
 	assert.Contains(t, detail.Provinces, "Saluzzo")
 
-	detail, err = store.DescribeClaim(context.TODO(), 9999)
+	detail, err = store.DescribeClaim(context.TODO(), campaignId, 9999)
 	assert.ErrorIs(t, err, ErrNoSuchClaim)
 }
 
 func TestCountClaims(t *testing.T) {
 	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestFlush"))
 	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
 
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Genoa", CLAIM_TYPE_TRADE)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Valencia", CLAIM_TYPE_TRADE)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Italy", CLAIM_TYPE_REGION)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Iberia", CLAIM_TYPE_REGION)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Ragusa", CLAIM_TYPE_TRADE)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Genoa", CLAIM_TYPE_TRADE)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Valencia", CLAIM_TYPE_TRADE)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Italy", CLAIM_TYPE_REGION)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Iberia", CLAIM_TYPE_REGION)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Ragusa", CLAIM_TYPE_TRADE)
 
-	total, uniquePlayers, err := store.CountClaims(context.TODO())
+	total, uniquePlayers, err := store.CountClaims(context.TODO(), campaignId)
 	assert.NoError(t, err)
 	assert.Condition(t, func() bool { return total > 0 })
 	assert.Condition(t, func() bool { return uniquePlayers > 0 })
@@ -204,15 +272,173 @@ func TestCountClaims(t *testing.T) {
 func TestFlush(t *testing.T) {
 	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestFlush"))
 	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
 
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Genoa", CLAIM_TYPE_TRADE)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Valencia", CLAIM_TYPE_TRADE)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Italy", CLAIM_TYPE_REGION)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Iberia", CLAIM_TYPE_REGION)
-	store.Claim(context.TODO(), "000000000000000001", "foo", "Ragusa", CLAIM_TYPE_TRADE)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Genoa", CLAIM_TYPE_TRADE)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Valencia", CLAIM_TYPE_TRADE)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Italy", CLAIM_TYPE_REGION)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Iberia", CLAIM_TYPE_REGION)
+	store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Ragusa", CLAIM_TYPE_TRADE)
 
-	assert.NoError(t, store.Flush(context.TODO()))
-	claims, err := store.ListClaims(context.TODO())
+	_, err = store.Flush(context.TODO(), campaignId, "000000000000000001", "test")
+	assert.NoError(t, err)
+	claims, err := store.ListClaims(context.TODO(), campaignId)
 	assert.NoError(t, err)
 	assert.Equal(t, 0, len(claims))
 }
+
+func TestClaimMany(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestClaimMany"))
+	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	barId, err := store.Claim(context.TODO(), campaignId, "000000000000000002", "bar", "Lower Rhineland", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+
+	// conflicts with the claims of other players are all reported
+	_, err = store.ClaimMany(context.TODO(), campaignId, "000000000000000001", "foo",
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_TRADE, Name: "bordeaux"}},
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_AREA, Name: "Guyenne"}},
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_AREA, Name: "Lower Rhineland"}},
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_TRADE, Name: "Rheinland"}},
+	)
+	var conflict ErrConflict
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, 8, len(conflict.Conflicts))
+	for _, c := range conflict.Conflicts {
+		assert.Equal(t, Conflict{Province: c.Province, Player: "bar", ClaimType: CLAIM_TYPE_AREA, Claim: "Lower Rhineland", ClaimID: barId}, c)
+	}
+
+	// unknown zones fail the whole bundle
+	_, err = store.ClaimMany(context.TODO(), campaignId, "000000000000000001", "foo",
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_TRADE, Name: "Genoa"}},
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_AREA, Name: "Atlantis"}},
+	)
+	assert.Error(t, err)
+
+	total, _, err := store.CountClaims(context.TODO(), campaignId)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	ids, err := store.ClaimMany(context.TODO(), campaignId, "000000000000000001", "foo",
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_TRADE, Name: "bordeaux"}, Exclusions: []Zone{{Type: CLAIM_TYPE_AREA, Name: "Guyenne"}}},
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_AREA, Name: "Guyenne"}},
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_TRADE, Name: "Genoa"}},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(ids))
+
+	claims, err := store.ListClaims(context.TODO(), campaignId)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(claims))
+	assert.Equal(t, ids[0], claims[1].ID)
+	assert.Equal(t, "Bordeaux", claims[1].Name)
+
+	// zones of a bundle may overlap, like claims the player makes one at a
+	// time
+	ids, err = store.ClaimMany(context.TODO(), campaignId, "000000000000000001", "foo",
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_REGION, Name: "France"}},
+		ClaimRequest{Zone: Zone{Type: CLAIM_TYPE_AREA, Name: "Gascony"}},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(ids))
+}
+
+func TestClaimConcurrency(t *testing.T) {
+	// two stores on the same database file, like the server and one of its
+	// subcommands, their claims really run in parallel
+	path := filepath.Join(t.TempDir(), "themis.db")
+	assert.NoError(t, os.WriteFile(path, nil, 0o600))
+	conn := fmt.Sprintf(CONN_STRING_PATTERN, path)
+	stores := make([]*Store, 2)
+	for i := range stores {
+		store, err := NewStore(conn)
+		assert.NoError(t, err)
+		defer store.Close()
+		stores[i] = store
+	}
+	campaignId, err := stores[0].CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	// overlapping zones around France and the Rhine
+	zones := []Zone{
+		{Type: CLAIM_TYPE_REGION, Name: "France"},
+		{Type: CLAIM_TYPE_REGION, Name: "North Germany"},
+		{Type: CLAIM_TYPE_AREA, Name: "Gascony"},
+		{Type: CLAIM_TYPE_AREA, Name: "Guyenne"},
+		{Type: CLAIM_TYPE_AREA, Name: "Lower Rhineland"},
+		{Type: CLAIM_TYPE_TRADE, Name: "Bordeaux"},
+		{Type: CLAIM_TYPE_TRADE, Name: "Rheinland"},
+		{Type: CLAIM_TYPE_PROVINCE, Name: "Foix"},
+	}
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 8*len(zones))
+	for u := 0; u < 8; u++ {
+		for _, z := range zones {
+			wg.Add(1)
+			go func(store *Store, userId string, z Zone) {
+				defer wg.Done()
+				_, err := store.Claim(context.TODO(), campaignId, userId, userId, z.Name, z.Type)
+				errs <- err
+			}(stores[u%len(stores)], fmt.Sprintf("%018d", u), z)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		var conflict ErrConflict
+		if err != nil && !errors.As(err, &conflict) {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+
+	claims, err := stores[1].ListClaims(context.TODO(), campaignId)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, claims)
+
+	// no province is held by two players
+	rows, err := stores[1].db.Query(`SELECT province_id FROM claim_provinces WHERE campaign_id = ?
+	GROUP BY province_id HAVING COUNT(DISTINCT userid) > 1`, campaignId)
+	assert.NoError(t, err)
+	defer rows.Close()
+	shared := make([]string, 0)
+	for rows.Next() {
+		var id string
+		assert.NoError(t, rows.Scan(&id))
+		shared = append(shared, id)
+	}
+	assert.NoError(t, rows.Err())
+	assert.Empty(t, shared)
+}
+
+func TestConflictTrigger(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestConflictTrigger"))
+	assert.NoError(t, err)
+	campaignId, err := store.CreateCampaign(context.TODO(), TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+
+	franceId, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "France", CLAIM_TYPE_REGION)
+	assert.NoError(t, err)
+	bordeauxId, err := store.Claim(context.TODO(), campaignId, "000000000000000001", "foo", "Bordeaux", CLAIM_TYPE_TRADE)
+	assert.NoError(t, err)
+
+	// writes bypassing the store's checks are refused by the database
+	_, err = store.db.Exec("INSERT INTO claim_provinces (claim_id, campaign_id, userid, province_id) VALUES (?, ?, ?, '176')", franceId+100, campaignId, "000000000000000002")
+	assert.ErrorContains(t, err, "already claimed by another player")
+	_, err = store.db.Exec("UPDATE claims SET userid = ? WHERE id = ?", "000000000000000002", bordeauxId)
+	assert.ErrorContains(t, err, "already claimed by another player")
+
+	// handing over one of two overlapping claims would create conflicts
+	err = store.TransferClaim(context.TODO(), campaignId, bordeauxId, "000000000000000001", "000000000000000002", "bar")
+	var conflict ErrConflict
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, franceId, conflict.Conflicts[0].ClaimID)
+
+	// released provinces are free again
+	assert.NoError(t, store.DeleteClaim(context.TODO(), campaignId, franceId, "000000000000000001"))
+	assert.NoError(t, store.TransferClaim(context.TODO(), campaignId, bordeauxId, "000000000000000001", "000000000000000002", "bar"))
+}
@@ -0,0 +1,368 @@
+package themis
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// TradeLink is a link of the trade network, trade flows from the upstream
+// node to the downstream one.
+type TradeLink struct {
+	Upstream   string
+	Downstream string
+}
+
+func (l TradeLink) String() string {
+	return fmt.Sprintf("%s -> %s", l.Upstream, l.Downstream)
+}
+
+// ParseTradeLinksCSV reads the links of the trade network from a CSV file with
+// a header row. Trade flows from the node of the `from` column to the node of
+// the `to` column, other columns are ignored. Duplicate links are removed.
+func ParseTradeLinksCSV(r io.Reader) ([]TradeLink, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ValidationError{Problems: []string{"file is empty"}}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	from, hasFrom := columns["from"]
+	to, hasTo := columns["to"]
+	if !hasFrom || !hasTo {
+		return nil, ValidationError{Problems: []string{`missing required columns "from" and "to"`}}
+	}
+
+	problems := make([]string, 0)
+	links := make([]TradeLink, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read record: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		cell := func(i int) string {
+			if i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		link := TradeLink{Upstream: cell(from), Downstream: cell(to)}
+		switch {
+		case link.Upstream == "" || link.Downstream == "":
+			problems = append(problems, fmt.Sprintf("line %d: missing trade node", line))
+		case strings.EqualFold(link.Upstream, link.Downstream):
+			problems = append(problems, fmt.Sprintf("line %d: trade node %s can't flow into itself", line, link.Upstream))
+		default:
+			links = append(links, link)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, ValidationError{Problems: problems}
+	}
+	return uniqueTradeLinks(links), nil
+}
+
+// LoadTradeNetwork reads the links of the trade network from the files of an
+// EU4 installation or mod. Each node of common/tradenodes/00_tradenodes.txt
+// lists the nodes its trade flows into in its outgoing blocks. Nodes are named
+// the same way as by LoadGameFiles.
+func LoadTradeNetwork(fsys fs.FS) ([]TradeLink, error) {
+	names, err := loadLocalisation(fsys)
+	if err != nil {
+		return nil, err
+	}
+	localise := localiser(names)
+
+	nodes, err := readScript(fsys, GAME_FILE_TRADE_NODES, true)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for _, node := range nodes {
+		if node.IsBlock {
+			known[node.Key] = true
+		}
+	}
+
+	problems := make([]string, 0)
+	links := make([]TradeLink, 0)
+	for _, node := range nodes {
+		if !node.IsBlock {
+			continue
+		}
+		for _, c := range node.Children {
+			if !strings.EqualFold(c.Key, "outgoing") {
+				continue
+			}
+			target, ok := c.Child("name")
+			if !ok || target.Value == "" {
+				problems = append(problems, fmt.Sprintf("%s line %d: outgoing link of %s has no name", GAME_FILE_TRADE_NODES, c.Line, node.Key))
+				continue
+			}
+			if !known[target.Value] {
+				problems = append(problems, fmt.Sprintf("%s line %d: unknown trade node %s in %s", GAME_FILE_TRADE_NODES, c.Line, target.Value, node.Key))
+				continue
+			}
+			links = append(links, TradeLink{Upstream: localise(node.Key, ""), Downstream: localise(target.Value, "")})
+		}
+	}
+	if len(problems) > 0 {
+		return nil, ValidationError{Problems: problems}
+	}
+	return uniqueTradeLinks(links), nil
+}
+
+// uniqueTradeLinks returns the links sorted and without duplicates.
+func uniqueTradeLinks(links []TradeLink) []TradeLink {
+	seen := make(map[TradeLink]bool)
+	unique := make([]TradeLink, 0, len(links))
+	for _, l := range links {
+		if !seen[l] {
+			seen[l] = true
+			unique = append(unique, l)
+		}
+	}
+	sort.Slice(unique, func(i, j int) bool {
+		if unique[i].Upstream != unique[j].Upstream {
+			return unique[i].Upstream < unique[j].Upstream
+		}
+		return unique[i].Downstream < unique[j].Downstream
+	})
+	return unique
+}
+
+// ImportTradeLinks replaces the trade network of the dataset. Nodes are
+// matched by name, case-insensitively, links with a node that is not part of
+// the dataset are ignored. It returns the number of links imported and
+// ignored.
+func (s *Store) ImportTradeLinks(ctx context.Context, dataset string, links []TradeLink) (imported, ignored int, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	datasetId, err := findDataset(ctx, tx, dataset)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, name FROM trade_nodes WHERE dataset_id = ?", datasetId)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to execute query: %w", err)
+	}
+	nodes := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan row: %w", err)
+		}
+		nodes[strings.ToLower(name)] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM trade_links WHERE dataset_id = ?", datasetId); err != nil {
+		return 0, 0, fmt.Errorf("failed to delete trade links: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO trade_links (dataset_id, upstream_id, downstream_id) VALUES (?, ?, ?)
+	ON CONFLICT(upstream_id, downstream_id) DO NOTHING`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+	for _, l := range uniqueTradeLinks(links) {
+		upstream, ok := nodes[strings.ToLower(l.Upstream)]
+		downstream, ok2 := nodes[strings.ToLower(l.Downstream)]
+		if !ok || !ok2 {
+			ignored++
+			continue
+		}
+		res, err := stmt.ExecContext(ctx, datasetId, upstream, downstream)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to insert trade link %s: %w", l, err)
+		}
+		// links differing only by case are the same link
+		if n, _ := res.RowsAffected(); n > 0 {
+			imported++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return imported, ignored, nil
+}
+
+// TradeNode is a node of the trade network and the players holding its
+// provinces in a campaign.
+type TradeNode struct {
+	Name      string
+	Provinces int
+	// Holders are ordered by the number of provinces they hold, the most
+	// first.
+	Holders []TradeNodeHolder
+}
+
+// TradeNodeHolder is a player holding provinces of a trade node, through any
+// kind of claim.
+type TradeNodeHolder struct {
+	Player    string
+	UserID    string
+	Provinces int
+}
+
+func (n TradeNode) String() string {
+	if len(n.Holders) == 0 {
+		return fmt.Sprintf("%s (%d provinces): free", n.Name, n.Provinces)
+	}
+	holders := make([]string, 0, len(n.Holders))
+	for _, h := range n.Holders {
+		holders = append(holders, fmt.Sprintf("%s %d", h.Player, h.Provinces))
+	}
+	return fmt.Sprintf("%s (%d provinces): %s", n.Name, n.Provinces, strings.Join(holders, ", "))
+}
+
+// DescribeTradeNode returns the trade node of the campaign's dataset with the
+// given name and who holds its provinces.
+func (s *Store) DescribeTradeNode(ctx context.Context, campaignId int, node string) (TradeNode, error) {
+	datasetId, err := campaignDataset(ctx, s.db, campaignId)
+	if err != nil {
+		return TradeNode{}, err
+	}
+	name, err := resolveZone(ctx, s.db, datasetId, Zone{Type: CLAIM_TYPE_TRADE, Name: node})
+	if err != nil {
+		return TradeNode{}, err
+	}
+
+	nodes, err := describeTradeNodes(ctx, s.db, campaignId, datasetId, []string{name})
+	if err != nil {
+		return TradeNode{}, err
+	}
+	return nodes[0], nil
+}
+
+// TradeUpstream returns the nodes whose trade flows into the given node, with
+// who holds them in the campaign, ordered by name. It returns ErrNoTradeLinks
+// if the campaign's dataset has no trade network.
+func (s *Store) TradeUpstream(ctx context.Context, campaignId int, node string) ([]TradeNode, error) {
+	return s.tradeNeighbors(ctx, campaignId, node, true)
+}
+
+// TradeDownstream returns the nodes the trade of the given node flows into,
+// with who holds them in the campaign, ordered by name. It returns
+// ErrNoTradeLinks if the campaign's dataset has no trade network.
+func (s *Store) TradeDownstream(ctx context.Context, campaignId int, node string) ([]TradeNode, error) {
+	return s.tradeNeighbors(ctx, campaignId, node, false)
+}
+
+func (s *Store) tradeNeighbors(ctx context.Context, campaignId int, node string, upstream bool) ([]TradeNode, error) {
+	datasetId, err := campaignDataset(ctx, s.db, campaignId)
+	if err != nil {
+		return nil, err
+	}
+	name, err := resolveZone(ctx, s.db, datasetId, Zone{Type: CLAIM_TYPE_TRADE, Name: node})
+	if err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	err = s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM trade_links WHERE dataset_id = ?)", datasetId).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan row: %w", err)
+	}
+	if !exists {
+		return nil, ErrNoTradeLinks
+	}
+
+	// the neighbor is the other end of the link
+	neighbor, self := "upstream_id", "downstream_id"
+	if !upstream {
+		neighbor, self = self, neighbor
+	}
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`SELECT neighbor.name FROM trade_links
+	JOIN trade_nodes AS neighbor ON neighbor.id = trade_links.%s
+	JOIN trade_nodes AS self ON self.id = trade_links.%s
+	WHERE self.dataset_id = ? AND self.name = ?
+	ORDER BY neighbor.name`, neighbor, self), datasetId, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	names := make([]string, 0)
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		names = append(names, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	return describeTradeNodes(ctx, s.db, campaignId, datasetId, names)
+}
+
+// describeTradeNodes counts the provinces of the trade nodes, of the types
+// counted by the campaign, and who holds them in the campaign. Nodes are returned in the order of the names.
+func describeTradeNodes(ctx context.Context, q querier, campaignId, datasetId int, names []string) ([]TradeNode, error) {
+	nodes := make([]TradeNode, 0, len(names))
+	for _, name := range names {
+		node := TradeNode{Name: name, Holders: make([]TradeNodeHolder, 0)}
+		err := q.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(1) FROM provinces WHERE dataset_id = ? AND trade_node = ? AND %s", provinceTypeCondition("?", "typ")),
+			datasetId, name, campaignId).Scan(&node.Provinces)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		rows, err := q.QueryContext(ctx, `SELECT MIN(claims.player), IFNULL(claim_provinces.userid, ''), COUNT(DISTINCT claim_provinces.province_id)
+		FROM provinces
+		JOIN claim_provinces ON claim_provinces.campaign_id = ?1 AND claim_provinces.province_id = provinces.id
+		JOIN claims ON claims.id = claim_provinces.claim_id
+		WHERE provinces.dataset_id = ?2 AND provinces.trade_node = ?3
+		GROUP BY claim_provinces.userid
+		ORDER BY COUNT(DISTINCT claim_provinces.province_id) DESC, MIN(claims.player)`, campaignId, datasetId, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute query: %w", err)
+		}
+		for rows.Next() {
+			h := TradeNodeHolder{}
+			if err := rows.Scan(&h.Player, &h.UserID, &h.Provinces); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan row: %w", err)
+			}
+			node.Holders = append(node.Holders, h)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to iterate rows: %w", err)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
@@ -0,0 +1,112 @@
+package themis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTradeLinksCSV(t *testing.T) {
+	links, err := ParseTradeLinksCSV(strings.NewReader(`from,to
+Rheinland,Champagne
+Champagne,Genoa
+Rheinland,Champagne
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []TradeLink{{Upstream: "Champagne", Downstream: "Genoa"}, {Upstream: "Rheinland", Downstream: "Champagne"}}, links)
+
+	_, err = ParseTradeLinksCSV(strings.NewReader("from,to\nGenoa,genoa\nGenoa,\n"))
+	var verr ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Equal(t, []string{"line 2: trade node Genoa can't flow into itself", "line 3: missing trade node"}, verr.Problems)
+}
+
+func TestLoadTradeNetwork(t *testing.T) {
+	links, err := LoadTradeNetwork(os.DirFS("testdata/eu4"))
+	assert.NoError(t, err)
+	assert.Equal(t, []TradeLink{{Upstream: "Baltic Sea", Downstream: "Lübeck"}, {Upstream: "Novgorod", Downstream: "Baltic Sea"}}, links)
+
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestLoadTradeNetwork"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	provinces, err := LoadGameFiles(os.DirFS("testdata/eu4"))
+	assert.NoError(t, err)
+	_, err = store.ImportProvinces(context.TODO(), "scandinavia", provinces)
+	assert.NoError(t, err)
+	imported, ignored, err := store.ImportTradeLinks(context.TODO(), "scandinavia", links)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, imported)
+	assert.Equal(t, 1, ignored)
+}
+
+func TestTradeFlow(t *testing.T) {
+	store, err := NewStore(fmt.Sprintf(TEST_CONN_STRING_PATTERN, "TestTradeFlow"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.TODO()
+	campaignId, err := store.CreateCampaign(ctx, TEST_GUILD_ID, t.Name())
+	assert.NoError(t, err)
+	_, err = store.Claim(ctx, campaignId, "000000000000000001", "foo", "Lower Rhineland", CLAIM_TYPE_AREA)
+	assert.NoError(t, err)
+	_, err = store.Claim(ctx, campaignId, "000000000000000002", "bar", "Roma", CLAIM_TYPE_PROVINCE)
+	assert.NoError(t, err)
+
+	_, err = store.TradeUpstream(ctx, campaignId, "Champagne")
+	assert.ErrorIs(t, err, ErrNoTradeLinks)
+
+	imported, ignored, err := store.ImportTradeLinks(ctx, DEFAULT_DATASET, []TradeLink{
+		{Upstream: "Saxony", Downstream: "Rheinland"},
+		{Upstream: "Rheinland", Downstream: "Champagne"},
+		{Upstream: "champagne", Downstream: "Genoa"},
+		{Upstream: "Champagne", Downstream: "English Channel"},
+		{Upstream: "Atlantis", Downstream: "Genoa"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, imported)
+	assert.Equal(t, 1, ignored)
+
+	upstream, err := store.TradeUpstream(ctx, campaignId, "champagne")
+	assert.NoError(t, err)
+	assert.Equal(t, []TradeNode{{Name: "Rheinland", Provinces: 42, Holders: []TradeNodeHolder{{Player: "foo", UserID: "000000000000000001", Provinces: 4}}}}, upstream)
+
+	downstream, err := store.TradeDownstream(ctx, campaignId, "Champagne")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(downstream))
+	assert.Equal(t, "English Channel (52 provinces): free", downstream[0].String())
+	assert.Equal(t, "Genoa (41 provinces): bar 1", downstream[1].String())
+
+	downstream, err = store.TradeDownstream(ctx, campaignId, "Genoa")
+	assert.NoError(t, err)
+	assert.Empty(t, downstream)
+
+	node, err := store.DescribeTradeNode(ctx, campaignId, "rheinland")
+	assert.NoError(t, err)
+	assert.Equal(t, "Rheinland", node.Name)
+	assert.Equal(t, 1, len(node.Holders))
+
+	_, err = store.TradeUpstream(ctx, campaignId, "Atlantis")
+	assert.Error(t, err)
+
+	// links of a removed node go with it
+	provinces, err := store.ListProvinces(ctx, ProvinceFilter{})
+	assert.NoError(t, err)
+	for i, p := range provinces {
+		if p.TradeNode == "Champagne" {
+			provinces[i].TradeNode = "Paris"
+		}
+	}
+	_, err = store.ImportProvinces(ctx, DEFAULT_DATASET, provinces)
+	assert.NoError(t, err)
+	downstream, err = store.TradeDownstream(ctx, campaignId, "Rheinland")
+	assert.NoError(t, err)
+	assert.Empty(t, downstream)
+	upstream, err = store.TradeUpstream(ctx, campaignId, "Rheinland")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(upstream))
+}